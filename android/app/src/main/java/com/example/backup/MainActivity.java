@@ -37,7 +37,12 @@ public class MainActivity extends AppCompatActivity {
     // On push button, Restore Backup
     public void clickEventRestore(View v) {
         // delete backup data
-        Backuplib.init(null, folder);
+        try {
+           Backuplib.init(null, folder);
+        } catch (Exception e) {
+           Log.d("Backuplib", "Error Init : "+e.getMessage());
+           return;
+        }
 
         File f = new File(backupFile);
         long ncustodians = 0;
@@ -48,7 +53,7 @@ public class MainActivity extends AppCompatActivity {
               Backuplib.decodeUnencrypted(backupFile);
               Log.d("Backuplib", "Decode Unencrypted OK");
 
-              Custodians custodians = Backuplib.getSecretCustodians();
+              Custodians custodians = Backuplib.getCustodians();
               ncustodians = Backuplib.getNCustodians();
               Log.d("Backuplib", "Number of Custodians: "+String.valueOf(ncustodians));
               for (int i=0; i<ncustodians; i++){
@@ -65,7 +70,7 @@ public class MainActivity extends AppCompatActivity {
            
 
           } catch (Exception e){
-              Log.d("Backuplib", "Error Decode Unencrypted");
+              Log.d("Backuplib", "Error Decode Unencrypted : "+e.getMessage());
           }
 
 
@@ -82,7 +87,12 @@ public class MainActivity extends AppCompatActivity {
 
     // On bpush button, Backup wallet
     public void clickEventBackup(View v) {
-        Backuplib.init(null, folder);
+        try {
+           Backuplib.init(null, folder);
+        } catch (Exception e) {
+           Log.d("Backuplib", "Error Init : "+e.getMessage());
+           return;
+        }
         Intent genQR = new Intent(getApplicationContext(), ScanQr.class);
         
         startActivity(genQR);
@@ -106,14 +106,19 @@ public class Restore extends AppCompatActivity {
             Log.d("Backuplib", "Custodian["+String.valueOf(custodian_idx)+"] Fname : "+fname);
 
             long prev_nshares = Backuplib.getNShares();
-            Backuplib.scanQRShare(fname);
+            try {
+                Backuplib.scanQRShare(fname);
+            } catch (Exception e) {
+                Log.d("Backuplib", "Error Scanning Share : "+e.getMessage());
+                warningTV.setText("Invalid share");
+                return;
+            }
             long post_nshares = Backuplib.getNShares();
             Shares shares = Backuplib.getShares();
             for (long i=prev_nshares; i < post_nshares; i++){
                Share share = Backuplib.getShare(i);
-               String PyString = byteArrayToString(share.getPy());
-               Log.d("Backuplib", "Share["+String.valueOf(i)+"] - Px : " +String.valueOf(share.getPx()));
-               Log.d("Backuplib", "Share["+String.valueOf(i)+"] - Py : " +PyString);
+               String shareString = byteArrayToString(share.getData());
+               Log.d("Backuplib", "Share["+String.valueOf(i)+"] : " +shareString);
             }
 
             if (qrFile.exists()) {
@@ -139,7 +144,14 @@ public class Restore extends AppCompatActivity {
 
         if (nshares >= minShares) {
             // Generate Key
-            byte[] kOp = Backuplib.generateKey();
+            byte[] kOp;
+            try {
+                kOp = Backuplib.generateKey();
+            } catch (Exception e) {
+                Log.d("Backuplib", "Error Generating Key : "+e.getMessage());
+                warningTV.setText("Error recovering key");
+                return;
+            }
             Backuplib.setkOp(kOp);
             String kOpString = byteArrayToString(kOp);
             Log.d("Backuplib", "kOp["+String.valueOf(kOp.length)+"] : " +kOpString);
@@ -156,7 +168,7 @@ public class Restore extends AppCompatActivity {
                 startActivity(Home);
 
             } catch (Exception e) {
-                Log.d("Backuplib", "Error Decode Encrypted");
+                Log.d("Backuplib", "Error Decode Encrypted : "+e.getMessage());
                 warningTV.setText("Error during decryption");
             }
 
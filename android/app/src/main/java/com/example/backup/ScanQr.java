@@ -83,19 +83,23 @@ public class ScanQr extends AppCompatActivity {
         Backuplib.setkOp(kOp);
         String kOpString = byteArrayToString(kOp);
         Log.d("Backuplib", "kOp["+String.valueOf(kOp.length)+"] : " +kOpString);
-        Backuplib.init(kOp, folder);
-
 
         // Generate shares from key
-        Backuplib.generateShares(kOp);
+        try {
+           Backuplib.init(kOp, folder);
+           Backuplib.generateShares(kOp);
+        } catch (Exception e) {
+           Log.d("Backuplib", "Error Generating Shares : "+e.getMessage());
+           warningTV.setText("Error generating shares");
+           return;
+        }
         long nshares = Backuplib.getNShares();
         Log.d("Backuplib", "Generated "+ String.valueOf(nshares)+ " Shares");
         Shares shares = Backuplib.getShares();
         for (int i=0; i < nshares; i++){
           Share share = Backuplib.getShare(i);
-          String PyString = byteArrayToString(share.getPy());
-          Log.d("Backuplib", "Share["+String.valueOf(i)+"] - Px : " +String.valueOf(share.getPx()));
-          Log.d("Backuplib", "Share["+String.valueOf(i)+"] - Py : " +PyString);
+          String shareString = byteArrayToString(share.getData());
+          Log.d("Backuplib", "Share["+String.valueOf(i)+"] : " +shareString);
         }
     }
 
@@ -142,7 +146,7 @@ public class ScanQr extends AppCompatActivity {
                 Backuplib.addCustodian(custodian_name, folder, Backuplib.QR, nshares, nSharesToCreate);
                 Log.d("Backuplib", "Number of shares distributed : "+String.valueOf(nshares));
                 Log.d("Backuplib", "Number of shares requested : "+String.valueOf(nSharesToCreate));
-                Custodians custodians = Backuplib.getSecretCustodians();
+                Custodians custodians = Backuplib.getCustodians();
                 long ncustodians = Backuplib.getNCustodians();
                 Log.d("Backuplib", "Number of Custodians: "+String.valueOf(ncustodians));
                 Custodian custodian = Backuplib.getCustodian(ncustodians-1);
@@ -151,7 +155,7 @@ public class ScanQr extends AppCompatActivity {
                 Log.d("Backuplib", "Custodian["+String.valueOf(ncustodians-1)+"] Fname : "+custodian.getFname());
 
             } catch (Exception e) {
-                Log.d("Backuplib", "Error Adding Custodian " + custodian_name);
+                Log.d("Backuplib", "Error Adding Custodian " + custodian_name + " : " + e.getMessage());
             }
             nshares+=nSharesToCreate;
             updateNSharesTV();
@@ -183,31 +187,37 @@ public class ScanQr extends AppCompatActivity {
 
         if (nshares >= minShares) {
 
-            // Add wallet configuration
-            Log.d("Backuplib", "Add WALLET_CONFIG");
-            Backuplib.addToBackup(Backuplib.WALLET_CONFIG, Backuplib.ENCRYPT);
-            // Add Custodian information (contact details) -> unencrypted
-            Log.d("Backuplib", "Add CUSTODIAN");
-            Backuplib.addToBackup(Backuplib.CUSTODIAN, Backuplib.DONT_ENCRYPT);
-            // Add SSharing info. We need Prime number and protocol used (Shamir) -> unencrypted
-            Log.d("Backuplib", "Add SSHARING");
-            Backuplib.addToBackup(Backuplib.SSHARING, Backuplib.DONT_ENCRYPT);
-            // Add Shares. We heed to keep a list of at least outstanding shares in case
-            //  we want to redistribute in the future. in this example I keep all for simplicity.
-            Log.d("Backuplib", "Add SHARES");
-            Backuplib.addToBackup(Backuplib.SHARES, Backuplib.ENCRYPT);
-            // Add KeyStore
-            Log.d("Backuplib", "Add PKEYS");
-	    Backuplib.addToBackup(Backuplib.PKEYS, Backuplib.ENCRYPT);
-            // Add Storage
-            Log.d("Backuplib", "Add STORAGE");
-	    Backuplib.addToBackup(Backuplib.STORAGE, Backuplib.ENCRYPT);
-
-            // Generate Backupfile -> Here we select the Key derivation algo and the encryption mechanism used
-            //  for encrypted sections. Also not, that we can mix encrypted and non-encrpyted information in the
-            // same baclup file
-            Backuplib.createBackup(Backuplib.PBKDF2_KEY, Backuplib.SHA256_HASH, Backuplib.GCM_ENCRYPTION, backupFile);
-            Log.d("Backuplib", "Backup Created");
+            try {
+              // Add wallet configuration
+              Log.d("Backuplib", "Add WALLET_CONFIG");
+              Backuplib.addToBackup(Backuplib.WALLET_CONFIG, Backuplib.ENCRYPT);
+              // Add Custodian information (contact details) -> unencrypted
+              Log.d("Backuplib", "Add CUSTODIAN");
+              Backuplib.addToBackup(Backuplib.CUSTODIAN, Backuplib.DONT_ENCRYPT);
+              // Add SSharing info. We need Prime number and protocol used (Shamir) -> unencrypted
+              Log.d("Backuplib", "Add SSHARING");
+              Backuplib.addToBackup(Backuplib.SSHARING, Backuplib.DONT_ENCRYPT);
+              // Add Shares. We heed to keep a list of at least outstanding shares in case
+              //  we want to redistribute in the future. in this example I keep all for simplicity.
+              Log.d("Backuplib", "Add SHARES");
+              Backuplib.addToBackup(Backuplib.SHARES, Backuplib.ENCRYPT);
+              // Add KeyStore
+              Log.d("Backuplib", "Add PKEYS");
+              Backuplib.addToBackup(Backuplib.PKEYS, Backuplib.ENCRYPT);
+              // Add Storage
+              Log.d("Backuplib", "Add STORAGE");
+              Backuplib.addToBackup(Backuplib.STORAGE, Backuplib.ENCRYPT);
+
+              // Generate Backupfile -> Here we select the Key derivation algo and the encryption mechanism used
+              //  for encrypted sections. Also not, that we can mix encrypted and non-encrpyted information in the
+              // same baclup file
+              Backuplib.createBackup(backupFile);
+              Log.d("Backuplib", "Backup Created");
+            } catch (Exception e) {
+              Log.d("Backuplib", "Error Creating Backup : "+e.getMessage());
+              warningTV.setText("Error creating backup");
+              return;
+            }
 
             Intent Home = new Intent(getApplicationContext(), MainActivity.class);
             startActivity(Home);
@@ -3,117 +3,118 @@
 package backuplib
 
 import (
+	"errors"
 	"fmt"
 	fc "github.com/iden3/go-backup/filecrypt"
+	"sort"
 	"strconv"
 )
 
 const (
 	ENCRYPT = iota
 	DONT_ENCRYPT
+	SOURCE_POLICY // use encryption policy of backup source
 )
 
 type Backup struct {
-	data interface{}
-	mode int
+	data    interface{}
+	mode    int
+	version int // schema version of data
 }
 
-// Summary of contents of backup file
-var backupRegistry map[int]Backup
-
-// Record and register backup data structures
-func AddToBackup(t, action int) {
+// Record and register backup data structures exported by source registered with type t. Sources
+// without data to export (for example, no access policy defined) are not included
+func (s *BackupSession) AddToBackup(t, action int) error {
+	if action != ENCRYPT && action != DONT_ENCRYPT && action != SOURCE_POLICY {
+		return newErrorf(ERR_INVALID_ARG, "AddToBackup", "Invalid encryption mode")
+	}
 	// check for duplicates
-	for idx, _ := range backupRegistry {
-		if idx == t {
-			return
-		}
+	s.mu.Lock()
+	_, ok := s.registry[t]
+	s.mu.Unlock()
+	if ok {
+		return nil
+	}
+	backupEl, err := s.newBackupElement(t, action)
+	if err != nil || backupEl == nil {
+		return err
 	}
-	backupEl := newBackupElement(t, action)
-	backupRegistry[t] = *backupEl
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registry[t] = *backupEl
+	return nil
 }
 
-func newBackupElement(t, action int) *Backup {
-	switch t {
-
-	case WALLET_CONFIG:
-		backupEl := Backup{data: GetWallet(),
-			mode: action,
-		}
-		return &backupEl
-
-	case CUSTODIAN:
-		backupEl := Backup{data: GetCustodians(),
-			mode: action,
-		}
-		return &backupEl
-
-	case SSHARING:
-		backupEl := Backup{data: GetSecretCfgOriginal(),
-			mode: action,
-		}
-		return &backupEl
-
-	case SHARES:
-		backupEl := Backup{data: toShares(GetShares()),
-			mode: action,
-		}
-		return &backupEl
-
-	case PKEYS:
-		pass := GetkOp()
-		_, keystore := id.Export(pass)
-		if keystore == nil {
-			return nil
-		}
-		keyStore2PK(keystore, pass)
-		backupEl := Backup{data: GetPrivateKeys(),
-			mode: action,
+// Export data from source. Session lock is not held so that sources can use session methods
+func (s *BackupSession) newBackupElement(t, action int) (*Backup, error) {
+	src := GetBackupSource(t)
+	if src == nil {
+		return nil, newErrorf(ERR_INVALID_ARG, "AddToBackup", "Unknown backup source "+strconv.Itoa(t))
+	}
+	data, err := src.Export(s)
+	if err != nil {
+		var backupErr *BackupError
+		if errors.As(err, &backupErr) {
+			return nil, err
 		}
-		return &backupEl
+		return nil, newError(ERR_SOURCE, "AddToBackup", fmt.Errorf("Export %s : %w", src.Name(), err))
+	}
+	if data == nil {
+		return nil, nil
+	}
+	if action == SOURCE_POLICY {
+		action = src.Mode()
+	}
+	return &Backup{data: data,
+		mode:    action,
+		version: src.Version(),
+	}, nil
+}
 
-	case STORAGE:
-		pass := GetkOp()
-		storage, _ := id.Export(pass)
-		if storage == nil {
-			return nil
-		}
-		storage2KV(storage)
-		backupEl := Backup{data: GetStorage(),
-			mode: action,
-		}
-		return &backupEl
+// Generate backup file
+func (s *BackupSession) CreateBackup(fname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.createBackup(fname)
+	if err != nil {
+		return err
 	}
+	s.commitStorageIndex(index)
 	return nil
 }
 
-// Generate backup file
-func CreateBackup(fname string) error {
-	key := GetkOp()
-	nBlocks := len(backupRegistry)
+// Generate backup file. Returns storage index to commit once the backup is stored (see
+// pendingStorageIndex). Called with lock held
+func (s *BackupSession) createBackup(fname string) (*storageIndex, error) {
+	key := s.data.kOp
+	nBlocks := len(s.registry)
 	fileCrypt, err := fc.New(nBlocks, fname, key, key, fc.FC_KEY_T_PBKDF2)
 	if err != nil {
-		return fmt.Errorf("New FC : %w", err)
+		return nil, newError(ERR_IO, "CreateBackup", fmt.Errorf("New FC : %w", err))
 	}
 
+	// blocks are sorted by type
+	types := make([]int, 0, nBlocks)
+	for t := range s.registry {
+		types = append(types, t)
+	}
+	sort.Ints(types)
+
 	// There are two types of blcks defined for now:
 	// Encrypted -> PBKDF2 Key Header + GCM Enc Header
 	// Not Encrypted -> PBKDF2 Key HEader + ClearFC Enc Header
-	for idx, el := range backupRegistry {
+	for _, t := range types {
+		el := s.registry[t]
 		// Add Enc Header
 		fcType := fc.FC_GCM
 		if el.mode == DONT_ENCRYPT {
 			fcType = fc.FC_CLEAR
 		}
-		tag := strconv.Itoa(idx)
-		err := fileCrypt.AddBlock([]byte(tag), fcType, el.data)
+		err := fileCrypt.AddBlock(sourceTag(t, el.version), fcType, el.data)
 		if err != nil {
-			return fmt.Errorf("Encrypt : %w", err)
+			return nil, newError(ERR_IO, "CreateBackup", fmt.Errorf("Encrypt : %w", err))
 		}
 	}
-	return nil
-}
 
-func initBackup() {
-	backupRegistry = make(map[int]Backup)
+	return s.pendingStorageIndex(), nil
 }
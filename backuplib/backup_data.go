@@ -11,125 +11,143 @@ import (
 )
 
 type BackupData struct {
-	kOp              []byte         // Key. Assumed it is derived from a password
-	wallet           *WalletConfig  // Simulated wallet configuration parameters
-	secretShares     *Shares        // Shares. Needed in case we want to continue distributing them
-	secretCfg        *shamir.Shamir // Configuration osf secret sharing
-	secretCustodians *Custodians    // Info on custodians so that we can retrieve it later
-	pK               *PrivateKeys   // Identity private keys
-	storage          []db.KV        // Identity storage
+	kOp              []byte          // Key. Assumed it is derived from a password
+	wallet           *WalletConfig   // Simulated wallet configuration parameters
+	secretShares     *Shares         // Shares. Needed in case we want to continue distributing them
+	secretCfg        SecretSharing   // Configuration osf secret sharing
+	secretCustodians *Custodians     // Info on custodians so that we can retrieve it later
+	policy           *shamir.Policy  // Access policy describing which custodians can recover the secret
+	pK               *PrivateKeys    // Identity private keys
+	storage          []db.KV         // Identity storage. Base of storage chain
+	storageID        []byte          // Identifier of storage
+	storageChain     []StorageBackup // Incremental backups imported on storage
 }
 
-var dataBackup BackupData
-
 // Getters/Setters
-func GetkOp() []byte {
-	return dataBackup.kOp
+func (s *BackupSession) GetkOp() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.kOp
 }
 
-func SetkOp(kOp []byte) {
-	dataBackup.kOp = make([]byte, len(kOp))
-	copy(dataBackup.kOp, kOp)
+func (s *BackupSession) SetkOp(kOp []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.kOp = clone(kOp)
 }
 
-func GetWallet() *WalletConfig {
-	return dataBackup.wallet
+func (s *BackupSession) GetWallet() *WalletConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.wallet
 }
 
-func SetWallet(data *WalletConfig) {
-	dataBackup.wallet = data
+func (s *BackupSession) SetWallet(data *WalletConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.wallet = data
 }
 
-func GetShares() *Shares {
-	return dataBackup.secretShares
+func (s *BackupSession) GetShares() *Shares {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.secretShares
 }
 
-func SetShares(data *Shares) {
-	dataBackup.secretShares = data
+func (s *BackupSession) SetShares(data *Shares) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.secretShares = data
 }
 
-func GetSecretCfg() *Secret {
-	secretCfg := Secret{
-		shamir.Shamir{
-			MaxShares:   dataBackup.secretCfg.GetMaxShares(),
-			MinShares:   dataBackup.secretCfg.GetMinShares(),
-			ElementType: dataBackup.secretCfg.GetElType(),
-		},
-	}
-	return &secretCfg
+func (s *BackupSession) GetSecretCfg() *Secret {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Secret{s.data.secretCfg}
 }
 
-func GetSecretCfgOriginal() *shamir.Shamir {
-	return dataBackup.secretCfg
+func (s *BackupSession) GetSecretCfgOriginal() SecretSharing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.secretCfg
 }
 
-func SetSecretCfg(data *Secret) {
+func (s *BackupSession) SetSecretCfg(data *Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if data != nil {
-		secretCfg := shamir.Shamir{
-			MaxShares:   data.GetMaxShares(),
-			MinShares:   data.GetMinShares(),
-			ElementType: data.GetElType(),
-		}
-		dataBackup.secretCfg = &secretCfg
+		s.data.secretCfg = data.SecretSharing
 	} else {
-		dataBackup.secretCfg = nil
+		s.data.secretCfg = nil
 	}
 }
 
-func GetCustodians() *Custodians {
-	return dataBackup.secretCustodians
+func (s *BackupSession) GetCustodians() *Custodians {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.secretCustodians
 }
 
-func SetCustodians(data *Custodians) {
-	dataBackup.secretCustodians = data
+func (s *BackupSession) SetCustodians(data *Custodians) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.secretCustodians = data
 }
 
-func GetPrivateKeys() *PrivateKeys {
-	return dataBackup.pK
+func (s *BackupSession) GetPolicy() *shamir.Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.policy
 }
 
-func SetPrivateKeys(data *PrivateKeys) {
-	if data == nil {
-		dataBackup.pK = nil
-	} else {
-		PK := make([]babyjub.PrivateKey, len(data.PK))
-		for idx, pk := range data.PK {
-			PK[idx] = pk
+// Set access policy. Policy needs to match the secret sharing configuration and the custodians
+// already added (see AddCustodian). nil removes the policy
+func (s *BackupSession) SetPolicy(data *shamir.Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if data != nil {
+		err := checkPolicy(data, s.data.secretCfg, s.data.secretCustodians)
+		if err != nil {
+			return newError(ERR_INVALID_ARG, "SetPolicy", err)
 		}
-		privateKey := PrivateKeys{PK: PK}
-		dataBackup.pK = &privateKey
 	}
+	s.data.policy = data
+	return nil
 }
 
-func GetStorage() []db.KV {
-	return dataBackup.storage
+func (s *BackupSession) GetPrivateKeys() *PrivateKeys {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.pK
 }
 
-func SetStorage(data []db.KV) {
-	copy(dataBackup.storage, data)
+func (s *BackupSession) SetPrivateKeys(data *PrivateKeys) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.pK = clonePrivateKeys(data)
 }
 
-func Init(pass []byte, folder string) {
-
-	// init aux data in backup structure
-	SetWallet(initWalletConfig())
-
-	// init Secret Sharing
-	initSecretCfg()
-
-	// init Secret Shares
-	initSecretShares()
-
-	// init backup registry
-	initBackup()
-
-	// init Encoding
-	initEncoding()
-
-	// init Custodians
-	initCustodians()
+func (s *BackupSession) GetStorage() []db.KV {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.storage
+}
 
-	// init identity
-	initIdentity(pass, folder)
+func (s *BackupSession) SetStorage(data []db.KV) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.storage = append([]db.KV(nil), data...)
+	s.data.storageID = hashStorage(data).id()
+	s.data.storageChain = nil
+}
 
+func clonePrivateKeys(data *PrivateKeys) *PrivateKeys {
+	if data == nil {
+		return nil
+	}
+	PK := make([]babyjub.PrivateKey, len(data.PK))
+	for idx, pk := range data.PK {
+		PK[idx] = pk
+	}
+	return &PrivateKeys{PK: PK}
 }
@@ -1,53 +1,37 @@
 package backuplib
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-backup/ff"
+	fc "github.com/iden3/go-backup/filecrypt"
+	"github.com/iden3/go-backup/shamir"
 	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/iden3-mobile/go/iden3mobile"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
-var oldKOp []byte
-var oldWallet WalletConfig
-var oldShares Shares
-var oldSecretCfg Secret
-var oldCustodians Custodians
-var oldPK PrivateKeys
-var oldStorage []db.KV
-
-func copyBackupData() {
-	oldKOp = GetkOp()
-	oldWallet = *GetWallet()
-	oldShares = *GetShares()
-	oldSecretCfg = *GetSecretCfg()
-	oldCustodians = *GetCustodians()
-	oldPK = *GetPrivateKeys()
-	oldStorage = GetStorage()
-}
-
-func deleteBackupData() {
-	SetkOp(nil)
-	SetWallet(nil)
-	SetShares(nil)
-	SetSecretCfg(nil)
-	SetCustodians(nil)
-	SetPrivateKeys(nil)
-	SetStorage(nil)
-
-}
-
-func TestBackup(t *testing.T) {
+// Create a backup file with a new session. Backup file and custodian shares are stored in folder
+func createTestBackup(t *testing.T, folder string) *BackupSession {
 	// Generates Key. This is our Identity operational Key. I am assuming
 	// that the operational key is the one that enables us to regain the identity.
 	kOp := KeyOperational()
-	SetkOp(kOp)
 
-	// Create identity and initialize modules
-	Init(kOp, "")
+	// Create identity and initialize session
+	session, err := NewBackupSession(kOp, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.SetkOp(kOp)
 
 	// Generate Shares of our operational Key
-	GenerateShares(kOp)
+	checkOK(t, session.GenerateShares(kOp))
 
 	// Define Custodians -> Simulates the process of inviting a trusted entity to
 	//   become our custodian, and sending N shares of the key upon acceptance of the invitation. In this example
@@ -59,61 +43,125 @@ func TestBackup(t *testing.T) {
 	//   how to contact them in the future.
 
 	// assign first share
-	AddCustodian("Pedrito", QR_DIR, QR, 0, 1)
+	session.AddCustodian("Pedrito", folder, QR, 0, 1)
 	// assign second share
-	AddCustodian("Faustino", QR_DIR, QR, 1, 1)
+	session.AddCustodian("Faustino", folder, QR, 1, 1)
 	// assign third and fourth share
-	AddCustodian("Sara Baras", QR_DIR, NONE, 2, 2)
+	session.AddCustodian("Sara Baras", folder, NONE, 2, 2)
 	// assign 5th share
-	AddCustodian("Sergio", QR_DIR, QR, 4, 1)
+	session.AddCustodian("Sergio", folder, QR, 4, 1)
 	// assign 6th share
-	AddCustodian("Raul", QR_DIR, QR, 5, 1)
+	session.AddCustodian("Raul", folder, QR, 5, 1)
+
+	// Define access policy. Any 4 shares recover the key : Sara Baras holds 2 shares
+	policy := shamir.NewThresholdPolicy(MIN_N_SHARES,
+		shamir.NewCustodianPolicy("Pedrito", 1),
+		shamir.NewCustodianPolicy("Faustino", 1),
+		shamir.NewCustodianPolicy("Sara Baras", 2),
+		shamir.NewCustodianPolicy("Sergio", 1),
+		shamir.NewCustodianPolicy("Raul", 1))
+	checkOK(t, session.SetPolicy(&policy))
 
 	// Define which information is included in Backup file. Contents of the backup are not important right
 	// now. It is just to show how easy it is to build the backup file.
-	// At this point, the buildup is static (I need to have a switch case in AddToBackup with all possible
-	// data stucture types that can be added to the backup, but there should be a more dynamic way...)
+	// Every type of data is exported by the BackupSource registered with that type (see source.go).
+	// Applications can register their own sources.
 	// Add wallet configuration
-	AddToBackup(WALLET_CONFIG, ENCRYPT)
+	checkOK(t, session.AddToBackup(WALLET_CONFIG, ENCRYPT))
 	// Add Custodian information (contact details) -> unencrypted
-	AddToBackup(CUSTODIAN, DONT_ENCRYPT)
+	checkOK(t, session.AddToBackup(CUSTODIAN, DONT_ENCRYPT))
 	// Add SSharing info. We need Prime number and protocol used (Shamir) -> unencrypted
-	AddToBackup(SSHARING, DONT_ENCRYPT)
+	checkOK(t, session.AddToBackup(SSHARING, DONT_ENCRYPT))
 	// Add Shares. We heed to keep a list of at least outstanding shares in case
 	//  we want to redistribute in the future. in this example I keep all for simplicity.
-	AddToBackup(SHARES, ENCRYPT)
+	checkOK(t, session.AddToBackup(SHARES, ENCRYPT))
 	// Add KeyStore
-	AddToBackup(PKEYS, ENCRYPT)
+	checkOK(t, session.AddToBackup(PKEYS, ENCRYPT))
 	// Add Storage
-	AddToBackup(STORAGE, ENCRYPT)
+	checkOK(t, session.AddToBackup(STORAGE, ENCRYPT))
+	// Add access policy -> unencrypted
+	checkOK(t, session.AddToBackup(POLICY, DONT_ENCRYPT))
 
 	// Generate Backupfile -> Here we select the Key derivation algo and the encryption mechanism used
 	//  for encrypted sections. Also not, that we can mix encrypted and non-encrpyted information in the
 	// same baclup file
-	err := CreateBackup(BACKUP_FILE)
+	err = session.CreateBackup(folder + "backup.bk")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	return session
+}
 
-	// We lost our phone.  We need to reinstall wallet in new phone and retrieve backup
-	// from cloud services. Copy old data to check backup is done correctly and delete
-	copyBackupData()
-	deleteBackupData()
+func checkOK(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustGenerateKey(t *testing.T, session *BackupSession) []byte {
+	t.Helper()
+	key, err := session.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// Temporary folder for backup files. Returned name ends with path separator
+func tmpFolder(t *testing.T) string {
+	folder, err := ioutil.TempDir("", "backuplib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return folder + "/"
+}
+
+func TestBackup(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	session := createTestBackup(t, folder)
+	defer session.Close()
+
+	if session.GetNCustodians() != 5 || session.GetNShares() != MAX_N_SHARES {
+		t.Error("Backup Custodians/Shares .... KO")
+	}
+	for idx := 0; idx < session.GetNCustodians(); idx++ {
+		if _, err := os.Stat(session.GetCustodian(idx).Fname); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(folder + "backup.bk"); err != nil {
+		t.Error(err)
+	}
 }
 
 func TestRestore(t *testing.T) {
-	Init(nil, "")
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	// We lost our phone.  We need to reinstall wallet in new phone and retrieve backup
+	// from cloud services.
+	session, err := NewBackupSession(nil, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
 
 	// Decode unencrypted backup file as it may contain some info
 	// (custodian contact info, genesis id, sharing)
 
 	// During this fist stage, we only recover nonencrypted data as we still don't have the key.
-	err := DecodeUnencrypted(BACKUP_FILE)
+	err = session.DecodeUnencrypted(folder + "backup.bk")
 	if err != nil {
 		t.Error(err)
 	}
 
-	res := checkEqual(oldCustodians, *GetCustodians())
+	res := checkEqual(*original.GetCustodians(), *session.GetCustodians())
 	if res {
 		fmt.Println("Retrieved Custodians .... OK")
 	} else {
@@ -122,29 +170,41 @@ func TestRestore(t *testing.T) {
 
 	// Retreive sharing info -> Finite Field information and protocol (Shamir's secret sharing) required to
 	//    regenerate the KEY. It is unencrypted
-	res = checkEqual(oldSecretCfg, *GetSecretCfg())
+	res = checkEqual(*original.GetSecretCfg(), *session.GetSecretCfg())
 	if res {
 		fmt.Println("Retrieved Sharing Conf .... OK")
 	} else {
-		fmt.Println(oldSecretCfg, *GetSecretCfg())
+		fmt.Println(*original.GetSecretCfg(), *session.GetSecretCfg())
 		t.Error("Retrieved Sharing Conf .... KO")
 	}
 
+	// Retrieve access policy -> tells us which custodians we need to contact
+	res = checkEqual(*original.GetPolicy(), *session.GetPolicy())
+	if res {
+		fmt.Println("Retrieved Policy .... OK")
+	} else {
+		t.Error("Retrieved Policy .... KO")
+	}
+	needed, err := session.NeededCustodians([]string{"Pedrito"})
+	if err != nil || len(needed) != 2 {
+		t.Error("Needed Custodians .... KO")
+	}
+
 	// Contact custodians and retrieve shares
 	// We simulate here that somehow we contact the custodians using the info in the backup
 	//    Out of the 5 custodians we had, we ony contacted   three.
 	// The custodian then sends the share in P2P channel. In our case, we assume that we are
 	//  face to face and the custodian gfenerates a QR that we can scan.
-	custodians := GetCustodians()
+	custodians := session.GetCustodians()
 	for _, custodian := range custodians.Data {
-		ScanQRShare(custodian.Fname)
+		checkOK(t, session.ScanQRShare(custodian.Fname))
 	}
 
 	// Generate Key
 	//   Using the collected shares, regenerate Key
-	kOp := GenerateKey()
-	SetkOp(kOp)
-	res = checkEqual(oldKOp, GetkOp())
+	kOp := mustGenerateKey(t, session)
+	session.SetkOp(kOp)
+	res = checkEqual(original.GetkOp(), session.GetkOp())
 	if res {
 		fmt.Println("Retrieved kOp .... OK")
 	} else {
@@ -154,46 +214,435 @@ func TestRestore(t *testing.T) {
 	// Decode and Decrypt backup file -> With the generated kOp, try to decrypt file.
 	//   kOp is not used directly. We use a Key Derivation Function. All parameters for this
 	//   function are public (except for the Key) and are in the encryption block header
-	DecodeEncrypted(BACKUP_FILE)
+	err = session.DecodeEncrypted(folder + "backup.bk")
+	if err != nil {
+		t.Error(err)
+	}
 
 	// With the decrpyted and decoded information, retrieve all information we stored and check
 	// if it is equal than the original
-	res = checkEqual(oldWallet, *GetWallet())
+	res = checkEqual(*original.GetWallet(), *session.GetWallet())
 	if res {
 		fmt.Println("Retrieved Wallet .... OK")
 	} else {
 		t.Error("Retrieved Wallet .... KO")
 	}
 
-	res = checkEqual(oldShares, *GetShares())
+	res = checkEqual(*original.GetShares(), *session.GetShares())
 	if res {
 		fmt.Println("Retrieved Shares .... OK")
 	} else {
 		t.Error("Retrieved Shares .... KO")
 	}
 
-	res = checkEqual(oldPK, *GetPrivateKeys())
+	res = checkEqual(*original.GetPrivateKeys(), *session.GetPrivateKeys())
 	if res {
 		fmt.Println("Retrieved Private Keys .... OK")
 	} else {
 		t.Error("Retrieved Private Keys .... KO")
 	}
 
-	res = checkEqual(oldStorage, GetStorage())
+	res = checkEqual(original.GetStorage(), session.GetStorage())
 	if res {
 		fmt.Println("Retrieved Storage .... OK")
 	} else {
 		t.Error("Retrieved Storage .... KO")
 	}
 
-	// Last step is to restore identity
-	_, err = RestoreIdentity("", keystore.StandardKeyStoreParams)
+	// Last step is to restore identity. Restored identity is kept when session is closed
+	idFolder := folder + "restored/"
+	checkOK(t, os.Mkdir(idFolder, 0700))
+	id, err := session.RestoreIdentity(idFolder, keystore.StandardKeyStoreParams)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	id.Stop()
+	kOp = session.GetkOp()
+	session.Close()
+	dirs, _ := filepath.Glob(idFolder + IDENTITY_MAIN_STORAGE + "*")
+	if len(dirs) != 1 {
+		t.Fatal("Restored identity removed", dirs)
+	}
+	id, err = iden3mobile.NewIdentityLoad(dirs[0], string(kOp), WEB3URL, HOLDER_TICKET_PERIOD, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id.Stop()
+}
+
+// Sessions without identity backed up concurrently don't share state
+func TestConcurrentSessions(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	var wg sync.WaitGroup
+	sessions := make([]*BackupSession, 4)
+	for idx := range sessions {
+		session, err := NewBackupSession(nil, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer session.Close()
+		sessions[idx] = session
+
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			kOp := session.KeyOperational()
+			session.SetkOp(kOp)
+			errs := []error{session.GenerateShares(kOp)}
+			for n := 0; n < MIN_N_SHARES; n++ {
+				errs = append(errs, session.AddCustodian(fmt.Sprintf("custodian-%d-%d", idx, n), folder, NONE, n, 1))
+			}
+			errs = append(errs,
+				session.AddToBackup(WALLET_CONFIG, ENCRYPT),
+				session.AddToBackup(CUSTODIAN, DONT_ENCRYPT),
+				session.AddToBackup(SSHARING, DONT_ENCRYPT),
+				session.AddToBackup(SHARES, ENCRYPT),
+				session.CreateBackup(fmt.Sprintf("%sbackup-%d.bk", folder, idx)))
+			for _, err := range errs {
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	for idx, original := range sessions {
+		session, _ := NewBackupSession(nil, folder)
+		fname := fmt.Sprintf("%sbackup-%d.bk", folder, idx)
+		err := session.DecodeUnencrypted(fname)
+		if err != nil || session.GetNCustodians() != MIN_N_SHARES {
+			t.Fatal("Retrieved Custodians .... KO", err)
+		}
+		for n := 0; n < session.GetNCustodians(); n++ {
+			checkOK(t, session.ScanQRShare(session.GetCustodian(n).Fname))
+		}
+		session.SetkOp(mustGenerateKey(t, session))
+		if !checkEqual(original.GetkOp(), session.GetkOp()) {
+			t.Error("Retrieved kOp .... KO", idx)
+		}
+		err = session.DecodeEncrypted(fname)
+		if err != nil {
+			t.Error(err)
+		}
+		if !checkEqual(*original.GetWallet(), *session.GetWallet()) {
+			t.Error("Retrieved Wallet .... KO", idx)
+		}
+	}
+}
+
+// Application settings backed up with a custom source
+type testSettings struct {
+	Values map[string]string
+}
+
+var exportedSettings = &testSettings{Values: map[string]string{"theme": "dark", "lang": "es"}}
+var importedSettings *testSettings
+
+func TestBackupSource(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	settingsType := USER_TYPES
+	src := NewBackupSource("settings", 2, ENCRYPT, []interface{}{&testSettings{}},
+		func(s *BackupSession) (interface{}, error) {
+			return exportedSettings, nil
+		},
+		func(s *BackupSession, data interface{}, version int) error {
+			settings, ok := data.(*testSettings)
+			if !ok || version != 2 {
+				return errors.New("Invalid Settings Format")
+			}
+			importedSettings = settings
+			return nil
+		})
+	if GetBackupSource(settingsType) == nil {
+		err := RegisterBackupSource(settingsType, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if RegisterBackupSource(settingsType, src) == nil || RegisterBackupSource(settingsType+1, src) == nil {
+		t.Error("Duplicated backup source accepted")
+	}
+	if RegisterBackupSource(MAX_TYPE+1, NewBackupSource("other", 1, ENCRYPT, nil, nil, nil)) == nil {
+		t.Error("Invalid backup source type accepted")
+	}
+
+	session, _ := NewBackupSession(nil, folder)
+	kOp := session.KeyOperational()
+	session.SetkOp(kOp)
+	checkOK(t, session.GenerateShares(kOp))
+	session.AddCustodian("Pedrito", folder, NONE, 0, MIN_N_SHARES)
+	checkOK(t, session.AddToBackup(CUSTODIAN, SOURCE_POLICY))
+	checkOK(t, session.AddToBackup(SSHARING, SOURCE_POLICY))
+	checkOK(t, session.AddToBackup(settingsType, SOURCE_POLICY))
+	err := session.CreateBackup(folder + "backup.bk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// settings are encrypted
+	importedSettings = nil
+	restored, _ := NewBackupSession(nil, folder)
+	err = restored.DecodeUnencrypted(folder + "backup.bk")
+	if err != nil || importedSettings != nil {
+		t.Error("Retrieved Unencrypted Settings .... KO", err)
+	}
+	checkOK(t, restored.ScanQRShare(restored.GetCustodian(0).Fname))
+	restored.SetkOp(mustGenerateKey(t, restored))
+	err = restored.DecodeEncrypted(folder + "backup.bk")
+	if err != nil || importedSettings == nil || !checkEqual(*exportedSettings, *importedSettings) {
+		t.Error("Retrieved Settings .... KO", err)
+	}
+
+	// wrong key
+	restored.SetkOp(restored.KeyOperational())
+	if restored.DecodeEncrypted(folder+"backup.bk") == nil {
+		t.Error("Backup decrypted with wrong key")
+	}
+}
+
+// Backups created before versioned tags are imported as version 0
+func TestLegacyBackup(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	session, _ := NewBackupSession(nil, folder)
+	kOp := session.KeyOperational()
+	session.SetkOp(kOp)
+	checkOK(t, session.GenerateShares(kOp))
+	session.AddCustodian("Pedrito", folder, NONE, 0, MIN_N_SHARES)
+
+	fname := folder + "legacy.bk"
+	fileCrypt, err := fc.New(3, fname, kOp, kOp, fc.FC_KEY_T_PBKDF2)
+	if err != nil {
+		t.Fatal(err)
 	}
+	fileCrypt.AddBlock([]byte("2"), fc.FC_CLEAR, session.GetCustodians())
+	fileCrypt.AddBlock([]byte("3"), fc.FC_CLEAR, &shamir.Shamir{MinShares: MIN_N_SHARES, MaxShares: MAX_N_SHARES, ElementType: PRIME})
+	fileCrypt.AddBlock([]byte("1"), fc.FC_GCM, session.GetWallet())
 
-	for _, dir := range rmDirs {
-		os.RemoveAll(dir)
+	restored, _ := NewBackupSession(nil, folder)
+	err = restored.DecodeUnencrypted(fname)
+	if err != nil || !checkEqual(*session.GetCustodians(), *restored.GetCustodians()) {
+		t.Fatal("Retrieved Legacy Custodians .... KO", err)
+	}
+	checkOK(t, restored.ScanQRShare(restored.GetCustodian(0).Fname))
+	restored.SetkOp(mustGenerateKey(t, restored))
+	err = restored.DecodeEncrypted(fname)
+	if err != nil || !checkEqual(*session.GetWallet(), *restored.GetWallet()) {
+		t.Error("Retrieved Legacy Wallet .... KO", err)
+	}
+
+	// newer schema versions are rejected
+	fname = folder + "future.bk"
+	fileCrypt, _ = fc.New(1, fname, kOp, kOp, fc.FC_KEY_T_PBKDF2)
+	fileCrypt.AddBlock(sourceTag(WALLET_CONFIG, 99), fc.FC_GCM, session.GetWallet())
+	if restored.DecodeEncrypted(fname) == nil {
+		t.Error("Unsupported schema version accepted")
+	}
+
+	for _, tag := range []string{"1.2.3", "a", "1.b"} {
+		if _, _, err := parseSourceTag([]byte(tag)); err == nil {
+			t.Error("Invalid tag accepted", tag)
+		}
+	}
+}
+
+// Backup file and custodian shares created before shares were stored in the scheme encoding
+func TestBaselineBackup(t *testing.T) {
+	dir := "testdata/baseline/"
+	kOpHex, _ := ioutil.ReadFile(dir + "kop.hex")
+	kOp, _ := hex.DecodeString(strings.TrimSpace(string(kOpHex)))
+
+	restored, _ := NewBackupSession(nil, "")
+	checkOK(t, restored.DecodeUnencrypted(dir+"backup.bk"))
+	if restored.GetNCustodians() == 0 || restored.GetSecretCfg().GetElType() != PRIME {
+		t.Fatal("Retrieved Baseline Custodians .... KO")
+	}
+	for _, fname := range []string{"qr-Pedrito.png", "qr-Faustino.png", "byte-Sara.dat"} {
+		checkOK(t, restored.ScanQRShare(dir+fname))
+	}
+	if restored.GetNShares() != MIN_N_SHARES || !checkEqual(kOp, mustGenerateKey(t, restored)) {
+		t.Fatal("Retrieved Baseline kOp .... KO")
+	}
+
+	restored.SetkOp(kOp)
+	checkOK(t, restored.DecodeEncrypted(dir+"backup.bk"))
+	if restored.GetNShares() != MAX_N_SHARES || !checkEqual(kOp, mustGenerateKey(t, restored)) {
+		t.Error("Retrieved Baseline Shares .... KO")
+	}
+	if restored.GetWallet() == nil || len(restored.GetWallet().Config) == 0 {
+		t.Error("Retrieved Baseline Wallet .... KO")
+	}
+}
+
+// Package level API operates on the default session
+func TestDefaultSession(t *testing.T) {
+	// package level API can be used before Init
+	defaultSession = newDefaultSession()
+	checkOK(t, AddToBackup(WALLET_CONFIG, ENCRYPT))
+	if GetNShares() != 0 || GetNCustodians() != 0 || GetShare(0) != nil {
+		t.Error("Default session before Init .... KO")
+	}
+	checkErr(t, AddCustodian("Pedrito", "", NONE, 0, 1), ErrInvalidArg)
+
+	checkOK(t, Init(nil, ""))
+	defer DefaultSession().Close()
+
+	kOp := KeyOperational()
+	SetkOp(kOp)
+	checkOK(t, GenerateShares(kOp))
+	if GetNShares() != MAX_N_SHARES || GetNCustodians() != 0 {
+		t.Error("Default session Shares .... KO")
+	}
+	key, err := GenerateKey()
+	if err != nil || !checkEqual(kOp, key) {
+		t.Error("Default session kOp .... KO")
 	}
 
+	// independent sessions don't modify default session
+	session, _ := NewBackupSession(nil, "")
+	checkOK(t, session.GenerateShares(session.KeyOperational()))
+	if !checkEqual(kOp, mustGenerateKey(t, DefaultSession())) || checkEqual(kOp, mustGenerateKey(t, session)) {
+		t.Error("Default session isolation .... KO")
+	}
+}
+
+// Secret sharing scheme where every share is a copy of the secret
+type replicatedSharing struct {
+	cfg SecretSharingCfg
+}
+
+func newReplicatedSharing(cfg *SecretSharingCfg) (SecretSharing, error) {
+	return replicatedSharing{cfg: *cfg}, nil
+}
+
+func (r replicatedSharing) GenerateShares(secret ff.Element) ([][]byte, error) {
+	shares := make([][]byte, r.cfg.MaxShares)
+	for idx := range shares {
+		shares[idx] = secret.ToBytesLE()
+	}
+	return shares, nil
+}
+
+func (r replicatedSharing) GenerateSecret(shares [][]byte) (ff.Element, error) {
+	secret, _ := ff.NewElement(r.cfg.ElementType)
+	return secret.SetBytesLE(shares[0])
+}
+
+func (r replicatedSharing) VerifyShare(share []byte) error {
+	_, err := r.GenerateSecret([][]byte{share})
+	return err
+}
+
+func (r replicatedSharing) Scheme() string    { return r.cfg.Scheme }
+func (r replicatedSharing) GetMinShares() int { return r.cfg.MinShares }
+func (r replicatedSharing) GetMaxShares() int { return r.cfg.MaxShares }
+func (r replicatedSharing) GetElType() int    { return r.cfg.ElementType }
+
+func TestSecretSharingScheme(t *testing.T) {
+	cfg := &SecretSharingCfg{Scheme: shamir.SCHEME_ID, MinShares: 2, MaxShares: 3, ElementType: PRIME}
+	secretSharing, err := NewSecretSharing(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkEqual(cfg, describeSecretSharing(secretSharing)) {
+		t.Error("Secret Sharing description .... KO")
+	}
+
+	// legacy SSHARING block
+	legacy := retrieveSSharing([]interface{}{&shamir.Shamir{MinShares: 2, MaxShares: 3, ElementType: PRIME}})
+	if !checkEqual(cfg, legacy) {
+		t.Error("Legacy Secret Sharing Conf .... KO")
+	}
+
+	_, err = NewSecretSharing(&SecretSharingCfg{Scheme: "unknown", MinShares: 2, MaxShares: 3})
+	if err == nil {
+		t.Error("Unknown Secret Sharing scheme accepted")
+	}
+
+	// schemes can be registered concurrently. Shares are opaque to backup
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if err := RegisterSecretSharing(fmt.Sprintf("replicated-%d", n), newReplicatedSharing); err != nil {
+				t.Error(err)
+			}
+		}(n)
+	}
+	wg.Wait()
+	checkErr(t, RegisterSecretSharing("replicated-2", newReplicatedSharing), ErrInvalidArg)
+	checkErr(t, RegisterSecretSharing(shamir.SCHEME_ID, newReplicatedSharing), ErrInvalidArg)
+	checkErr(t, RegisterSecretSharing("nil", nil), ErrInvalidArg)
+	secretSharing, err = NewSecretSharing(&SecretSharingCfg{Scheme: "replicated-2", MinShares: 1, MaxShares: 3, ElementType: PRIME})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicated, _ := NewBackupSession(nil, "")
+	replicated.SetSecretCfg(&Secret{secretSharing})
+	kOp := replicated.KeyOperational()
+	checkOK(t, replicated.GenerateShares(kOp))
+	if replicated.GetNShares() != 3 || !checkEqual(kOp, mustGenerateKey(t, replicated)) {
+		t.Error("Replicated Secret Sharing .... KO")
+	}
+
+	// operational key belongs to the configured field
+	secretSharing, _ = NewSecretSharing(&SecretSharingCfg{Scheme: shamir.SCHEME_ID, MinShares: 2, MaxShares: 3, ElementType: ff.FF_CURVE25519_L})
+	session, _ := NewBackupSession(nil, "")
+	session.SetSecretCfg(&Secret{secretSharing})
+	for n := 0; n < 8; n++ {
+		kOp = session.KeyOperational()
+		checkOK(t, session.GenerateShares(kOp))
+		if !checkEqual(kOp, mustGenerateKey(t, session)) {
+			t.Error("Operational key .... KO")
+		}
+	}
+}
+
+// Shares of every supported field are distributed, backed up and restored
+func TestSharesFields(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	for _, elType := range []int{ff.FF_BN256_FQ, ff.FF_BLS12381_FR, ff.FF_SECP256K1_N, ff.FF_CURVE25519_L} {
+		secretSharing, err := NewSecretSharing(&SecretSharingCfg{Scheme: shamir.SCHEME_ID, MinShares: 2, MaxShares: 3, ElementType: elType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, _ := NewBackupSession(nil, folder)
+		session.SetSecretCfg(&Secret{secretSharing})
+		kOp := session.KeyOperational()
+		session.SetkOp(kOp)
+		checkOK(t, session.GenerateShares(kOp))
+		checkOK(t, session.AddCustodian(fmt.Sprintf("qr-%d", elType), folder, QR, 0, 1))
+		checkOK(t, session.AddCustodian(fmt.Sprintf("raw-%d", elType), folder, NONE, 1, 1))
+		checkOK(t, session.AddToBackup(CUSTODIAN, DONT_ENCRYPT))
+		checkOK(t, session.AddToBackup(SSHARING, DONT_ENCRYPT))
+		checkOK(t, session.AddToBackup(SHARES, ENCRYPT))
+		fname := fmt.Sprintf("%sbackup-%d.bk", folder, elType)
+		checkOK(t, session.CreateBackup(fname))
+
+		restored, _ := NewBackupSession(nil, folder)
+		checkOK(t, restored.DecodeUnencrypted(fname))
+		if restored.GetSecretCfg().GetElType() != elType {
+			t.Error("Retrieved Secret Sharing Conf .... KO", elType)
+		}
+		checkOK(t, restored.ScanQRShare(restored.GetCustodian(0).Fname))
+		checkOK(t, restored.ScanQRShare(restored.GetCustodian(1).Fname))
+		key := mustGenerateKey(t, restored)
+		if !checkEqual(kOp, key) {
+			t.Error("Retrieved kOp .... KO", elType)
+		}
+		restored.SetkOp(key)
+		checkOK(t, restored.DecodeEncrypted(fname))
+		if !checkEqual(*session.GetShares(), *restored.GetShares()) {
+			t.Error("Retrieved Shares .... KO", elType)
+		}
+	}
 }
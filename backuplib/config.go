@@ -14,9 +14,10 @@ const (
 	N_ELEMENTS            = 1000 // used by walletcfg currently
 	MIN_N_SHARES          = 4
 	MAX_N_SHARES          = 10
-	PRIME                 = ff.FF_BN256_FP
+	PRIME                 = ff.FF_BN256_FP // any ff element type (FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L...)
 	BACKUP_FILE           = "../testdata/backup.bk"
 	QR_DIR                = "../testdata/"
+	QR_MODULE_SIZE        = -4 // QR image size adapts to share size. Every module is 4x4 pixels
 	PBKDF2_NITER          = 60000
 	PBKDF2_SALTLEN        = 12
 	PBKDF2_KEY            = fc.FC_KEY_T_PBKDF2
@@ -35,5 +36,3 @@ type config struct {
 	Web3Url            string `yaml:"web3Url"`
 	HolderTicketPeriod int    `yaml:"holderTicketPeriod"`
 }
-
-var c config
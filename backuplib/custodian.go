@@ -9,6 +9,7 @@ package backuplib
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	qrdec "github.com/druiz0992/goqr"
 	"github.com/iden3/go-backup/shamir"
 	qrgen "github.com/skip2/go-qrcode"
@@ -32,6 +33,7 @@ const (
 type Custodian struct {
 	Nickname string
 	NShares  int // number of shares provided
+	StartIdx int // index of first share provided
 	Fname    string
 }
 
@@ -39,112 +41,209 @@ type Custodians struct {
 	Data []Custodian
 }
 
-var SecretCustodians *Custodians
-
-func GetNCustodians() int {
-	custodians := GetCustodians()
-	return len(custodians.Data)
+func (s *BackupSession) GetNCustodians() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data.secretCustodians.Data)
 }
 
-func GetCustodian(n int) *Custodian {
-	custodians := GetCustodians()
-	if n < len(custodians.Data) {
-		return &custodians.Data[n]
+// Returns a copy of custodian n, or nil if n is out of range
+func (s *BackupSession) GetCustodian(n int) *Custodian {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	custodians := s.data.secretCustodians
+	if n >= 0 && n < len(custodians.Data) {
+		custodian := custodians.Data[n]
+		return &custodian
 	} else {
 		return nil
 	}
 }
 
+// Returns the custodians that still need to return their shares to satisfy the access policy,
+// given the nicknames of the custodians whose shares are already available
+func (s *BackupSession) NeededCustodians(available []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy := s.data.policy
+	if policy == nil {
+		return nil, newErrorf(ERR_INVALID_BACKUP, "NeededCustodians", "No access policy defined")
+	}
+	err := checkPolicy(policy, s.data.secretCfg, s.data.secretCustodians)
+	if err != nil {
+		return nil, newError(ERR_INVALID_BACKUP, "NeededCustodians", err)
+	}
+	return policy.Needed(available), nil
+}
+
+// Check access policy matches the shares distributed to custodians. Shares are generated with the
+// single threshold of the secret sharing configuration, so policy needs to be a weighted threshold
+// of custodians with the same threshold. Custodians in policy need to hold as many shares as their
+// weight, from disjoint ranges
+func checkPolicy(policy *shamir.Policy, secretCfg SecretSharing, custodians *Custodians) error {
+	err := policy.Validate()
+	if err != nil {
+		return err
+	}
+	if policy.IsLeaf() {
+		return errors.New("Policy root needs to be a threshold node")
+	}
+	weights := make(map[string]int, len(policy.Children))
+	total := 0
+	for _, child := range policy.Children {
+		if !child.IsLeaf() {
+			return errors.New("Nested policies not supported. Shares are generated with a single threshold")
+		}
+		if _, ok := weights[child.Custodian]; ok {
+			return errors.New("Duplicated custodian in policy : " + child.Custodian)
+		}
+		weights[child.Custodian] = child.Weight
+		total += child.Weight
+	}
+	if secretCfg == nil {
+		return errors.New("No Secret Sharing configuration")
+	}
+	if policy.Threshold != secretCfg.GetMinShares() || total > secretCfg.GetMaxShares() {
+		return fmt.Errorf("Policy threshold %d of %d shares doesn't match secret sharing configuration (%d of %d)",
+			policy.Threshold, total, secretCfg.GetMinShares(), secretCfg.GetMaxShares())
+	}
+	if custodians == nil {
+		return nil
+	}
+	for idx, custodian := range custodians.Data {
+		weight, ok := weights[custodian.Nickname]
+		if !ok {
+			return errors.New("Custodian not in policy : " + custodian.Nickname)
+		}
+		if weight != custodian.NShares {
+			return fmt.Errorf("Custodian %s holds %d shares, policy weight is %d", custodian.Nickname, custodian.NShares, weight)
+		}
+		for _, other := range custodians.Data[:idx] {
+			if custodian.StartIdx < other.StartIdx+other.NShares && other.StartIdx < custodian.StartIdx+custodian.NShares {
+				return fmt.Errorf("Custodians %s and %s hold the same shares", other.Nickname, custodian.Nickname)
+			}
+		}
+	}
+	return nil
+}
+
 // Add new Custodian and simulate the distribution of N shares
-func addCustodian(nickname, folder string, method int, shares []shamir.Share, startIdx, nshares int) error {
+func addCustodian(nickname, folder string, method int, shares [][]byte, startIdx, nshares int) (*Custodian, error) {
+	if startIdx < 0 || nshares <= 0 || startIdx+nshares > len(shares) {
+		return nil, newErrorf(ERR_INVALID_ARG, "AddCustodian", "Invalid share range")
+	}
 	// add info to custodian
 	newCustodian := Custodian{
 		Nickname: nickname,
 		NShares:  nshares,
+		StartIdx: startIdx,
 	}
 
-	// encode share information to stream of bytes.
-	sharesArray := make([]shamir.Share, 0)
-	sharesArray = append(sharesArray, shares[startIdx:startIdx+nshares]...)
+	// encode share information to stream of bytes. Shares are kept in the encoding of the
+	// secret sharing scheme
+	sharesArray := &Shares{Data: fromShares(shares[startIdx : startIdx+nshares])}
+
+	if method != QR && method != NONE {
+		return nil, newErrorf(ERR_INVALID_ARG, "AddCustodian", "Invalid Method to distriburt Shares")
+	}
+	shareBytes, err := encodeShareToByte(sharesArray, folder)
+	if err != nil {
+		return nil, newError(ERR_IO, "AddCustodian", err)
+	}
 
 	// generate QR
 	if method == QR {
-		shareByte := encodeShareToByte(sharesArray, folder)
 		qrfile := folder + "qr-" + nickname + ".png"
 		newCustodian.Fname = qrfile
-		err := qrgen.WriteFile(string(shareByte), qrgen.High, 256, qrfile)
-		if err == nil {
-			custodians := GetCustodians()
-			custodians.Data = append(custodians.Data, newCustodian)
-			SetCustodians(custodians)
+		err := qrgen.WriteFile(string(shareBytes), qrgen.High, QR_MODULE_SIZE, qrfile)
+		if err != nil {
+			return nil, newError(ERR_QR, "AddCustodian", err)
 		}
-		return err
-
-		// generate Raw data
-	} else if method == NONE {
-		shareBytes := encodeShareToByte(sharesArray, folder)
-		fname := folder + "byte-" + nickname + ".dat"
-		newCustodian.Fname = fname
-		file, _ := os.Create(fname)
-		file.Write(shareBytes)
-		file.Close()
-		custodians := GetCustodians()
-		custodians.Data = append(custodians.Data, newCustodian)
-		SetCustodians(custodians)
-		return nil
+		return &newCustodian, nil
+	}
 
-	} else {
-		return errors.New("Invalid Method to distriburt Shares")
+	// generate Raw data
+	fname := folder + "byte-" + nickname + ".dat"
+	newCustodian.Fname = fname
+	err = ioutil.WriteFile(fname, shareBytes, 0644)
+	if err != nil {
+		return nil, newError(ERR_IO, "AddCustodian", err)
 	}
+	return &newCustodian, nil
 }
 
-func initCustodians() {
+func initCustodians() *Custodians {
 	var custodians Custodians
 	custodiansData := make([]Custodian, 0)
 	custodians.Data = custodiansData
-	SetCustodians(&custodians)
+	return &custodians
 }
 
-func AddCustodian(nickname, folder string, method int, startIdx, nshares int) error {
-	sharesGo := toShares(GetShares())
+func (s *BackupSession) AddCustodian(nickname, folder string, method int, startIdx, nshares int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sharesGo := toShares(s.data.secretShares)
+
+	// custodians need to match the access policy
+	if s.data.policy != nil {
+		custodians := &Custodians{Data: append(append([]Custodian{}, s.data.secretCustodians.Data...),
+			Custodian{Nickname: nickname, NShares: nshares, StartIdx: startIdx})}
+		err := checkPolicy(s.data.policy, s.data.secretCfg, custodians)
+		if err != nil {
+			return newError(ERR_INVALID_ARG, "AddCustodian", err)
+		}
+	}
 
-	err := addCustodian(nickname, folder, method, sharesGo, startIdx, nshares)
-	return err
+	newCustodian, err := addCustodian(nickname, folder, method, sharesGo, startIdx, nshares)
+	if err != nil {
+		return err
+	}
+	s.data.secretCustodians.Data = append(s.data.secretCustodians.Data, *newCustodian)
+	return nil
 }
 
-func ScanQRShare(fname string) {
-	rxSharesGo := scanQRShare(fname)
+// Scan share from custodian, and add it to session shares
+func (s *BackupSession) ScanQRShare(fname string) error {
+	rxSharesGo, err := scanQRShare(fname, s.GetSecretCfg())
+	if err != nil {
+		return err
+	}
 	rxShareMobile := Shares{Data: fromShares(rxSharesGo)}
 
-	shares := GetShares()
-	shares.Data = append(shares.Data, rxShareMobile.Data...)
-	SetShares(shares)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.secretShares.Data = append(s.data.secretShares.Data, rxShareMobile.Data...)
+	return nil
 }
 
-// Decode QR that includes a share, and return it a slice of maps with the index and the share
-func scanQRShare(fname string) []shamir.Share {
+// Decode QR or file that includes shares, and return the shares. Shares need to be valid shares
+// of sharingCfg
+func scanQRShare(fname string, sharingCfg *Secret) ([][]byte, error) {
 	var tmpFname string
 	if filepath.Ext(fname) == ".png" {
 		dirName := filepath.Dir(fname)
-		tmpFname = dirName + "/tmp_f"
 		imgdata, err := ioutil.ReadFile(fname)
 		if err != nil {
-			panic(err)
+			return nil, newError(ERR_IO, "ScanQRShare", err)
 		}
 
 		img, _, err := image.Decode(bytes.NewReader(imgdata))
 		if err != nil {
-			panic(err)
+			return nil, newError(ERR_QR, "ScanQRShare", err)
 		}
 		qrCodes, err := qrdec.Recognize(img)
 		if err != nil {
-			panic(err)
+			return nil, newError(ERR_QR, "ScanQRShare", err)
 		}
-		file, err := os.Create(tmpFname)
-		defer os.Remove(tmpFname)
+		if len(qrCodes) == 0 {
+			return nil, newErrorf(ERR_QR, "ScanQRShare", "No QR code found")
+		}
+		file, err := ioutil.TempFile(dirName, "tmp_f")
 		if err != nil {
-			panic(err)
+			return nil, newError(ERR_IO, "ScanQRShare", err)
 		}
+		tmpFname = file.Name()
+		defer os.Remove(tmpFname)
 		file.Write(qrCodes[0].Payload)
 		file.Close()
 
@@ -153,8 +252,19 @@ func scanQRShare(fname string) []shamir.Share {
 	}
 
 	// tmpFname is a file including the encoded share.
-	qrinfo := decode(tmpFname, nil)
-	share := retrieveShares(qrinfo)
+	qrinfo, err := decode(tmpFname, nil)
+	if err != nil {
+		return nil, newError(ERR_INVALID_SHARE, "ScanQRShare", err)
+	}
+	shares := retrieveShares(qrinfo, sharingCfg.GetElType())
+	if shares == nil {
+		return nil, newErrorf(ERR_INVALID_SHARE, "ScanQRShare", "Invalid shares Format")
+	}
+	for _, share := range shares {
+		if err := sharingCfg.VerifyShare(share); err != nil {
+			return nil, newError(ERR_INVALID_SHARE, "ScanQRShare", err)
+		}
+	}
 
-	return share
+	return shares, nil
 }
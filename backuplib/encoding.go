@@ -4,18 +4,16 @@ package backuplib
 
 import (
 	"bufio"
-	"encoding/gob"
-	"errors"
 	"fmt"
-	"github.com/iden3/go-backup/ff"
 	fc "github.com/iden3/go-backup/filecrypt"
 	"github.com/iden3/go-backup/shamir"
 	"github.com/iden3/go-iden3-core/db"
-	"github.com/iden3/go-iden3-crypto/babyjub"
+	"io"
+	"io/ioutil"
 	"os"
 )
 
-// Types of data we can include in the backup. Needed to register the data strcuture
+// Types of data included by the library in the backup. See BackupSource
 const (
 	START_TYPES = iota
 	WALLET_CONFIG
@@ -24,90 +22,56 @@ const (
 	SHARES
 	PKEYS
 	STORAGE
+	POLICY
 	NTYPES
-	// Add other possible data types that we need encoding
 )
 
-func initEncoding() {
-	for i := START_TYPES + 1; i < NTYPES; i++ {
-		encodeType(i)
-	}
-}
-
-// Register data strucrure
-func encodeType(dtype int) {
-	switch dtype {
-
-	case WALLET_CONFIG:
-		gob.Register(&WalletConfig{})
-
-	case CUSTODIAN:
-		gob.Register(&Custodians{})
-		var el []Custodian
-		gob.Register(el)
-
-	case SSHARING:
-		gob.Register(&shamir.Shamir{})
-
-	case SHARES:
-		el1, _ := ff.NewElement(PRIME)
-		var el2 shamir.Share
-		var el3 []shamir.Share
-		gob.Register(el1)
-		gob.Register(el2)
-		gob.Register(el3)
-
-	case PKEYS:
-		var el PrivateKeys
-		var el1 *babyjub.PrivateKey
-		gob.Register(&el)
-		gob.Register(el1)
-
-	case STORAGE:
-		var el db.KV
-		var el1 []db.KV
-		gob.Register(&el)
-		gob.Register(el1)
-
-	}
-}
-
 // Transform share encoding to []byte
-func encodeShareToByte(shares []shamir.Share, folder string) []byte {
-	tmpFname := folder + "share-tmp.dat"
-	encodeShare(shares, tmpFname)
+func encodeShareToByte(shares *Shares, folder string) ([]byte, error) {
+	// unique tmp file, so that concurrent sessions can share folder
+	tmpFile, err := ioutil.TempFile(folder, "share-tmp-*.dat")
+	if err != nil {
+		return nil, err
+	}
+	tmpFname := tmpFile.Name()
+	tmpFile.Close()
 	defer os.Remove(tmpFname)
-	data := readBinaryFile(tmpFname)
+	err = encodeShare(shares, tmpFname)
+	if err != nil {
+		return nil, err
+	}
 
-	return data
+	return readBinaryFile(tmpFname)
 }
 
 // Read file
-func readBinaryFile(tmpFname string) []byte {
+func readBinaryFile(tmpFname string) ([]byte, error) {
 	file, err := os.Open(tmpFname)
-	defer file.Close()
-
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer file.Close()
 
 	stats, err := file.Stat()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	var size int64 = stats.Size()
 	bytes := make([]byte, size)
 
 	bufr := bufio.NewReader(file)
-	_, err = bufr.Read(bytes)
+	_, err = io.ReadFull(bufr, bytes)
+	if err != nil {
+		return nil, err
+	}
 
-	return bytes
+	return bytes, nil
 }
 
 // Generate share blocks to distribure via secret sharing and return
 // filecrpyt bytestream
-func encodeShare(shares []shamir.Share, fname string) error {
+func encodeShare(shares *Shares, fname string) error {
 	// Key header -> no key (only for hmac)
 	//key := []byte("ThisIsMySecretKey")
 	fileCrypt, err := fc.New(1, fname, nil, nil, fc.FC_KEY_T_NOKEY)
@@ -122,83 +86,137 @@ func encodeShare(shares []shamir.Share, fname string) error {
 	return nil
 }
 
-// Decode and decrypt file using provided key
-func DecodeUnencrypted(fname string) error {
-	//key := []byte("ThisIsMySecretKey")
-	info := decode(fname, nil)
+// Decode unencrypted blocks of backup file
+func (s *BackupSession) DecodeUnencrypted(fname string) error {
+	// Access policy is optional
+	s.SetPolicy(nil)
 
-	rxCustodians := retrieveCustodians(info)
-
-	if rxCustodians == nil {
-		return errors.New("Invalid Custodian Format")
-	} else {
-		initCustodians()
-		custodians := GetCustodians()
-		custodians.Data = rxCustodians
-		SetCustodians(custodians)
+	imported, err := s.importBlocks("DecodeUnencrypted", fname, nil)
+	if err != nil {
+		return err
 	}
 
-	rxSecretCfg := retrieveSSharing(info)
-
-	if rxSecretCfg == nil {
-		return errors.New("Invalid Secret Sharing Format")
-	} else {
-		initSecretCfg()
-		secretCfg := GetSecretCfg()
-		secretCfg.MinShares = rxSecretCfg.GetMinShares()
-		secretCfg.MaxShares = rxSecretCfg.GetMaxShares()
-		secretCfg.ElementType = rxSecretCfg.GetElType()
-		SetSecretCfg(secretCfg)
+	// custodians and secret sharing configuration are needed to recover the key
+	if !imported[CUSTODIAN] {
+		return newErrorf(ERR_INVALID_BACKUP, "DecodeUnencrypted", "Invalid Custodian Format")
+	}
+	if !imported[SSHARING] {
+		return newErrorf(ERR_INVALID_BACKUP, "DecodeUnencrypted", "Invalid Secret Sharing Format")
 	}
 
 	return nil
 }
 
-func DecodeEncrypted(fname string) error {
-	key := GetkOp()
-	info := decode(fname, key)
+// Decode and decrypt backup file using session key
+func (s *BackupSession) DecodeEncrypted(fname string) error {
+	imported, err := s.importBlocks("DecodeEncrypted", fname, s.GetkOp())
+	if err != nil {
+		return err
+	}
+	if len(imported) == 0 {
+		return newErrorf(ERR_INVALID_BACKUP, "DecodeEncrypted", "Empty backup")
+	}
+	return nil
+}
 
-	retrievedWallet := retrieveWallet(info)
-	if retrievedWallet == nil {
-		return errors.New("Invalid Wallet Format")
-	} else {
-		SetWallet(retrievedWallet)
+// Decode blocks in backup file, and import them with the registered backup sources. Without key
+// only unencrypted blocks are imported. Blocks from unknown sources are ignored. Returns imported types
+func (s *BackupSession) importBlocks(op, fname string, key []byte) (map[int]bool, error) {
+	blocks, err := decodeBlocks(op, fname, key)
+	if err != nil {
+		return nil, err
 	}
 
-	retrievedShares := retrieveShares(info)
-	if retrievedShares == nil {
-		return errors.New("Invalid shares Format")
-	} else {
-		shares := GetShares()
-		shares.Data = fromShares(retrievedShares)
-		SetShares(shares)
+	imported := make(map[int]bool)
+	for _, b := range blocks {
+		err = b.src.Import(s, b.data, b.version)
+		if err != nil {
+			return nil, newError(ERR_INVALID_BACKUP, op, fmt.Errorf("Import %s : %w", b.src.Name(), err))
+		}
+		imported[b.t] = true
 	}
 
-	retrievedPrivateKeys := retrievePrivateKeys(info)
-	if retrievedPrivateKeys == nil {
-		return errors.New("Invalid Private Keys Format")
-	} else {
-		SetPrivateKeys(retrievedPrivateKeys)
+	return imported, nil
+}
+
+// Block decoded from backup file
+type decodedBlock struct {
+	t, version int
+	src        BackupSource
+	data       interface{}
+}
+
+// Decode blocks in backup file from registered backup sources. Without key, only unencrypted
+// blocks are decoded
+func decodeBlocks(op, fname string, key []byte) ([]decodedBlock, error) {
+	fileCrypt, err := openBackup(op, fname, key)
+	if err != nil {
+		return nil, err
 	}
 
-	retrievedStorage := retrieveStorage(info)
-	if retrievedStorage == nil {
-		return errors.New("Invalid Storage Format")
-	} else {
-		SetStorage(retrievedStorage)
+	blocks := make([]decodedBlock, 0)
+	for _, tag := range fileCrypt.ListTags() {
+		t, version, err := parseSourceTag(tag)
+		if err != nil {
+			continue
+		}
+		src := GetBackupSource(t)
+		if src == nil {
+			continue
+		}
+		data, err := decryptBlock(op, fileCrypt, tag, src, version, key)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			// encrypted block
+			continue
+		}
+		blocks = append(blocks, decodedBlock{t: t, version: version, src: src, data: data})
 	}
 
-	return nil
+	return blocks, nil
+}
 
+func openBackup(op, fname string, key []byte) (*fc.FileCrypt, error) {
+	if _, err := os.Stat(fname); err != nil {
+		return nil, newError(ERR_IO, op, err)
+	}
+	fileCrypt, err := fc.NewFromFile(key, fname)
+	if err != nil {
+		return nil, newError(ERR_INVALID_BACKUP, op, err)
+	}
+	return fileCrypt, nil
 }
 
-// Decode and decrypt file using provided key
-func decode(fname string, key []byte) []interface{} {
-	newFC, _ := fc.NewFromFile(key, fname)
-	results, _ := newFC.DecryptAll(key)
+// Decrypt block with tag exported by src with schema version. Without key, returns nil data for
+// encrypted blocks
+func decryptBlock(op string, fileCrypt *fc.FileCrypt, tag []byte, src BackupSource, version int, key []byte) (interface{}, error) {
+	if version > src.Version() {
+		return nil, newError(ERR_UNSUPPORTED, op, fmt.Errorf("Unsupported %s schema version %d", src.Name(), version))
+	}
+	data, err := fileCrypt.DecryptSingle(tag, key)
+	if err != nil {
+		if key == nil {
+			return nil, nil
+		}
+		return nil, newError(ERR_DECRYPT, op, fmt.Errorf("Decrypt %s : %w", src.Name(), err))
+	}
+	return data, nil
+}
 
-	return results
+// Decode and decrypt file using provided key
+func decode(fname string, key []byte) ([]interface{}, error) {
+	newFC, err := fc.NewFromFile(key, fname)
+	if err != nil {
+		return nil, fmt.Errorf("New FC : %w", err)
+	}
+	results, err := newFC.DecryptAll(key)
+	if err != nil {
+		return nil, fmt.Errorf("Decrypt : %w", err)
+	}
 
+	return results, nil
 }
 
 // Retrieve functions return a specific data type from a generic type
@@ -220,13 +238,31 @@ func retrieveCustodians(info []interface{}) []Custodian {
 }
 
 // retrieve Secret Sharing config data structure
-func retrieveSSharing(info []interface{}) *shamir.Shamir {
-	var r *shamir.Shamir
+func retrieveSSharing(info []interface{}) *SecretSharingCfg {
 	for _, el := range info {
 		switch el.(type) {
+		case *SecretSharingCfg:
+			return el.(*SecretSharingCfg)
 		case *shamir.Shamir:
-			r = el.(*shamir.Shamir)
-			return r
+			// legacy backups store shamir configuration
+			r := el.(*shamir.Shamir)
+			return &SecretSharingCfg{
+				Scheme:      shamir.SCHEME_ID,
+				MinShares:   r.GetMinShares(),
+				MaxShares:   r.GetMaxShares(),
+				ElementType: r.GetElType(),
+			}
+		}
+	}
+	return nil
+}
+
+// retrieve access policy
+func retrievePolicy(info []interface{}) *shamir.Policy {
+	for _, el := range info {
+		switch el.(type) {
+		case *shamir.Policy:
+			return el.(*shamir.Policy)
 		}
 	}
 	return nil
@@ -273,17 +309,17 @@ func retrieveStorage(info []interface{}) []db.KV {
 	return nil
 }
 
-// Retrieve shares data structure
-func retrieveShares(info []interface{}) []shamir.Share {
-	r := make([]shamir.Share, 0)
+// Retrieve shares data structure. Legacy shares are encoded as share envelopes of element
+// type elType
+func retrieveShares(info []interface{}, elType int) [][]byte {
 	for _, el := range info {
 		switch el.(type) {
-		case []shamir.Share:
-			r = el.([]shamir.Share)
-			return r
-		case shamir.Share:
-			r = append(r, el.(shamir.Share))
-			return r
+		case *Shares:
+			return toShares(el.(*Shares))
+		case []legacyShare:
+			return fromLegacyShares(el.([]legacyShare), elType)
+		case legacyShare:
+			return fromLegacyShares([]legacyShare{el.(legacyShare)}, elType)
 		}
 	}
 	return nil
@@ -0,0 +1,98 @@
+/*
+  Errors
+
+  Fallible operations return a *BackupError. Code classifies the failure so that mobile
+  layers can map it to a user message (gomobile binds BackupError as an exception class
+  with a getCode() method). Go code can use errors.Is with the ErrXXX values, or ErrorCode.
+*/
+
+package backuplib
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Error codes
+const (
+	ERR_NONE           = iota
+	ERR_UNKNOWN        // unclassified error
+	ERR_IO             // file could not be read or written
+	ERR_QR             // QR could not be generated or decoded
+	ERR_INVALID_ARG    // invalid argument (index out of range, unknown type...)
+	ERR_INVALID_SECRET // secret cannot be shared
+	ERR_NOT_ENOUGH     // not enough shares to recover secret
+	ERR_INVALID_SHARE  // share could not be decoded or verified
+	ERR_INVALID_BACKUP // backup file is corrupted or has unexpected contents
+	ERR_DECRYPT        // backup could not be decrypted. Wrong key
+	ERR_UNSUPPORTED    // backup created by an unsupported version
+	ERR_IDENTITY       // identity could not be created, exported or restored
+	ERR_SECRET_SHARING // invalid secret sharing configuration
+	ERR_SOURCE         // backup source failed
+	ERR_STORE          // backup store failed
+)
+
+type BackupError struct {
+	Code int    // error code
+	Op   string // operation that failed
+	Err  error  // underlying error
+}
+
+// Errors to compare with errors.Is
+var (
+	ErrIO            = &BackupError{Code: ERR_IO}
+	ErrQR            = &BackupError{Code: ERR_QR}
+	ErrInvalidArg    = &BackupError{Code: ERR_INVALID_ARG}
+	ErrInvalidSecret = &BackupError{Code: ERR_INVALID_SECRET}
+	ErrNotEnough     = &BackupError{Code: ERR_NOT_ENOUGH}
+	ErrInvalidShare  = &BackupError{Code: ERR_INVALID_SHARE}
+	ErrInvalidBackup = &BackupError{Code: ERR_INVALID_BACKUP}
+	ErrDecrypt       = &BackupError{Code: ERR_DECRYPT}
+	ErrUnsupported   = &BackupError{Code: ERR_UNSUPPORTED}
+	ErrIdentity      = &BackupError{Code: ERR_IDENTITY}
+	ErrSecretSharing = &BackupError{Code: ERR_SECRET_SHARING}
+	ErrSource        = &BackupError{Code: ERR_SOURCE}
+	ErrStore         = &BackupError{Code: ERR_STORE}
+)
+
+func newError(code int, op string, err error) error {
+	return &BackupError{Code: code, Op: op, Err: err}
+}
+
+// Error with message msg
+func newErrorf(code int, op, msg string) error {
+	return &BackupError{Code: code, Op: op, Err: errors.New(msg)}
+}
+
+func (e *BackupError) Error() string {
+	msg := "Backup error " + strconv.Itoa(e.Code)
+	if e.Op != "" {
+		msg = e.Op
+	}
+	if e.Err != nil {
+		msg += " : " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *BackupError) Unwrap() error {
+	return e.Err
+}
+
+// Errors with the same code match
+func (e *BackupError) Is(target error) bool {
+	t, ok := target.(*BackupError)
+	return ok && t.Code == e.Code
+}
+
+// Returns code of err. ERR_NONE if err is nil, ERR_UNKNOWN if err is not a BackupError
+func ErrorCode(err error) int {
+	if err == nil {
+		return ERR_NONE
+	}
+	var backupErr *BackupError
+	if errors.As(err, &backupErr) {
+		return backupErr.Code
+	}
+	return ERR_UNKNOWN
+}
@@ -0,0 +1,183 @@
+package backuplib
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/iden3/go-backup/ff"
+	"github.com/iden3/go-backup/shamir"
+	"github.com/iden3/go-iden3-core/keystore"
+)
+
+func checkErr(t *testing.T, err, expected error) {
+	t.Helper()
+	if !errors.Is(err, expected) {
+		t.Errorf("Expected error %d, obtained %v", ErrorCode(expected), err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	if ErrorCode(nil) != ERR_NONE || ErrorCode(errors.New("other")) != ERR_UNKNOWN {
+		t.Error("Unexpected error code")
+	}
+	err := newErrorf(ERR_DECRYPT, "DecodeEncrypted", "HMAC error")
+	if ErrorCode(err) != ERR_DECRYPT || !errors.Is(err, ErrDecrypt) || errors.Is(err, ErrIO) {
+		t.Error("Unexpected error code")
+	}
+	if err.Error() != "DecodeEncrypted : HMAC error" {
+		t.Error("Unexpected error message", err)
+	}
+}
+
+func TestSharesErrors(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+
+	// secret larger than field element
+	checkErr(t, session.GenerateShares(make([]byte, 64)), ErrInvalidSecret)
+
+	// no shares
+	_, err := session.GenerateKey()
+	checkErr(t, err, ErrNotEnough)
+
+	kOp := session.KeyOperational()
+	checkOK(t, session.GenerateShares(kOp))
+	if session.GetShare(-1) != nil || session.GetShare(MAX_N_SHARES) != nil {
+		t.Error("Share out of range returned")
+	}
+	// shares are returned by copy
+	share := session.GetShare(0)
+	share.Data[0] ^= 0xff
+	if bytes.Equal(session.GetShare(0).Data, share.Data) {
+		t.Error("Session share modified")
+	}
+
+	// invalid custodians
+	checkErr(t, session.AddCustodian("Pedrito", folder, QR, MAX_N_SHARES-1, 2), ErrInvalidArg)
+	checkErr(t, session.AddCustodian("Pedrito", folder, QR, -1, 1), ErrInvalidArg)
+	checkErr(t, session.AddCustodian("Pedrito", folder, EMAIL, 0, 1), ErrInvalidArg)
+	checkErr(t, session.AddCustodian("Pedrito", folder+"missing/", NONE, 0, 1), ErrIO)
+	if session.GetNCustodians() != 0 || session.GetCustodian(-1) != nil {
+		t.Error("Invalid custodian added")
+	}
+
+	// invalid shares
+	restored, _ := NewBackupSession(nil, folder)
+	checkErr(t, restored.ScanQRShare(folder+"missing.png"), ErrIO)
+	ioutil.WriteFile(folder+"invalid.png", []byte("not an image"), 0644)
+	checkErr(t, restored.ScanQRShare(folder+"invalid.png"), ErrQR)
+	ioutil.WriteFile(folder+"invalid.dat", make([]byte, 100), 0644)
+	checkErr(t, restored.ScanQRShare(folder+"invalid.dat"), ErrInvalidShare)
+	if restored.GetNShares() != 0 {
+		t.Error("Invalid share added")
+	}
+
+	// not enough shares
+	checkOK(t, session.AddCustodian("Pedrito", folder, NONE, 0, MIN_N_SHARES-1))
+	custodian := session.GetCustodian(0)
+	custodian.Nickname = "Faustino"
+	if session.GetCustodian(0).Nickname != "Pedrito" {
+		t.Error("Session custodian modified")
+	}
+	checkOK(t, restored.ScanQRShare(session.GetCustodian(0).Fname))
+	_, err = restored.GenerateKey()
+	checkErr(t, err, ErrNotEnough)
+
+	// custodian file holds the shares encoded by the secret sharing scheme
+	info, err := decode(session.GetCustodian(0).Fname, nil)
+	if err != nil || !checkEqual(toShares(session.GetShares())[:MIN_N_SHARES-1], retrieveShares(info, PRIME)) {
+		t.Error("Custodian shares .... KO", err)
+	}
+
+	// access policy needs to match secret sharing configuration and custodians
+	pedrito := shamir.NewCustodianPolicy("Pedrito", MIN_N_SHARES-1)
+	policy := shamir.NewThresholdPolicy(MIN_N_SHARES-1, pedrito, shamir.NewCustodianPolicy("Faustino", 1))
+	checkErr(t, session.SetPolicy(&policy), ErrInvalidArg)
+	policy = shamir.NewThresholdPolicy(MIN_N_SHARES, pedrito,
+		shamir.NewOrPolicy(shamir.NewCustodianPolicy("Faustino", 1), shamir.NewCustodianPolicy("Sergio", 1)))
+	checkErr(t, session.SetPolicy(&policy), ErrInvalidArg)
+	policy = shamir.NewThresholdPolicy(MIN_N_SHARES, shamir.NewCustodianPolicy("Pedrito", 1), shamir.NewCustodianPolicy("Faustino", 3))
+	checkErr(t, session.SetPolicy(&policy), ErrInvalidArg)
+	policy = shamir.NewThresholdPolicy(MIN_N_SHARES, pedrito, shamir.NewCustodianPolicy("Faustino", 1))
+	checkOK(t, session.SetPolicy(&policy))
+	checkErr(t, session.AddCustodian("Sergio", folder, NONE, MIN_N_SHARES-1, 1), ErrInvalidArg)
+	checkErr(t, session.AddCustodian("Faustino", folder, NONE, MIN_N_SHARES-1, 2), ErrInvalidArg)
+	checkErr(t, session.AddCustodian("Faustino", folder, NONE, 0, 1), ErrInvalidArg)
+	checkOK(t, session.AddCustodian("Faustino", folder, NONE, MIN_N_SHARES-1, 1))
+	if needed, err := session.NeededCustodians([]string{"Faustino"}); err != nil || !checkEqual(needed, []string{"Pedrito"}) {
+		t.Error("Unexpected needed custodians", needed, err)
+	}
+	checkOK(t, session.SetPolicy(nil))
+
+	// shares of a different secret sharing configuration
+	other, _ := NewSecretSharing(&SecretSharingCfg{Scheme: shamir.SCHEME_ID, MinShares: 2, MaxShares: 3, ElementType: ff.FF_BLS12381_FR})
+	restored.SetSecretCfg(&Secret{other})
+	checkErr(t, restored.ScanQRShare(session.GetCustodian(0).Fname), ErrInvalidShare)
+
+	_, err = NewSecretSharing(&SecretSharingCfg{Scheme: "unknown"})
+	checkErr(t, err, ErrSecretSharing)
+}
+
+func TestBackupErrors(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	kOp := session.KeyOperational()
+	session.SetkOp(kOp)
+	checkOK(t, session.GenerateShares(kOp))
+
+	checkErr(t, session.AddToBackup(MAX_TYPE, ENCRYPT), ErrInvalidArg)
+	checkErr(t, session.AddToBackup(WALLET_CONFIG, 7), ErrInvalidArg)
+	checkErr(t, session.AddToBackup(PKEYS, ENCRYPT), ErrIdentity)
+	checkErr(t, session.AddToBackup(STORAGE, ENCRYPT), ErrIdentity)
+	// no policy defined -> not included
+	checkOK(t, session.AddToBackup(POLICY, DONT_ENCRYPT))
+	_, err := session.NeededCustodians(nil)
+	checkErr(t, err, ErrInvalidBackup)
+
+	_, err = session.RestoreIdentity(folder, keystore.StandardKeyStoreParams)
+	checkErr(t, err, ErrIdentity)
+
+	// backup without custodians
+	checkOK(t, session.AddToBackup(WALLET_CONFIG, ENCRYPT))
+	checkOK(t, session.AddToBackup(SSHARING, DONT_ENCRYPT))
+	checkOK(t, session.CreateBackup(folder+"backup.bk"))
+	checkErr(t, session.CreateBackup(folder+"missing/backup.bk"), ErrIO)
+
+	restored, _ := NewBackupSession(nil, folder)
+	checkErr(t, restored.DecodeUnencrypted(folder+"backup.bk"), ErrInvalidBackup)
+	checkErr(t, restored.DecodeUnencrypted(folder+"missing.bk"), ErrIO)
+
+	// wrong key
+	restored.SetkOp(restored.KeyOperational())
+	checkErr(t, restored.DecodeEncrypted(folder+"backup.bk"), ErrDecrypt)
+	restored.SetkOp(kOp)
+	checkOK(t, restored.DecodeEncrypted(folder+"backup.bk"))
+
+	// corrupted backups
+	data, _ := ioutil.ReadFile(folder + "backup.bk")
+	for _, corrupted := range [][]byte{
+		data[:20],
+		data[:len(data)/2],
+		append([]byte{1, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, data[10:]...),
+		make([]byte, len(data)),
+	} {
+		ioutil.WriteFile(folder+"corrupted.bk", corrupted, 0644)
+		err = restored.DecodeUnencrypted(folder + "corrupted.bk")
+		if err == nil {
+			t.Error("Corrupted backup decoded")
+		}
+		err = restored.DecodeEncrypted(folder + "corrupted.bk")
+		if err == nil {
+			t.Error("Corrupted backup decrypted")
+		}
+	}
+}
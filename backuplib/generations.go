@@ -0,0 +1,440 @@
+/*
+  Backup generations
+
+  Every backup uploaded with CreateGeneration is kept as a new generation in a store, instead of
+  replacing the previous backup. Generations are identified by their creation time
+  ("20060102T150405Z"), and described in a manifest stored together with them :
+
+    <prefix>manifest.json
+    <prefix><ID>.bk
+
+  A retention policy selects the generations kept after every new generation is created.
+
+  Generations with incremental or differential STORAGE blocks record the storage backups they are
+  built on, and can only be restored together with them (see RestoreGeneration).
+*/
+
+package backuplib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-backup/store"
+)
+
+const (
+	GENERATION_MANIFEST  = "manifest.json"
+	GENERATION_EXT       = ".bk"
+	GENERATION_ID_FORMAT = "20060102T150405Z"
+	MANIFEST_VERSION     = 1
+)
+
+// Backup generation description
+type Generation struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	Name  string    `json:"name"`  // object name in store
+	Size  int64     `json:"size"`  // backup file size
+	Types []int     `json:"types"` // backup sources included
+
+	// STORAGE block. Empty if generation doesn't include STORAGE
+	StorageMode     int    `json:"storageMode,omitempty"`
+	StorageID       []byte `json:"storageId,omitempty"`
+	StorageBaseID   []byte `json:"storageBaseId,omitempty"`   // full backup the block is built on
+	StorageParentID []byte `json:"storageParentId,omitempty"` // backup the changes are relative to
+}
+
+type manifest struct {
+	Version     int          `json:"version"`
+	Generations []Generation `json:"generations"` // sorted by time
+}
+
+// Backup generations kept in a store
+type Generations struct {
+	mu     sync.Mutex
+	st     store.BackupStore
+	prefix string
+	policy *RetentionPolicy
+	now    func() time.Time
+}
+
+// Generations kept in st with names starting with prefix (for example "wallet/"). Several wallets
+// can share a store with different prefixes
+func NewGenerations(st store.BackupStore, prefix string) *Generations {
+	return &Generations{st: st, prefix: prefix, now: time.Now}
+}
+
+// Set retention policy applied when a generation is created. With nil policy all generations are kept
+func (g *Generations) SetRetentionPolicy(policy *RetentionPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.policy = policy
+}
+
+// Returns generations sorted by time, oldest first
+func (g *Generations) List() ([]Generation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m, err := g.readManifest("ListGenerations")
+	if err != nil {
+		return nil, err
+	}
+	return m.Generations, nil
+}
+
+// Returns generation with identifier id
+func (g *Generations) Get(id string) (*Generation, error) {
+	generations, err := g.List()
+	if err != nil {
+		return nil, err
+	}
+	for idx := range generations {
+		if generations[idx].ID == id {
+			return &generations[idx], nil
+		}
+	}
+	return nil, newErrorf(ERR_INVALID_ARG, "GetGeneration", "Unknown generation "+id)
+}
+
+// Returns latest generation created at or before t
+func (g *Generations) At(t time.Time) (*Generation, error) {
+	generations, err := g.List()
+	if err != nil {
+		return nil, err
+	}
+	for idx := len(generations) - 1; idx >= 0; idx-- {
+		if !generations[idx].Time.After(t) {
+			return &generations[idx], nil
+		}
+	}
+	return nil, newErrorf(ERR_INVALID_ARG, "GetGeneration", "No generation at "+t.UTC().Format(GENERATION_ID_FORMAT))
+}
+
+// Returns generations needed to restore generation id, oldest first : the full backup and the
+// incremental (or differential) backups its STORAGE block is built on, and the generation itself
+func (g *Generations) Chain(id string) ([]Generation, error) {
+	generations, err := g.List()
+	if err != nil {
+		return nil, err
+	}
+	idx := len(generations) - 1
+	for idx >= 0 && generations[idx].ID != id {
+		idx--
+	}
+	if idx < 0 {
+		return nil, newErrorf(ERR_INVALID_ARG, "GenerationChain", "Unknown generation "+id)
+	}
+	chain := []Generation{generations[idx]}
+	for {
+		idx, err = storageParent(generations, idx)
+		if err != nil {
+			return nil, newError(ERR_INVALID_BACKUP, "GenerationChain", err)
+		}
+		if idx < 0 {
+			return chain, nil
+		}
+		chain = append([]Generation{generations[idx]}, chain...)
+	}
+}
+
+// Returns index of the generation the STORAGE block of generations[idx] is built on, or -1 if it
+// is a full backup. Generations are sorted by time
+func storageParent(generations []Generation, idx int) (int, error) {
+	gen := generations[idx]
+	if gen.StorageID == nil || gen.StorageMode == STORAGE_FULL {
+		return -1, nil
+	}
+	for p := idx - 1; p >= 0; p-- {
+		parent := generations[p]
+		if !bytes.Equal(parent.StorageID, gen.StorageParentID) {
+			continue
+		}
+		if parent.StorageMode == STORAGE_FULL && bytes.Equal(parent.StorageID, gen.StorageBaseID) ||
+			parent.StorageMode != STORAGE_FULL && bytes.Equal(parent.StorageBaseID, gen.StorageBaseID) {
+			return p, nil
+		}
+	}
+	return -1, errors.New("Storage base backup missing in generation " + gen.ID)
+}
+
+// Apply retention policy. Returns removed generations
+func (g *Generations) Prune() ([]Generation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.prune()
+}
+
+// Called with lock held
+func (g *Generations) prune() ([]Generation, error) {
+	m, err := g.readManifest("PruneGenerations")
+	if err != nil || g.policy == nil {
+		return nil, err
+	}
+	keep := g.policy.Keep(m.Generations, g.now())
+	kept := make([]Generation, 0, len(m.Generations))
+	removed := make([]Generation, 0)
+	for idx, gen := range m.Generations {
+		if keep[idx] {
+			kept = append(kept, gen)
+		} else {
+			removed = append(removed, gen)
+		}
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	// update manifest first, so that it never references deleted backups
+	m.Generations = kept
+	err = g.writeManifest("PruneGenerations", m)
+	if err != nil {
+		return nil, err
+	}
+	for _, gen := range removed {
+		err = g.st.Delete(gen.Name)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return removed, newError(ERR_STORE, "PruneGenerations", err)
+		}
+	}
+	return removed, nil
+}
+
+// Add generation to manifest and apply retention policy. Called with lock held
+func (g *Generations) add(gen Generation) error {
+	m, err := g.readManifest("CreateGeneration")
+	if err != nil {
+		return err
+	}
+	m.Generations = append(m.Generations, gen)
+	sort.SliceStable(m.Generations, func(i, j int) bool {
+		return m.Generations[i].Time.Before(m.Generations[j].Time)
+	})
+	err = g.writeManifest("CreateGeneration", m)
+	if err != nil {
+		return err
+	}
+	_, err = g.prune()
+	return err
+}
+
+// Returns unused identifier for a generation created at t. Called with lock held
+func (g *Generations) newID(t time.Time) (string, error) {
+	m, err := g.readManifest("CreateGeneration")
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(m.Generations))
+	for _, gen := range m.Generations {
+		used[gen.ID] = true
+	}
+	id := t.UTC().Format(GENERATION_ID_FORMAT)
+	for n := 1; used[id]; n++ {
+		id = t.UTC().Format(GENERATION_ID_FORMAT) + "-" + strconv.Itoa(n)
+	}
+	return id, nil
+}
+
+// Missing manifest is an empty manifest
+func (g *Generations) readManifest(op string) (*manifest, error) {
+	data, err := g.st.Get(g.prefix + GENERATION_MANIFEST)
+	if errors.Is(err, store.ErrNotFound) {
+		return &manifest{Version: MANIFEST_VERSION, Generations: make([]Generation, 0)}, nil
+	}
+	if err != nil {
+		return nil, newError(ERR_STORE, op, err)
+	}
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, newError(ERR_INVALID_BACKUP, op, fmt.Errorf("Invalid manifest : %w", err))
+	}
+	if m.Version > MANIFEST_VERSION {
+		return nil, newErrorf(ERR_UNSUPPORTED, op, "Unsupported manifest version "+strconv.Itoa(m.Version))
+	}
+	if m.Generations == nil {
+		m.Generations = make([]Generation, 0)
+	}
+	return &m, nil
+}
+
+func (g *Generations) writeManifest(op string, m *manifest) error {
+	m.Version = MANIFEST_VERSION
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return newError(ERR_UNKNOWN, op, err)
+	}
+	err = g.st.Put(g.prefix+GENERATION_MANIFEST, data)
+	if err != nil {
+		return newError(ERR_STORE, op, err)
+	}
+	return nil
+}
+
+// Generate backup and upload it as a new generation. Sources added to backup are exported again, so
+// that every generation captures their current state. Retention policy is applied afterwards
+func (s *BackupSession) CreateGeneration(g *Generations) (*Generation, error) {
+	err := s.refreshBackup()
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	id, err := g.newID(now)
+	if err != nil {
+		return nil, err
+	}
+	gen := Generation{ID: id,
+		Time:  now,
+		Name:  g.prefix + id + GENERATION_EXT,
+		Types: s.backupTypes(),
+	}
+	if block := s.storageBlock(); block != nil {
+		gen.StorageMode = block.Mode
+		gen.StorageID = block.ID
+		gen.StorageBaseID = block.BaseID
+		gen.StorageParentID = block.ParentID
+	}
+	// storage index is only committed once the generation is recorded in the manifest, so that
+	// next incremental generations are built on a recorded generation
+	index, err := s.uploadBackup(g.st, gen.Name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := g.st.Stat(gen.Name)
+	if err != nil {
+		return nil, newError(ERR_STORE, "CreateGeneration", err)
+	}
+	gen.Size = info.Size
+
+	err = g.add(gen)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.commitStorageIndex(index)
+	s.mu.Unlock()
+	return &gen, nil
+}
+
+// Retrieve generation id and write it to local file fname, to be decoded with DecodeUnencrypted
+// and DecodeEncrypted
+func (s *BackupSession) FetchGeneration(g *Generations, id, fname string) error {
+	gen, err := g.Get(id)
+	if err != nil {
+		return err
+	}
+	return s.FetchBackup(g.st, gen.Name, fname)
+}
+
+// Retrieve latest generation created at or before t and write it to local file fname. Returns
+// generation fetched
+func (s *BackupSession) FetchGenerationAt(g *Generations, t time.Time, fname string) (*Generation, error) {
+	gen, err := g.At(t)
+	if err != nil {
+		return nil, err
+	}
+	err = s.FetchBackup(g.st, gen.Name, fname)
+	if err != nil {
+		return nil, err
+	}
+	return gen, nil
+}
+
+// Fetch generation id and the generations its STORAGE block is built on to folder, and decode them
+// with session key. Only STORAGE is imported from previous generations, so the session holds the
+// contents of generation id, and GetRestoredStorage and RestoreIdentity replay the storage chain.
+// Fetched files are removed
+func (s *BackupSession) RestoreGeneration(g *Generations, id, folder string) error {
+	chain, err := g.Chain(id)
+	if err != nil {
+		return err
+	}
+	for idx, gen := range chain {
+		fname := folder + gen.ID + GENERATION_EXT
+		err = s.FetchBackup(g.st, gen.Name, fname)
+		if err != nil {
+			return err
+		}
+		if idx == len(chain)-1 {
+			err = s.DecodeEncrypted(fname)
+		} else {
+			err = s.decodeStorageItem(fname)
+		}
+		os.Remove(fname)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import STORAGE block of backup file fname
+func (s *BackupSession) decodeStorageItem(fname string) error {
+	status, err := s.DecodeItems(fname, []int{STORAGE})
+	if err != nil {
+		return err
+	}
+	switch status[0].Status {
+	case ITEM_FAILED:
+		return status[0].Err
+	case ITEM_ENCRYPTED:
+		return newErrorf(ERR_DECRYPT, "RestoreGeneration", "Session key not set")
+	case ITEM_MISSING:
+		return newErrorf(ERR_INVALID_BACKUP, "RestoreGeneration", "Storage base backup missing")
+	}
+	return nil
+}
+
+// STORAGE block added to backup, or nil
+func (s *BackupSession) storageBlock() *StorageBackup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	block, _ := s.registry[STORAGE].data.(*StorageBackup)
+	return block
+}
+
+// Export again sources added to backup with the same encryption mode
+func (s *BackupSession) refreshBackup() error {
+	s.mu.Lock()
+	modes := make(map[int]int, len(s.registry))
+	for t, el := range s.registry {
+		modes[t] = el.mode
+	}
+	s.mu.Unlock()
+
+	for t, mode := range modes {
+		backupEl, err := s.newBackupElement(t, mode)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		if backupEl == nil {
+			delete(s.registry, t)
+		} else {
+			s.registry[t] = *backupEl
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Types of backup sources added to backup, sorted
+func (s *BackupSession) backupTypes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make([]int, 0, len(s.registry))
+	for t := range s.registry {
+		types = append(types, t)
+	}
+	sort.Ints(types)
+	return types
+}
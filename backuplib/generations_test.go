@@ -0,0 +1,245 @@
+package backuplib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-backup/store"
+)
+
+func TestGenerations(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	st, _ := store.NewLocalStore(folder + "remote")
+	g := NewGenerations(st, "wallet/")
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	gens, err := g.List()
+	if err != nil || len(gens) != 0 {
+		t.Error("Unexpected generations", gens, err)
+	}
+
+	// two generations in the same second get different IDs
+	first, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	second, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	if first.ID != "20200615T120000Z" || second.ID != "20200615T120000Z-1" {
+		t.Error("Unexpected generation IDs", first.ID, second.ID)
+	}
+	now = now.Add(24 * time.Hour)
+	original.SetWallet(&WalletConfig{})
+	third, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	if third.Size == 0 || len(third.Types) != 7 || third.Name != "wallet/20200616T120000Z.bk" {
+		t.Error("Unexpected generation", third)
+	}
+
+	gens, err = g.List()
+	if err != nil || len(gens) != 3 || gens[0].ID != first.ID || gens[2].ID != third.ID {
+		t.Error("Unexpected generations", gens, err)
+	}
+	if gen, err := g.At(now.Add(-time.Hour)); err != nil || gen.ID != second.ID {
+		t.Error("Unexpected generation at time", gen, err)
+	}
+	_, err = g.At(now.Add(-48 * time.Hour))
+	checkErr(t, err, ErrInvalidArg)
+	_, err = g.Get("missing")
+	checkErr(t, err, ErrInvalidArg)
+
+	// restore previous generation
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	gen, err := session.FetchGenerationAt(g, now.Add(-time.Hour), folder+"fetched.bk")
+	checkOK(t, err)
+	if gen.ID != second.ID {
+		t.Error("Unexpected generation fetched", gen)
+	}
+	session.SetkOp(original.GetkOp())
+	checkOK(t, session.DecodeEncrypted(folder+"fetched.bk"))
+	if checkEqual(*original.GetWallet(), *session.GetWallet()) {
+		t.Error("Retrieved latest wallet")
+	}
+	checkOK(t, session.FetchGeneration(g, third.ID, folder+"fetched.bk"))
+	checkOK(t, session.DecodeEncrypted(folder+"fetched.bk"))
+	if !checkEqual(*original.GetWallet(), *session.GetWallet()) {
+		t.Error("Retrieved Wallet .... KO")
+	}
+
+	// retention
+	g.SetRetentionPolicy(&RetentionPolicy{KeepLast: 2})
+	removed, err := g.Prune()
+	if err != nil || len(removed) != 1 || removed[0].ID != first.ID {
+		t.Error("Unexpected generations removed", removed, err)
+	}
+	if _, err = st.Stat(first.Name); err == nil {
+		t.Error("Removed generation still stored")
+	}
+	now = now.Add(time.Hour)
+	_, err = original.CreateGeneration(g)
+	checkOK(t, err)
+	if gens, _ = g.List(); len(gens) != 2 || gens[0].ID != third.ID {
+		t.Error("Retention policy not applied", gens)
+	}
+
+	// generations of other wallets are independent
+	other := NewGenerations(st, "other/")
+	if gens, _ = other.List(); len(gens) != 0 {
+		t.Error("Unexpected generations", gens)
+	}
+
+	// corrupted manifest
+	st.Put("wallet/"+GENERATION_MANIFEST, []byte("{"))
+	_, err = g.List()
+	checkErr(t, err, ErrInvalidBackup)
+	st.Put("wallet/"+GENERATION_MANIFEST, []byte(`{"version":2}`))
+	_, err = g.List()
+	checkErr(t, err, ErrUnsupported)
+}
+
+func TestGenerationChain(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	st, _ := store.NewLocalStore(folder + "remote")
+	g := NewGenerations(st, "wallet/")
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	// full generation followed by incremental generations
+	full, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	if full.StorageMode != STORAGE_FULL || full.StorageID == nil || full.StorageParentID != nil {
+		t.Error("Unexpected full generation", full)
+	}
+	checkOK(t, original.SetStorageBackupMode(STORAGE_INCREMENTAL))
+	storage, _ := original.identity().Export(original.GetkOp())
+	incrs := make([]*Generation, 3)
+	for idx := range incrs {
+		tx, _ := storage.NewTx()
+		tx.Put([]byte(fmt.Sprintf("new key %d", idx)), []byte("value"))
+		checkOK(t, tx.Commit())
+		now = now.Add(time.Hour)
+		incrs[idx], err = original.CreateGeneration(g)
+		checkOK(t, err)
+	}
+	expected, _ := storage2KV(storage)
+
+	gens, _ := g.List()
+	if len(gens) != 4 || gens[3].StorageMode != STORAGE_INCREMENTAL ||
+		!bytes.Equal(gens[3].StorageBaseID, full.StorageID) || !bytes.Equal(gens[3].StorageParentID, gens[2].StorageID) {
+		t.Error("Storage chain not recorded in manifest", gens)
+	}
+	chain, err := g.Chain(incrs[2].ID)
+	if err != nil || len(chain) != 4 || chain[0].ID != full.ID || chain[3].ID != incrs[2].ID {
+		t.Error("Unexpected generation chain", chain, err)
+	}
+	_, err = g.Chain("missing")
+	checkErr(t, err, ErrInvalidArg)
+
+	// base and parents of kept generations are not removed
+	g.SetRetentionPolicy(&RetentionPolicy{KeepLast: 1})
+	removed, err := g.Prune()
+	if err != nil || len(removed) != 0 {
+		t.Error("Storage chain removed", removed, err)
+	}
+
+	// a new full generation allows removing the previous chain
+	checkOK(t, original.SetStorageBackupMode(STORAGE_FULL))
+	now = now.Add(time.Hour)
+	last, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	if gens, _ = g.List(); len(gens) != 1 || gens[0].ID != last.ID {
+		t.Error("Retention policy not applied", gens)
+	}
+
+	// restore incremental generation
+	g.SetRetentionPolicy(nil)
+	checkOK(t, original.SetStorageBackupMode(STORAGE_INCREMENTAL))
+	tx, _ := storage.NewTx()
+	tx.Put([]byte("new key 3"), []byte("value"))
+	checkOK(t, tx.Commit())
+	expected, _ = storage2KV(storage)
+	now = now.Add(time.Hour)
+	incr, err := original.CreateGeneration(g)
+	checkOK(t, err)
+
+	restored, _ := NewBackupSession(nil, folder)
+	defer restored.Close()
+	checkErr(t, restored.RestoreGeneration(g, incr.ID, folder), ErrDecrypt)
+	restored.SetkOp(original.GetkOp())
+	checkOK(t, restored.RestoreGeneration(g, incr.ID, folder))
+	kv, err := restored.GetRestoredStorage()
+	if err != nil || !kvEqual(kv, expected) {
+		t.Error("Unexpected restored storage", err)
+	}
+	if !checkEqual(*original.GetWallet(), *restored.GetWallet()) {
+		t.Error("Retrieved Wallet .... KO")
+	}
+
+	// manifest without the full generation
+	m, _ := g.readManifest("")
+	m.Generations = m.Generations[1:]
+	checkOK(t, g.writeManifest("", m))
+	_, err = g.Chain(incr.ID)
+	checkErr(t, err, ErrInvalidBackup)
+	checkErr(t, restored.RestoreGeneration(g, incr.ID, folder), ErrInvalidBackup)
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	// daily backups for 400 days
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	gens := make([]Generation, 400)
+	for idx := range gens {
+		gens[idx].Time = now.AddDate(0, 0, idx-len(gens)+1)
+	}
+
+	countKept := func(p *RetentionPolicy) (int, []bool) {
+		keep := p.Keep(gens, now)
+		n := 0
+		for _, k := range keep {
+			if k {
+				n++
+			}
+		}
+		return n, keep
+	}
+
+	// newest generation is always kept
+	if n, keep := countKept(&RetentionPolicy{}); n != 1 || !keep[len(gens)-1] {
+		t.Error("Unexpected generations kept", n)
+	}
+	if n, _ := countKept(&RetentionPolicy{KeepLast: 3}); n != 3 {
+		t.Error("Unexpected generations kept", n)
+	}
+	// last 3, 7 days and last day of previous 11 months
+	n, keep := countKept(&RetentionPolicy{KeepLast: 3, KeepDaily: 7, KeepMonthly: 12})
+	if n != 18 {
+		t.Error("Unexpected generations kept", n)
+	}
+	for idx, gen := range gens {
+		lastOfMonth := gen.Time.AddDate(0, 0, 1).Day() == 1
+		expected := idx >= len(gens)-7 || (lastOfMonth && gen.Time.After(time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)))
+		if keep[idx] != expected {
+			t.Error("Unexpected retention", gen.Time)
+		}
+	}
+	// weeks and years
+	if n, _ := countKept(&RetentionPolicy{KeepWeekly: 4, KeepYearly: 2}); n != 5 {
+		t.Error("Unexpected generations kept", n)
+	}
+	if n, _ := countKept(&RetentionPolicy{}); len((&RetentionPolicy{}).Keep(nil, now)) != 0 || n != 1 {
+		t.Error("Unexpected generations kept", n)
+	}
+}
@@ -13,101 +13,114 @@ import (
 	"os"
 )
 
-var id *iden3mobile.Identity
-
 type PrivateKeys struct {
 	PK []babyjub.PrivateKey
 }
 
 // Init
-func initIdentity(pass []byte, folder string) error {
-	c = config{
+func (s *BackupSession) initIdentity(pass []byte, folder string) error {
+	s.c = config{
 		Web3Url:            WEB3URL,
 		HolderTicketPeriod: HOLDER_TICKET_PERIOD,
 	}
 
 	if pass == nil {
-		id = nil
+		s.id = nil
 		return nil
 	}
 	// New identity without extra claims
 	dir1, err := ioutil.TempDir(folder, IDENTITY_MAIN_STORAGE)
 	if err != nil {
-		return err
+		return newError(ERR_IO, "Init", err)
 	}
-	rmDirs = append(rmDirs, dir1)
-	_id, err := iden3mobile.NewIdentity(dir1, string(pass), c.Web3Url, c.HolderTicketPeriod, iden3mobile.NewBytesArray(), nil)
+	s.rmDirs = append(s.rmDirs, dir1)
+	_id, err := iden3mobile.NewIdentity(dir1, string(pass), s.c.Web3Url, s.c.HolderTicketPeriod, iden3mobile.NewBytesArray(), nil)
 	if err != nil {
-		return err
+		return newError(ERR_IDENTITY, "Init", err)
 	}
-	id = _id
+	s.id = _id
 	return nil
 }
 
 // KeyStore to Private Keys
-func keyStore2PK(ks *keystore.KeyStore, pass []byte) error {
+func keyStore2PK(ks *keystore.KeyStore, pass []byte) (*PrivateKeys, error) {
 	keys := ks.Keys()
 	backupPK := make([]babyjub.PrivateKey, 0)
 	for _, key := range keys {
 		pk, err := ks.ExportKey(&key, pass)
 		if err != nil {
-			return nil
+			return nil, err
 		}
 		backupPK = append(backupPK, *pk)
 	}
-	PK := PrivateKeys{PK: backupPK}
-	dataBackup.pK = &PK
-	return nil
+	return &PrivateKeys{PK: backupPK}, nil
 }
 
 // db.Storage to KV
-func storage2KV(sto db.Storage) error {
+func storage2KV(sto db.Storage) ([]db.KV, error) {
 	r := []db.KV{}
 	lister := func(k []byte, v []byte) (bool, error) {
-		r = append(r, db.KV{clone(k), clone(v)})
+		r = append(r, db.KV{K: clone(k), V: clone(v)})
 		return true, nil
 	}
 
 	err := sto.Iterate(lister)
-	dataBackup.storage = r
 
-	return err
+	return r, err
 }
 
-func restoreStorage(folder string) (db.Storage, error) {
+// Restore storage from full backup contents and chain of incremental backups. Storage of an
+// existing identity in folder is replaced
+func restoreStorage(folder string, backupStorage []db.KV, chain []StorageBackup) error {
+	backupStorage, err := replayStorage(backupStorage, chain)
+	if err != nil {
+		return err
+	}
+
 	// Create empty storage
 	storageFolder := folder + "/" + FOLDER_STORE
-	err := os.Mkdir(storageFolder, 0777)
+	restoreFolder := storageFolder + ".restore"
+	os.RemoveAll(restoreFolder)
+	err = os.Mkdir(restoreFolder, 0777)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	sto, err := db.NewLevelDbStorage(storageFolder+STORE_FILE, false)
+	defer os.RemoveAll(restoreFolder)
+	sto, err := db.NewLevelDbStorage(restoreFolder+STORE_FILE, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Fill storage with backup contents
 	//    Prepare new Tx
 	tx, err := sto.NewTx()
 	if err != nil {
-		return nil, err
+		sto.Close()
+		return err
 	}
-	//   Retrieve copy of storage backup
-	backupStorage := GetStorage()
 	//   Iterate through backup KV values and insert them to new storage
 	for _, kv := range backupStorage {
 		tx.Put(kv.K, kv.V)
 	}
-	tx.Commit()
+	err = tx.Commit()
 	sto.Close()
+	if err != nil {
+		return err
+	}
 
-	return sto, nil
+	// Replace storage only once it is complete
+	err = os.RemoveAll(storageFolder)
+	if err != nil {
+		return err
+	}
+	return os.Rename(restoreFolder, storageFolder)
 }
 
-func restoreKStore(folder string, params keystore.KeyStoreParams) (*keystore.KeyStore, error) {
-	// Create empty storage
+// Import backup keys in key store. Keys in the key store of an existing identity in folder are kept
+func restoreKStore(folder string, params keystore.KeyStoreParams, backupKStore *PrivateKeys, pass []byte) (*keystore.KeyStore, error) {
+	// Create key store folder if needed
 	kstorageFolder := folder + "/" + FOLDER_KSTORE
-	err := os.Mkdir(kstorageFolder, 0777)
+	err := os.MkdirAll(kstorageFolder, 0777)
 	if err != nil {
 		return nil, err
 	}
@@ -118,11 +131,10 @@ func restoreKStore(folder string, params keystore.KeyStoreParams) (*keystore.Key
 	}
 
 	// Restore backup copy
-	backupKStore := GetPrivateKeys()
-	pass := GetkOp()
 	for _, pk := range backupKStore.PK {
 		_, err = ks.ImportKey(pk, pass)
 		if err != nil {
+			ks.Close()
 			return nil, err
 		}
 	}
@@ -131,26 +143,40 @@ func restoreKStore(folder string, params keystore.KeyStoreParams) (*keystore.Key
 	return ks, err
 }
 
-func RestoreIdentity(folder string, params keystore.KeyStoreParams) (*iden3mobile.Identity, error) {
+// Restore identity from backup contents in a new folder. Identity and its folder are owned by the
+// caller, and are kept when the session is closed
+func (s *BackupSession) RestoreIdentity(folder string, params keystore.KeyStoreParams) (*iden3mobile.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.pK == nil {
+		return nil, newErrorf(ERR_IDENTITY, "RestoreIdentity", "No private keys to restore")
+	}
 	dir, err := ioutil.TempDir(folder, IDENTITY_MAIN_STORAGE)
-	// Restore Storage
-	_, err = restoreStorage(dir)
 	if err != nil {
-		return nil, err
+		return nil, newError(ERR_IO, "RestoreIdentity", err)
 	}
+	_id, err := s.restoreIdentity(dir, params)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, newError(ERR_IDENTITY, "RestoreIdentity", err)
+	}
+	return _id, nil
+}
 
-	// Restore Key Store
-	_, err = restoreKStore(dir, params)
+// Restore identity in folder dir. Called with lock held
+func (s *BackupSession) restoreIdentity(dir string, params keystore.KeyStoreParams) (*iden3mobile.Identity, error) {
+	// Restore Storage
+	err := restoreStorage(dir, s.data.storage, s.data.storageChain)
 	if err != nil {
 		return nil, err
 	}
 
-	pass := GetkOp()
-	rmDirs = append(rmDirs, dir)
-	_id, err := iden3mobile.NewIdentityLoad(dir, string(pass), c.Web3Url, c.HolderTicketPeriod, nil)
+	// Restore Key Store
+	pass := s.data.kOp
+	_, err = restoreKStore(dir, params, s.data.pK, pass)
 	if err != nil {
 		return nil, err
 	}
 
-	return _id, nil
+	return iden3mobile.NewIdentityLoad(dir, string(pass), s.c.Web3Url, s.c.HolderTicketPeriod, nil)
 }
@@ -0,0 +1,292 @@
+/*
+  gomobile compatibility layer
+
+  Mobile applications use the package level API, which operates on a default session. Init
+  resets the default session. New code should create its own BackupSession instead.
+
+  gomobile can't bind slices other than []byte, so item types are passed as comma separated
+  strings ("1,4"), custodian nicknames as JSON arrays, and item status lists as ItemStatusList.
+*/
+
+package backuplib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iden3/go-backup/filecrypt/repo"
+	"github.com/iden3/go-backup/shamir"
+	"github.com/iden3/go-backup/store"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/iden3-mobile/go/iden3mobile"
+)
+
+// Default session has no identity until Init is called
+var defaultSession = newDefaultSession()
+
+func newDefaultSession() *BackupSession {
+	s := &BackupSession{}
+	s.init(nil, "")
+	return s
+}
+
+// Item status list returned by DecodeItems and RestoreIdentityItems
+type ItemStatusList struct {
+	items []ItemStatus
+}
+
+func (l *ItemStatusList) Len() int {
+	return len(l.items)
+}
+
+// Returns item i, or nil if out of range
+func (l *ItemStatusList) Get(i int) *ItemStatus {
+	if i < 0 || i >= len(l.items) {
+		return nil
+	}
+	item := l.items[i]
+	return &item
+}
+
+// Parse comma separated item types
+func parseItemTypes(op, types string) ([]int, error) {
+	if strings.TrimSpace(types) == "" {
+		return nil, nil
+	}
+	fields := strings.Split(types, ",")
+	parsed := make([]int, 0, len(fields))
+	for _, field := range fields {
+		t, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, newErrorf(ERR_INVALID_ARG, op, fmt.Sprintf("Invalid item type %q", field))
+		}
+		parsed = append(parsed, t)
+	}
+	return parsed, nil
+}
+
+// Returns the session used by the package level API
+func DefaultSession() *BackupSession {
+	return defaultSession
+}
+
+func Init(pass []byte, folder string) error {
+	return defaultSession.Init(pass, folder)
+}
+
+func GetkOp() []byte {
+	return defaultSession.GetkOp()
+}
+
+func SetkOp(kOp []byte) {
+	defaultSession.SetkOp(kOp)
+}
+
+func KeyOperational() []byte {
+	return defaultSession.KeyOperational()
+}
+
+func GetWallet() *WalletConfig {
+	return defaultSession.GetWallet()
+}
+
+func SetWallet(data *WalletConfig) {
+	defaultSession.SetWallet(data)
+}
+
+func GetShares() *Shares {
+	return defaultSession.GetShares()
+}
+
+func SetShares(data *Shares) {
+	defaultSession.SetShares(data)
+}
+
+func GetSecretCfg() *Secret {
+	return defaultSession.GetSecretCfg()
+}
+
+func GetSecretCfgOriginal() SecretSharing {
+	return defaultSession.GetSecretCfgOriginal()
+}
+
+func SetSecretCfg(data *Secret) {
+	defaultSession.SetSecretCfg(data)
+}
+
+func GetCustodians() *Custodians {
+	return defaultSession.GetCustodians()
+}
+
+func SetCustodians(data *Custodians) {
+	defaultSession.SetCustodians(data)
+}
+
+func GetPolicy() *shamir.Policy {
+	return defaultSession.GetPolicy()
+}
+
+func SetPolicy(data *shamir.Policy) error {
+	return defaultSession.SetPolicy(data)
+}
+
+func GetPrivateKeys() *PrivateKeys {
+	return defaultSession.GetPrivateKeys()
+}
+
+func SetPrivateKeys(data *PrivateKeys) {
+	defaultSession.SetPrivateKeys(data)
+}
+
+func GetStorage() []db.KV {
+	return defaultSession.GetStorage()
+}
+
+func SetStorage(data []db.KV) {
+	defaultSession.SetStorage(data)
+}
+
+func AddToBackup(t, action int) error {
+	return defaultSession.AddToBackup(t, action)
+}
+
+func CreateBackup(fname string) error {
+	return defaultSession.CreateBackup(fname)
+}
+
+func DecodeUnencrypted(fname string) error {
+	return defaultSession.DecodeUnencrypted(fname)
+}
+
+func DecodeEncrypted(fname string) error {
+	return defaultSession.DecodeEncrypted(fname)
+}
+
+func PreviewRestore(fname string) (*RestorePreview, error) {
+	return defaultSession.PreviewRestore(fname)
+}
+
+// Import blocks of comma separated types from backup file fname
+func DecodeItems(fname string, types string) (*ItemStatusList, error) {
+	parsed, err := parseItemTypes("DecodeItems", types)
+	if err != nil {
+		return nil, err
+	}
+	status, err := defaultSession.DecodeItems(fname, parsed)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemStatusList{items: status}, nil
+}
+
+func UploadBackup(st store.BackupStore, name string) error {
+	return defaultSession.UploadBackup(st, name)
+}
+
+func FetchBackup(st store.BackupStore, name, fname string) error {
+	return defaultSession.FetchBackup(st, name, fname)
+}
+
+func SetStorageBackupMode(mode int) error {
+	return defaultSession.SetStorageBackupMode(mode)
+}
+
+func SaveStorageIndex(fname string) error {
+	return defaultSession.SaveStorageIndex(fname)
+}
+
+func LoadStorageIndex(fname string) error {
+	return defaultSession.LoadStorageIndex(fname)
+}
+
+func CreateGeneration(g *Generations) (*Generation, error) {
+	return defaultSession.CreateGeneration(g)
+}
+
+func FetchGeneration(g *Generations, id, fname string) error {
+	return defaultSession.FetchGeneration(g, id, fname)
+}
+
+func RestoreGeneration(g *Generations, id, folder string) error {
+	return defaultSession.RestoreGeneration(g, id, folder)
+}
+
+func CreateSnapshot(r *repo.Repository, name string) (*repo.Snapshot, error) {
+	return defaultSession.CreateSnapshot(r, name)
+}
+
+func RestoreSnapshot(r *repo.Repository, id string) error {
+	return defaultSession.RestoreSnapshot(r, id)
+}
+
+func GetNShares() int {
+	return defaultSession.GetNShares()
+}
+
+func GetShare(n int) *Share {
+	return defaultSession.GetShare(n)
+}
+
+func GenerateShares(secret []byte) error {
+	return defaultSession.GenerateShares(secret)
+}
+
+func GenerateKey() ([]byte, error) {
+	return defaultSession.GenerateKey()
+}
+
+func GetNCustodians() int {
+	return defaultSession.GetNCustodians()
+}
+
+func GetCustodian(n int) *Custodian {
+	return defaultSession.GetCustodian(n)
+}
+
+// Custodians needed to recover secret. available and result are JSON arrays of nicknames
+func NeededCustodians(available string) (string, error) {
+	var nicknames []string
+	if available != "" {
+		err := json.Unmarshal([]byte(available), &nicknames)
+		if err != nil {
+			return "", newError(ERR_INVALID_ARG, "NeededCustodians", err)
+		}
+	}
+	needed, err := defaultSession.NeededCustodians(nicknames)
+	if err != nil {
+		return "", err
+	}
+	if needed == nil {
+		needed = []string{}
+	}
+	data, _ := json.Marshal(needed)
+	return string(data), nil
+}
+
+func AddCustodian(nickname, folder string, method int, startIdx, nshares int) error {
+	return defaultSession.AddCustodian(nickname, folder, method, startIdx, nshares)
+}
+
+func ScanQRShare(fname string) error {
+	return defaultSession.ScanQRShare(fname)
+}
+
+func RestoreIdentity(folder string, params keystore.KeyStoreParams) (*iden3mobile.Identity, error) {
+	return defaultSession.RestoreIdentity(folder, params)
+}
+
+// Restore comma separated identity item types in identity folder dir
+func RestoreIdentityItems(dir string, params keystore.KeyStoreParams, types string) (*ItemStatusList, error) {
+	parsed, err := parseItemTypes("RestoreIdentityItems", types)
+	if err != nil {
+		return nil, err
+	}
+	status, err := defaultSession.RestoreIdentityItems(dir, params, parsed)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemStatusList{items: status}, nil
+}
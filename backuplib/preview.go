@@ -0,0 +1,278 @@
+/*
+  Restore preview
+
+  InspectBackup lists the blocks in a backup file from their headers, without decrypting them.
+  PreviewRestore decodes a backup file into a detached RestorePreview instead of the session, and
+  compares its contents with the current local state, so that users can see what a restore would
+  change before calling DecodeUnencrypted or DecodeEncrypted.
+*/
+
+package backuplib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	fc "github.com/iden3/go-backup/filecrypt"
+	"github.com/iden3/go-backup/shamir"
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// Description of a block in a backup file
+type BlockInfo struct {
+	Tag       string
+	Type      int    // backup source type. 0 if tag is not a backup source tag
+	Source    string // name of registered backup source. Empty if unknown
+	Version   int    // schema version
+	Size      int64  // bytes stored
+	Encrypted bool
+}
+
+// Description of a backup file
+type BackupInfo struct {
+	Size    int64
+	ModTime time.Time // time backup file was written
+	Blocks  []BlockInfo
+}
+
+// Backup contents decoded without modifying the session. Fields are only set for decoded types
+type RestorePreview struct {
+	Info        *BackupInfo
+	Data        map[int]interface{} // decoded block data, indexed by type
+	Wallet      *WalletConfig
+	Custodians  *Custodians
+	SecretCfg   *SecretSharingCfg
+	Shares      *Shares
+	Policy      *shamir.Policy
+	PrivateKeys *PrivateKeys
+	Storage     []db.KV // storage contents after applying backup
+	Diff        *RestoreDiff
+}
+
+// Differences between backup contents and local state
+type RestoreDiff struct {
+	NewKeys        []string // public keys (compressed, hex) in backup but not in local state
+	MissingKeys    []string // local public keys not in backup
+	AddedConfig    []string // wallet config entries not in local state
+	ChangedConfig  []string // wallet config entries with different value
+	RemovedConfig  []string // local wallet config entries not in backup
+	StorageEntries int      // KV pairs in backup storage
+	LocalEntries   int      // KV pairs in local storage
+	StorageAdded   int      // KV pairs not in local storage
+	StorageChanged int      // KV pairs with different value
+	StorageDeleted int      // local KV pairs not in backup storage
+}
+
+// Returns description of blocks in backup file fname. Blocks are not decrypted
+func InspectBackup(fname string) (*BackupInfo, error) {
+	stats, err := os.Stat(fname)
+	if err != nil {
+		return nil, newError(ERR_IO, "InspectBackup", err)
+	}
+	fileCrypt, err := fc.NewFromFile(nil, fname)
+	if err != nil {
+		return nil, newError(ERR_INVALID_BACKUP, "InspectBackup", err)
+	}
+	blocks, err := fileCrypt.ListBlocks()
+	if err != nil {
+		return nil, newError(ERR_INVALID_BACKUP, "InspectBackup", err)
+	}
+
+	info := &BackupInfo{Size: stats.Size(), ModTime: stats.ModTime(), Blocks: make([]BlockInfo, 0, len(blocks))}
+	for _, block := range blocks {
+		blockInfo := BlockInfo{
+			Tag:       string(block.Tag),
+			Size:      block.Size,
+			Encrypted: block.EncType != fc.FC_CLEAR,
+		}
+		if t, version, err := parseSourceTag(block.Tag); err == nil {
+			blockInfo.Type, blockInfo.Version = t, version
+			if src := GetBackupSource(t); src != nil {
+				blockInfo.Source = src.Name()
+			}
+		}
+		info.Blocks = append(info.Blocks, blockInfo)
+	}
+	return info, nil
+}
+
+// Decode backup file fname without modifying the session. Encrypted blocks are decoded if session
+// key is set. Storage blocks are applied on the storage imported by the session, so that
+// incremental backups can be previewed
+func (s *BackupSession) PreviewRestore(fname string) (*RestorePreview, error) {
+	info, err := InspectBackup(fname)
+	if err != nil {
+		return nil, err
+	}
+	key := s.GetkOp()
+	blocks, err := decodeBlocks("PreviewRestore", fname, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// built-in sources import into a detached session. Application sources may have side
+	// effects, so only their decoded data is returned
+	detached := &BackupSession{}
+	err = detached.init(nil, "")
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	detached.data.kOp = clone(s.data.kOp)
+	detached.data.storage = s.data.storage
+	detached.data.storageID = s.data.storageID
+	detached.data.storageChain = append([]StorageBackup(nil), s.data.storageChain...)
+	s.mu.Unlock()
+
+	preview := &RestorePreview{Info: info, Data: make(map[int]interface{})}
+	for _, b := range blocks {
+		preview.Data[b.t] = b.data
+		if b.t >= USER_TYPES {
+			continue
+		}
+		err = b.src.Import(detached, b.data, b.version)
+		if err != nil {
+			return nil, newError(ERR_INVALID_BACKUP, "PreviewRestore", fmt.Errorf("Import %s : %w", b.src.Name(), err))
+		}
+	}
+
+	data := &detached.data
+	for t := range preview.Data {
+		switch t {
+		case WALLET_CONFIG:
+			preview.Wallet = data.wallet
+		case CUSTODIAN:
+			preview.Custodians = data.secretCustodians
+		case SSHARING:
+			preview.SecretCfg = describeSecretSharing(data.secretCfg)
+		case SHARES:
+			preview.Shares = data.secretShares
+		case POLICY:
+			preview.Policy = data.policy
+		case PKEYS:
+			preview.PrivateKeys = data.pK
+		case STORAGE:
+			preview.Storage, err = replayStorage(data.storage, data.storageChain)
+			if err != nil {
+				return nil, newError(ERR_INVALID_BACKUP, "PreviewRestore", err)
+			}
+		}
+	}
+
+	preview.Diff, err = s.diffRestore(preview)
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// Compare preview with local state : identity keys and storage if session has an identity,
+// otherwise session data
+func (s *BackupSession) diffRestore(preview *RestorePreview) (*RestoreDiff, error) {
+	localKeys, localStorage, err := s.localState()
+	if err != nil {
+		return nil, err
+	}
+	diff := &RestoreDiff{
+		NewKeys:       make([]string, 0),
+		MissingKeys:   make([]string, 0),
+		AddedConfig:   make([]string, 0),
+		ChangedConfig: make([]string, 0),
+		RemovedConfig: make([]string, 0),
+	}
+
+	if preview.Wallet != nil {
+		local := s.GetWallet()
+		if local == nil {
+			local = &WalletConfig{}
+		}
+		diff.AddedConfig, diff.ChangedConfig, diff.RemovedConfig = diffMaps(preview.Wallet.Config, local.Config)
+	}
+
+	if preview.PrivateKeys != nil {
+		local := make(map[string][]byte)
+		if localKeys != nil {
+			local = publicKeys(localKeys)
+		}
+		diff.NewKeys, _, diff.MissingKeys = diffMaps(publicKeys(preview.PrivateKeys), local)
+	}
+
+	if preview.Storage != nil {
+		backup, local := make(map[string][]byte), make(map[string][]byte)
+		for _, el := range preview.Storage {
+			backup[string(el.K)] = el.V
+		}
+		for _, el := range localStorage {
+			local[string(el.K)] = el.V
+		}
+		added, changed, deleted := diffMaps(backup, local)
+		diff.StorageEntries, diff.LocalEntries = len(backup), len(local)
+		diff.StorageAdded, diff.StorageChanged, diff.StorageDeleted = len(added), len(changed), len(deleted)
+	}
+	return diff, nil
+}
+
+// Current private keys and storage contents
+func (s *BackupSession) localState() (*PrivateKeys, []db.KV, error) {
+	id := s.identity()
+	if id == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		kv, err := replayStorage(s.data.storage, s.data.storageChain)
+		if err != nil {
+			return nil, nil, newError(ERR_INVALID_BACKUP, "PreviewRestore", fmt.Errorf("Local storage : %w", err))
+		}
+		return s.data.pK, kv, nil
+	}
+	pass := s.GetkOp()
+	storage, keystore := id.Export(pass)
+	if storage == nil || keystore == nil {
+		return nil, nil, newError(ERR_IDENTITY, "PreviewRestore", errors.New("Identity export failed"))
+	}
+	pK, err := keyStore2PK(keystore, pass)
+	if err != nil {
+		return nil, nil, newError(ERR_IDENTITY, "PreviewRestore", err)
+	}
+	kv, err := storage2KV(storage)
+	if err != nil {
+		return nil, nil, newError(ERR_IDENTITY, "PreviewRestore", err)
+	}
+	return pK, kv, nil
+}
+
+// Compressed public keys (hex) of private keys
+func publicKeys(pK *PrivateKeys) map[string][]byte {
+	keys := make(map[string][]byte, len(pK.PK))
+	for idx := range pK.PK {
+		pub := pK.PK[idx].Public().Compress()
+		keys[hex.EncodeToString(pub[:])] = nil
+	}
+	return keys
+}
+
+// Returns sorted keys added to, changed in and removed from prev
+func diffMaps(m, prev map[string][]byte) ([]string, []string, []string) {
+	added, changed, removed := make([]string, 0), make([]string, 0), make([]string, 0)
+	for k, v := range m {
+		prevV, ok := prev[k]
+		if !ok {
+			added = append(added, k)
+		} else if !bytes.Equal(v, prevV) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range prev {
+		if _, ok := m[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
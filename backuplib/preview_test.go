@@ -0,0 +1,103 @@
+package backuplib
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInspectBackup(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	info, err := InspectBackup(folder + "backup.bk")
+	checkOK(t, err)
+	if len(info.Blocks) != 7 || info.Size == 0 || info.ModTime.IsZero() {
+		t.Fatal("Unexpected backup info", info)
+	}
+	for idx, el := range []struct {
+		source    string
+		encrypted bool
+	}{{"wallet", true}, {"custodians", false}, {"sharing", false}, {"shares", true},
+		{"privatekeys", true}, {"storage", true}, {"policy", false}} {
+		block := info.Blocks[idx]
+		if block.Type != idx+1 || block.Source != el.source || block.Encrypted != el.encrypted || block.Size == 0 {
+			t.Error("Unexpected block", block)
+		}
+	}
+	if info.Blocks[5].Tag != "6.2" || info.Blocks[5].Version != 2 {
+		t.Error("Unexpected storage block", info.Blocks[5])
+	}
+
+	_, err = InspectBackup(folder + "missing.bk")
+	checkErr(t, err, ErrIO)
+}
+
+func TestPreviewRestore(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	// without key, only unencrypted blocks are decoded
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	wallet := session.GetWallet()
+	preview, err := session.PreviewRestore(folder + "backup.bk")
+	checkOK(t, err)
+	if preview.Wallet != nil || preview.PrivateKeys != nil || preview.Custodians == nil || preview.Policy == nil ||
+		len(preview.Data) != 3 || len(preview.Info.Blocks) != 7 {
+		t.Error("Unexpected preview", preview)
+	}
+
+	// session is not modified
+	session.SetkOp(original.GetkOp())
+	preview, err = session.PreviewRestore(folder + "backup.bk")
+	checkOK(t, err)
+	if !checkEqual(*original.GetWallet(), *preview.Wallet) || !kvEqual(original.GetStorage(), preview.Storage) ||
+		len(preview.PrivateKeys.PK) != len(original.GetPrivateKeys().PK) || preview.SecretCfg == nil {
+		t.Error("Unexpected preview contents")
+	}
+	if session.GetWallet() != wallet || session.GetPrivateKeys() != nil || session.GetStorage() != nil {
+		t.Error("Session modified by preview")
+	}
+	diff := preview.Diff
+	if len(diff.NewKeys) != len(preview.PrivateKeys.PK) || len(diff.MissingKeys) != 0 ||
+		len(diff.AddedConfig) != len(preview.Wallet.Config) || len(diff.RemovedConfig) != len(wallet.Config) ||
+		diff.StorageEntries != len(preview.Storage) || diff.StorageAdded != diff.StorageEntries || diff.LocalEntries != 0 {
+		t.Error("Unexpected diff", diff)
+	}
+
+	// diff with identity of original session
+	config := make(map[string][]byte)
+	for k, v := range original.GetWallet().Config {
+		config[k] = v
+	}
+	var changed string
+	for k := range config {
+		changed = k
+		break
+	}
+	config[changed] = []byte("changed")
+	config["new entry"] = []byte("new")
+	original.SetWallet(&WalletConfig{Config: config})
+	preview, err = original.PreviewRestore(folder + "backup.bk")
+	checkOK(t, err)
+	diff = preview.Diff
+	if len(diff.NewKeys) != 0 || len(diff.MissingKeys) != 0 ||
+		len(diff.AddedConfig) != 0 || len(diff.ChangedConfig) != 1 || diff.ChangedConfig[0] != changed ||
+		len(diff.RemovedConfig) != 1 || diff.RemovedConfig[0] != "new entry" ||
+		diff.LocalEntries == 0 || diff.StorageAdded+diff.StorageChanged+diff.StorageDeleted != 0 {
+		t.Error("Unexpected diff", diff)
+	}
+
+	// local storage that doesn't match its backup chain
+	session.mu.Lock()
+	session.data.storageChain = []StorageBackup{{Mode: STORAGE_INCREMENTAL, ID: []byte("invalid")}}
+	session.mu.Unlock()
+	_, err = session.PreviewRestore(folder + "backup.bk")
+	checkErr(t, err, ErrInvalidBackup)
+}
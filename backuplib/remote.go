@@ -0,0 +1,69 @@
+/*
+  Remote backups
+
+  Backups are uploaded to and fetched from a store.BackupStore (local directory, S3-compatible
+  object store, WebDAV server...). Backup files are encrypted before upload, so stores don't need
+  to be trusted.
+*/
+
+package backuplib
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/iden3/go-backup/store"
+)
+
+// Generate backup file and store it in st with name
+func (s *BackupSession) UploadBackup(st store.BackupStore, name string) error {
+	index, err := s.uploadBackup(st, name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commitStorageIndex(index)
+	return nil
+}
+
+// Generate backup file and store it in st with name. Returns storage index to commit once the
+// backup is recorded
+func (s *BackupSession) uploadBackup(st store.BackupStore, name string) (*storageIndex, error) {
+	tmpFile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		return nil, newError(ERR_IO, "UploadBackup", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	s.mu.Lock()
+	index, err := s.createBackup(tmpFile.Name())
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	data, err := readBinaryFile(tmpFile.Name())
+	if err != nil {
+		return nil, newError(ERR_IO, "UploadBackup", err)
+	}
+	err = st.Put(name, data)
+	if err != nil {
+		return nil, newError(ERR_STORE, "UploadBackup", err)
+	}
+	return index, nil
+}
+
+// Retrieve backup name from st and write it to local file fname, to be decoded with
+// DecodeUnencrypted and DecodeEncrypted
+func (s *BackupSession) FetchBackup(st store.BackupStore, name, fname string) error {
+	data, err := st.Get(name)
+	if err != nil {
+		return newError(ERR_STORE, "FetchBackup", err)
+	}
+	err = ioutil.WriteFile(fname, data, 0600)
+	if err != nil {
+		return newError(ERR_IO, "FetchBackup", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package backuplib
+
+import (
+	"os"
+	"testing"
+
+	"github.com/iden3/go-backup/store"
+)
+
+func TestRemoteBackup(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	st, err := store.NewLocalStore(folder + "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOK(t, original.UploadBackup(st, "wallet/backup.bk"))
+	if info, err := st.Stat("wallet/backup.bk"); err != nil || info.Size == 0 {
+		t.Error("Backup not uploaded", err)
+	}
+
+	// restore from store
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	checkOK(t, session.FetchBackup(st, "wallet/backup.bk", folder+"fetched.bk"))
+	checkOK(t, session.DecodeUnencrypted(folder+"fetched.bk"))
+	session.SetkOp(original.GetkOp())
+	checkOK(t, session.DecodeEncrypted(folder+"fetched.bk"))
+	if !checkEqual(*original.GetWallet(), *session.GetWallet()) {
+		t.Error("Retrieved Wallet .... KO")
+	}
+
+	checkErr(t, session.FetchBackup(st, "wallet/missing.bk", folder+"missing.bk"), ErrStore)
+	checkErr(t, session.FetchBackup(st, "wallet/backup.bk", folder+"missing/fetched.bk"), ErrIO)
+	checkErr(t, original.UploadBackup(st, "../backup.bk"), ErrStore)
+}
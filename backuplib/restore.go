@@ -0,0 +1,141 @@
+/*
+  Selective restore
+
+  DecodeItems imports only the chosen blocks of a backup file, and RestoreIdentityItems only
+  restores the chosen parts of the identity (key store or storage). Every item reports its own
+  status, so missing or damaged items don't prevent restoring the others.
+*/
+
+package backuplib
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/iden3/go-iden3-core/keystore"
+)
+
+// Item restore status
+const (
+	ITEM_RESTORED  = iota
+	ITEM_MISSING   // not included in backup
+	ITEM_ENCRYPTED // encrypted and session key not set
+	ITEM_FAILED    // see ItemStatus.Err
+)
+
+type ItemStatus struct {
+	Type   int
+	Source string // name of backup source
+	Status int
+	Err    error // *BackupError when Status is ITEM_FAILED
+}
+
+// Import blocks of types from backup file fname. Encrypted blocks are decoded if session key is
+// set. Returns status of every type. Error is only returned if the backup can't be read
+func (s *BackupSession) DecodeItems(fname string, types []int) ([]ItemStatus, error) {
+	types, err := itemTypes("DecodeItems", types)
+	if err != nil {
+		return nil, err
+	}
+	key := s.GetkOp()
+	fileCrypt, err := openBackup("DecodeItems", fname, key)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[int][]byte)
+	versions := make(map[int]int)
+	for _, tag := range fileCrypt.ListTags() {
+		t, version, err := parseSourceTag(tag)
+		if err == nil {
+			tags[t], versions[t] = tag, version
+		}
+	}
+
+	status := make([]ItemStatus, 0, len(types))
+	for _, t := range types {
+		src := GetBackupSource(t)
+		item := ItemStatus{Type: t, Source: src.Name(), Status: ITEM_RESTORED}
+		tag, ok := tags[t]
+		if !ok {
+			item.Status = ITEM_MISSING
+			status = append(status, item)
+			continue
+		}
+		data, err := decryptBlock("DecodeItems", fileCrypt, tag, src, versions[t], key)
+		switch {
+		case err != nil:
+			item.Status, item.Err = ITEM_FAILED, err
+		case data == nil:
+			item.Status = ITEM_ENCRYPTED
+		default:
+			err = src.Import(s, data, versions[t])
+			if err != nil {
+				item.Status = ITEM_FAILED
+				item.Err = newError(ERR_INVALID_BACKUP, "DecodeItems", fmt.Errorf("Import %s : %w", src.Name(), err))
+			}
+		}
+		status = append(status, item)
+	}
+	return status, nil
+}
+
+// Restore identity items decoded from backup (PKEYS and STORAGE) in identity folder dir. Folder
+// can hold an existing identity, that must be stopped : backup keys are added to its key store, and
+// its storage is replaced. Returns status of every type
+func (s *BackupSession) RestoreIdentityItems(dir string, params keystore.KeyStoreParams, types []int) ([]ItemStatus, error) {
+	types, err := itemTypes("RestoreIdentityItems", types)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		if t != PKEYS && t != STORAGE {
+			return nil, newErrorf(ERR_INVALID_ARG, "RestoreIdentityItems", "Not an identity item : "+GetBackupSource(t).Name())
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, newError(ERR_IO, "RestoreIdentityItems", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := make([]ItemStatus, 0, len(types))
+	for _, t := range types {
+		item := ItemStatus{Type: t, Source: GetBackupSource(t).Name(), Status: ITEM_RESTORED}
+		var err error
+		switch {
+		case t == PKEYS && s.data.pK == nil, t == STORAGE && s.data.storageID == nil:
+			item.Status = ITEM_MISSING
+		case t == PKEYS:
+			_, err = restoreKStore(dir, params, s.data.pK, s.data.kOp)
+		case t == STORAGE:
+			err = restoreStorage(dir, s.data.storage, s.data.storageChain)
+		}
+		if err != nil {
+			item.Status = ITEM_FAILED
+			item.Err = newError(ERR_IDENTITY, "RestoreIdentityItems", fmt.Errorf("Restore %s : %w", item.Source, err))
+		}
+		status = append(status, item)
+	}
+	return status, nil
+}
+
+// Returns sorted types without duplicates. Types need a registered backup source
+func itemTypes(op string, types []int) ([]int, error) {
+	unique := make(map[int]bool, len(types))
+	sorted := make([]int, 0, len(types))
+	for _, t := range types {
+		if GetBackupSource(t) == nil {
+			return nil, newErrorf(ERR_INVALID_ARG, op, fmt.Sprintf("Unknown backup source %d", t))
+		}
+		if !unique[t] {
+			unique[t] = true
+			sorted = append(sorted, t)
+		}
+	}
+	if len(sorted) == 0 {
+		return nil, newErrorf(ERR_INVALID_ARG, op, "No items to restore")
+	}
+	sort.Ints(sorted)
+	return sorted, nil
+}
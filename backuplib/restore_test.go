@@ -0,0 +1,170 @@
+package backuplib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/iden3-mobile/go/iden3mobile"
+)
+
+func checkStatus(t *testing.T, status []ItemStatus, expected map[int]int) {
+	t.Helper()
+	if len(status) != len(expected) {
+		t.Fatal("Unexpected items", status)
+	}
+	for _, item := range status {
+		if s, ok := expected[item.Type]; !ok || item.Status != s || (s == ITEM_FAILED) != (item.Err != nil) {
+			t.Error("Unexpected item status", item)
+		}
+	}
+}
+
+func TestDecodeItems(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	// without key, encrypted items are not restored
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	wallet := session.GetWallet()
+	status, err := session.DecodeItems(folder+"backup.bk", []int{POLICY, WALLET_CONFIG, CUSTODIAN, POLICY})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{WALLET_CONFIG: ITEM_ENCRYPTED, CUSTODIAN: ITEM_RESTORED, POLICY: ITEM_RESTORED})
+	if status[0].Type != WALLET_CONFIG || status[0].Source != "wallet" || session.GetWallet() != wallet {
+		t.Error("Unexpected wallet status", status[0])
+	}
+	if !checkEqual(*original.GetCustodians(), *session.GetCustodians()) {
+		t.Error("Retrieved Custodians .... KO")
+	}
+
+	// only wallet config
+	session.SetkOp(original.GetkOp())
+	status, err = session.DecodeItems(folder+"backup.bk", []int{WALLET_CONFIG})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{WALLET_CONFIG: ITEM_RESTORED})
+	if !checkEqual(*original.GetWallet(), *session.GetWallet()) || session.GetPrivateKeys() != nil || session.GetStorage() != nil {
+		t.Error("Unexpected restored items")
+	}
+
+	// items missing in backup
+	partial, _ := NewBackupSession(nil, folder)
+	defer partial.Close()
+	partial.SetkOp(original.GetkOp())
+	checkOK(t, partial.AddToBackup(WALLET_CONFIG, ENCRYPT))
+	checkOK(t, partial.CreateBackup(folder+"partial.bk"))
+	status, err = session.DecodeItems(folder+"partial.bk", []int{WALLET_CONFIG, PKEYS, STORAGE})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{WALLET_CONFIG: ITEM_RESTORED, PKEYS: ITEM_MISSING, STORAGE: ITEM_MISSING})
+	if !checkEqual(*partial.GetWallet(), *session.GetWallet()) {
+		t.Error("Retrieved Wallet .... KO")
+	}
+
+	// wrong key
+	session.SetkOp(session.KeyOperational())
+	status, err = session.DecodeItems(folder+"backup.bk", []int{WALLET_CONFIG})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{WALLET_CONFIG: ITEM_FAILED})
+	checkErr(t, status[0].Err, ErrDecrypt)
+
+	_, err = session.DecodeItems(folder+"backup.bk", []int{NTYPES})
+	checkErr(t, err, ErrInvalidArg)
+	_, err = session.DecodeItems(folder+"backup.bk", nil)
+	checkErr(t, err, ErrInvalidArg)
+	_, err = session.DecodeItems(folder+"missing.bk", []int{WALLET_CONFIG})
+	checkErr(t, err, ErrIO)
+}
+
+// Package level API takes item types as comma separated strings and nicknames as JSON arrays
+func TestMobileItems(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	defaultSession = newDefaultSession()
+	defer func() { defaultSession = newDefaultSession() }()
+	SetkOp(original.GetkOp())
+	list, err := DecodeItems(folder+"backup.bk", fmt.Sprintf("%d, %d,%d", POLICY, WALLET_CONFIG, POLICY))
+	checkOK(t, err)
+	checkStatus(t, list.items, map[int]int{WALLET_CONFIG: ITEM_RESTORED, POLICY: ITEM_RESTORED})
+	if list.Len() != 2 || list.Get(0).Type != WALLET_CONFIG || list.Get(2) != nil || list.Get(-1) != nil {
+		t.Error("Unexpected item status list")
+	}
+	_, err = DecodeItems(folder+"backup.bk", "wallet")
+	checkErr(t, err, ErrInvalidArg)
+	_, err = DecodeItems(folder+"backup.bk", "")
+	checkErr(t, err, ErrInvalidArg)
+
+	needed, err := NeededCustodians(`["Pedrito","Faustino"]`)
+	checkOK(t, err)
+	expected, _ := original.NeededCustodians([]string{"Pedrito", "Faustino"})
+	var nicknames []string
+	if json.Unmarshal([]byte(needed), &nicknames) != nil || !checkEqual(nicknames, expected) {
+		t.Error("Unexpected needed custodians", needed)
+	}
+	_, err = NeededCustodians("Pedrito")
+	checkErr(t, err, ErrInvalidArg)
+
+	_, err = RestoreIdentityItems(folder, keystore.StandardKeyStoreParams, fmt.Sprint(WALLET_CONFIG))
+	checkErr(t, err, ErrInvalidArg)
+}
+
+func TestRestoreIdentityItems(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+	kOp := original.GetkOp()
+
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	session.SetkOp(kOp)
+	dir := folder + "identity"
+	checkOK(t, os.Mkdir(dir, 0700))
+
+	// nothing decoded yet
+	status, err := session.RestoreIdentityItems(dir, keystore.StandardKeyStoreParams, []int{PKEYS, STORAGE})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{PKEYS: ITEM_MISSING, STORAGE: ITEM_MISSING})
+
+	status, err = session.DecodeItems(folder+"backup.bk", []int{PKEYS, STORAGE})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{PKEYS: ITEM_RESTORED, STORAGE: ITEM_RESTORED})
+
+	// key store only, then storage of the same identity
+	status, err = session.RestoreIdentityItems(dir, keystore.StandardKeyStoreParams, []int{PKEYS})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{PKEYS: ITEM_RESTORED})
+	status, err = session.RestoreIdentityItems(dir, keystore.StandardKeyStoreParams, []int{STORAGE})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{STORAGE: ITEM_RESTORED})
+
+	// restore keys into the existing identity again
+	status, err = session.RestoreIdentityItems(dir, keystore.StandardKeyStoreParams, []int{PKEYS})
+	checkOK(t, err)
+	checkStatus(t, status, map[int]int{PKEYS: ITEM_RESTORED})
+
+	id, err := iden3mobile.NewIdentityLoad(dir, string(kOp), WEB3URL, HOLDER_TICKET_PERIOD, nil)
+	checkOK(t, err)
+	storage, ks := id.Export(kOp)
+	kv, _ := storage2KV(storage)
+	pK, err := keyStore2PK(ks, kOp)
+	id.Stop()
+	checkOK(t, err)
+	if !kvEqual(kv, original.GetStorage()) || len(pK.PK) != len(original.GetPrivateKeys().PK) {
+		t.Error("Unexpected restored identity")
+	}
+
+	_, err = session.RestoreIdentityItems(dir, keystore.StandardKeyStoreParams, []int{WALLET_CONFIG})
+	checkErr(t, err, ErrInvalidArg)
+	_, err = session.RestoreIdentityItems(folder+"missing", keystore.StandardKeyStoreParams, []int{PKEYS})
+	checkErr(t, err, ErrIO)
+}
@@ -0,0 +1,120 @@
+/*
+  Retention policies
+
+  A retention policy selects the backup generations to keep. Generations are kept if they are
+  selected by any rule :
+    - KeepLast : newest generations
+    - KeepDaily, KeepWeekly, KeepMonthly, KeepYearly : newest generation of each of the last n
+      days, ISO weeks, months or years, counting the current one
+
+  For example, keeping the last 3 backups, daily backups for a week and monthly backups for a year :
+
+    RetentionPolicy{KeepLast: 3, KeepDaily: 7, KeepMonthly: 12}
+
+  Periods are computed in UTC. The newest generation is always kept, and so are the generations
+  that the STORAGE block of a kept generation is built on (full base and incremental parents).
+*/
+
+package backuplib
+
+import (
+	"strconv"
+	"time"
+)
+
+type RetentionPolicy struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+}
+
+// Returns period of t, and period n periods before now
+type periodFunc func(t time.Time) string
+type periodBackFunc func(now time.Time, n int) time.Time
+
+func dayPeriod(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func weekPeriod(t time.Time) string {
+	year, week := t.ISOWeek()
+	return strconv.Itoa(year) + "W" + strconv.Itoa(week)
+}
+
+func monthPeriod(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+func yearPeriod(t time.Time) string {
+	return t.Format("2006")
+}
+
+func daysBack(now time.Time, n int) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()-n, 0, 0, 0, 0, time.UTC)
+}
+
+func weeksBack(now time.Time, n int) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()-7*n, 0, 0, 0, 0, time.UTC)
+}
+
+func monthsBack(now time.Time, n int) time.Time {
+	return time.Date(now.Year(), now.Month()-time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func yearsBack(now time.Time, n int) time.Time {
+	return time.Date(now.Year()-n, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Returns generations to keep at time now. Generations are sorted by time, oldest first
+func (p *RetentionPolicy) Keep(generations []Generation, now time.Time) []bool {
+	keep := make([]bool, len(generations))
+	if len(generations) == 0 {
+		return keep
+	}
+	now = now.UTC()
+
+	// newest generations
+	keep[len(generations)-1] = true
+	for idx := len(generations) - 1; idx >= 0 && idx >= len(generations)-p.KeepLast; idx-- {
+		keep[idx] = true
+	}
+
+	rules := []struct {
+		n      int
+		period periodFunc
+		back   periodBackFunc
+	}{
+		{p.KeepDaily, dayPeriod, daysBack},
+		{p.KeepWeekly, weekPeriod, weeksBack},
+		{p.KeepMonthly, monthPeriod, monthsBack},
+		{p.KeepYearly, yearPeriod, yearsBack},
+	}
+	for _, rule := range rules {
+		periods := make(map[string]bool, rule.n)
+		for n := 0; n < rule.n; n++ {
+			periods[rule.period(rule.back(now, n))] = true
+		}
+		// newest generation of every period
+		for idx := len(generations) - 1; idx >= 0; idx-- {
+			period := rule.period(generations[idx].Time.UTC())
+			if periods[period] {
+				keep[idx] = true
+				delete(periods, period)
+			}
+		}
+	}
+
+	// storage backups kept generations are built on. Parents are older, so chains are followed
+	// walking towards the oldest generation
+	for idx := len(generations) - 1; idx >= 0; idx-- {
+		if !keep[idx] {
+			continue
+		}
+		if parent, err := storageParent(generations, idx); err == nil && parent >= 0 {
+			keep[parent] = true
+		}
+	}
+	return keep
+}
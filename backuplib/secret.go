@@ -11,103 +11,153 @@ import (
 	"github.com/iden3/go-backup/shamir"
 )
 
+// Share encoded by the secret sharing scheme
 type Share struct {
-	Px int
-	Py []byte
+	Data []byte
 }
 
 type Shares struct {
 	Data []Share
 }
 
+// Share format of backups and custodian files created before shares were stored in the scheme
+// encoding. Px is the share index
+type legacyShare struct {
+	Px int
+	Py ff.Element
+}
+
 type Secret struct {
-	shamir.Shamir
+	SecretSharing
 }
 
-func GetNShares() int {
-	shares := GetShares()
-	return len(shares.Data)
+func (s *BackupSession) GetNShares() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data.secretShares.Data)
 }
 
-func GetShare(n int) *Share {
-	shares := GetShares()
-	if n < len(shares.Data) {
-		return &shares.Data[n]
+// Returns a copy of share n, or nil if n is out of range
+func (s *BackupSession) GetShare(n int) *Share {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shares := s.data.secretShares
+	if n >= 0 && n < len(shares.Data) {
+		return &Share{Data: clone(shares.Data[n].Data)}
 	} else {
 		return nil
 	}
 }
 
-// Generate shares from secret
-func GenerateShares(secret []byte) {
+// Generate random operational key. Key is the little endian encoding of an element of the secret
+// sharing element type, so that it can be shared with GenerateShares. Returns nil if element type
+// is not a prime field
+func (s *BackupSession) KeyOperational() []byte {
+	elType := PRIME
+	if secretCfg := s.GetSecretCfgOriginal(); secretCfg != nil {
+		elType = secretCfg.GetElType()
+	}
+	return keyOperational(elType)
+}
+
+// Generate shares from secret. Secret needs to be the little endian encoding of a field element
+// (see KeyOperational). Secrets that do not fit in an element are rejected and no shares are generated
+func (s *BackupSession) GenerateShares(secret []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	// convert secret to right format
-	secretFF, _ := ff.NewElement(PRIME)
-	secretFF.FromByte(secret)
-	secretCfg := GetSecretCfg()
-	sharesGo, _ := secretCfg.GenerateShares(secretFF)
-	sharesMobile := GetShares()
-	sharesMobile.Data = fromShares(sharesGo)
-	SetShares(sharesMobile)
+	secretCfg := s.data.secretCfg
+	if secretCfg == nil {
+		return newErrorf(ERR_SECRET_SHARING, "GenerateShares", "No Secret Sharing configuration")
+	}
+	secretFF, err := ff.EncodeBytes(secret, secretCfg.GetElType())
+	if err != nil {
+		return newError(ERR_INVALID_SECRET, "GenerateShares", err)
+	}
+	sharesGo, err := secretCfg.GenerateShares(secretFF)
+	if err != nil {
+		return newError(ERR_SECRET_SHARING, "GenerateShares", err)
+	}
+	s.data.secretShares.Data = fromShares(sharesGo)
+	return nil
 }
 
-func toShares(shares *Shares) []shamir.Share {
-	sharesGo := make([]shamir.Share, 0)
-	secretConfig := GetSecretCfg()
+func toShares(shares *Shares) [][]byte {
+	sharesGo := make([][]byte, 0)
 	for _, share := range shares.Data {
-		newEl, _ := ff.NewElement(secretConfig.GetElType())
-		newShareGo := shamir.Share{Px: share.Px,
-			Py: newEl.FromByte(share.Py)}
-		sharesGo = append(sharesGo, newShareGo)
+		sharesGo = append(sharesGo, clone(share.Data))
 	}
-
 	return sharesGo
 }
 
-func fromShares(shares []shamir.Share) []Share {
+func fromShares(shares [][]byte) []Share {
 	sharesMobile := make([]Share, 0)
 	for _, share := range shares {
-		newShareMobile := Share{Px: share.Px,
-			Py: share.Py.ToByte()}
-		sharesMobile = append(sharesMobile, newShareMobile)
+		sharesMobile = append(sharesMobile, Share{Data: clone(share)})
 	}
 	return sharesMobile
 }
 
+// Encode legacy shares as share envelopes of element type elType
+func fromLegacyShares(shares []legacyShare, elType int) [][]byte {
+	sharesGo := make([][]byte, 0)
+	for _, share := range shares {
+		px, err := ff.NewElement(elType)
+		if err != nil || share.Py == nil {
+			return nil
+		}
+		newShareGo := shamir.Share{Px: px.SetUint64(uint64(share.Px)), Py: share.Py}
+		sharesGo = append(sharesGo, newShareGo.Marshal(elType))
+	}
+	return sharesGo
+}
+
 // Generate secret from shares
-func GenerateKey() []byte {
-	sharesGo := toShares(GetShares())
-	return generateKey(sharesGo, GetSecretCfg())
+func (s *BackupSession) GenerateKey() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.secretCfg == nil {
+		return nil, newErrorf(ERR_SECRET_SHARING, "GenerateKey", "No Secret Sharing configuration")
+	}
+	return generateKey(toShares(s.data.secretShares), &Secret{s.data.secretCfg})
 }
 
-func generateKey(shares []shamir.Share, sharingCfg *Secret) []byte {
-	sharesPool := make([]shamir.Share, 0)
+func generateKey(shares [][]byte, sharingCfg *Secret) ([]byte, error) {
+	sharesPool := make([][]byte, 0)
 	for _, share := range shares {
 		sharesPool = append(sharesPool, share)
 		if len(sharesPool) == sharingCfg.GetMinShares() {
 			break
 		}
 	}
+	if len(sharesPool) < sharingCfg.GetMinShares() {
+		return nil, newErrorf(ERR_NOT_ENOUGH, "GenerateKey", "Not enough shares")
+	}
 	secret, err := sharingCfg.GenerateSecret(sharesPool)
 	if err != nil {
-		panic(err)
+		return nil, newError(ERR_INVALID_SHARE, "GenerateKey", err)
 	}
 
-	return secret.ToByte()
-}
-
-func initSecretCfg() {
-	var secretCfg Secret
-	cfg, err := shamir.NewConfig(MIN_N_SHARES, MAX_N_SHARES, PRIME)
+	n, _ := ff.ByteLen(sharingCfg.GetElType())
+	key, err := ff.DecodeBytes(secret, n)
 	if err != nil {
-		panic(err)
+		return nil, newError(ERR_INVALID_SECRET, "GenerateKey", err)
 	}
-	secretCfg.Shamir = *cfg
-	SetSecretCfg(&secretCfg)
+	return key, nil
+}
+
+func initSecretCfg() (SecretSharing, error) {
+	return NewSecretSharing(&SecretSharingCfg{
+		Scheme:      shamir.SCHEME_ID,
+		MinShares:   MIN_N_SHARES,
+		MaxShares:   MAX_N_SHARES,
+		ElementType: PRIME,
+	})
 }
 
-func initSecretShares() {
+func initSecretShares() *Shares {
 	var shares Shares
 	shareData := make([]Share, 0)
 	shares.Data = shareData
-	SetShares(&shares)
+	return &shares
 }
@@ -0,0 +1,95 @@
+/*
+  Backup Session
+
+  A BackupSession owns all the state needed to backup and restore one wallet : backup data,
+  backup registry, identity and its configuration. Different sessions are independent, so
+  several wallets or identities can be backed up in the same process. Methods can be called
+  concurrently from different goroutines.
+*/
+
+package backuplib
+
+import (
+	"os"
+	"sync"
+
+	"github.com/iden3/iden3-mobile/go/iden3mobile"
+)
+
+type BackupSession struct {
+	mu       sync.Mutex
+	data     BackupData            // Backup data
+	registry map[int]Backup        // Summary of contents of backup file
+	id       *iden3mobile.Identity // Identity
+	c        config                // Identity configuration
+	rmDirs   []string              // tmp dirs to be deleted
+
+	storageMode    int           // STORAGE_FULL, STORAGE_INCREMENTAL or STORAGE_DIFFERENTIAL
+	storageIndex   *storageIndex // Content hashes of storage in last backups
+	storagePending *storageIndex // Storage index after exported STORAGE block is backed up
+}
+
+// Create new session. If pass is not nil, a new identity protected by pass is created in folder
+func NewBackupSession(pass []byte, folder string) (*BackupSession, error) {
+	s := &BackupSession{}
+	err := s.init(pass, folder)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reset session contents
+func (s *BackupSession) Init(pass []byte, folder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.init(pass, folder)
+}
+
+func (s *BackupSession) init(pass []byte, folder string) error {
+	// init aux data in backup structure
+	s.data = BackupData{}
+	s.data.wallet = initWalletConfig()
+
+	// init Secret Sharing
+	secretCfg, err := initSecretCfg()
+	if err != nil {
+		return err
+	}
+	s.data.secretCfg = secretCfg
+
+	// init Secret Shares
+	s.data.secretShares = initSecretShares()
+
+	// init backup registry
+	s.registry = make(map[int]Backup)
+	s.storageMode = STORAGE_FULL
+	s.storageIndex = nil
+	s.storagePending = nil
+
+	// init Custodians
+	s.data.secretCustodians = initCustodians()
+
+	// init identity
+	return s.initIdentity(pass, folder)
+}
+
+// Stop identities and remove temporary folders created by the session
+func (s *BackupSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.id != nil {
+		s.id.Stop()
+		s.id = nil
+	}
+	for _, dir := range s.rmDirs {
+		os.RemoveAll(dir)
+	}
+	s.rmDirs = nil
+}
+
+func (s *BackupSession) identity() *iden3mobile.Identity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
@@ -0,0 +1,153 @@
+/*
+
+  Pluggable secret sharing schemes
+
+  Backup only depends on the SecretSharing interface. The configuration of the scheme is stored
+  in the unencrypted SSHARING block as a SecretSharingCfg, whose Scheme field selects the
+  implementation used during restore.
+
+*/
+
+package backuplib
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/iden3/go-backup/ff"
+	"github.com/iden3/go-backup/shamir"
+)
+
+// Shares are opaque to backup. Every scheme defines the encoding of its shares, that is stored
+// in the backup and distributed to custodians
+type SecretSharing interface {
+	GenerateShares(secret ff.Element) ([][]byte, error)
+	GenerateSecret(shares [][]byte) (ff.Element, error)
+	VerifyShare(share []byte) error
+	Scheme() string
+	GetMinShares() int
+	GetMaxShares() int
+	GetElType() int
+}
+
+// Secret sharing configuration stored in the backup
+type SecretSharingCfg struct {
+	Scheme      string
+	MinShares   int
+	MaxShares   int
+	ElementType int
+}
+
+// Build a secret sharing scheme from its configuration
+type SecretSharingBuilder func(cfg *SecretSharingCfg) (SecretSharing, error)
+
+var (
+	schemesMu      sync.RWMutex
+	sharingSchemes = map[string]SecretSharingBuilder{
+		shamir.SCHEME_ID: newShamirSharing,
+	}
+)
+
+// Register a new secret sharing scheme. Registered schemes can't be replaced
+func RegisterSecretSharing(scheme string, builder SecretSharingBuilder) error {
+	if scheme == "" || builder == nil {
+		return newErrorf(ERR_INVALID_ARG, "RegisterSecretSharing", "Invalid secret sharing scheme")
+	}
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	if _, ok := sharingSchemes[scheme]; ok {
+		return newErrorf(ERR_INVALID_ARG, "RegisterSecretSharing", "Secret sharing scheme already registered")
+	}
+	sharingSchemes[scheme] = builder
+	return nil
+}
+
+// Build secret sharing scheme identified by cfg.Scheme
+func NewSecretSharing(cfg *SecretSharingCfg) (SecretSharing, error) {
+	schemesMu.RLock()
+	builder, ok := sharingSchemes[cfg.Scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return nil, newErrorf(ERR_SECRET_SHARING, "NewSecretSharing", "Unknown Secret Sharing scheme "+cfg.Scheme)
+	}
+	s, err := builder(cfg)
+	if err != nil {
+		return nil, newError(ERR_SECRET_SHARING, "NewSecretSharing", err)
+	}
+	return s, nil
+}
+
+// Describe configuration of secret sharing scheme
+func describeSecretSharing(s SecretSharing) *SecretSharingCfg {
+	if s == nil {
+		return nil
+	}
+	return &SecretSharingCfg{
+		Scheme:      s.Scheme(),
+		MinShares:   s.GetMinShares(),
+		MaxShares:   s.GetMaxShares(),
+		ElementType: s.GetElType(),
+	}
+}
+
+// Shamir's secret sharing. Shares are encoded as shamir share envelopes
+type shamirSharing struct {
+	shamir.Shamir
+}
+
+func newShamirSharing(cfg *SecretSharingCfg) (SecretSharing, error) {
+	s, err := shamir.NewConfig(cfg.MinShares, cfg.MaxShares, cfg.ElementType)
+	if err != nil {
+		return nil, err
+	}
+	return shamirSharing{*s}, nil
+}
+
+func (s shamirSharing) GenerateShares(secret ff.Element) ([][]byte, error) {
+	shares, err := s.Shamir.GenerateShares(secret)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([][]byte, len(shares))
+	for idx, share := range shares {
+		encoded[idx] = share.Marshal(s.ElementType)
+	}
+	return encoded, nil
+}
+
+func (s shamirSharing) GenerateSecret(shares [][]byte) (ff.Element, error) {
+	decoded := make([]shamir.Share, len(shares))
+	for idx, share := range shares {
+		r, err := s.decodeShare(share)
+		if err != nil {
+			return nil, err
+		}
+		decoded[idx] = *r
+	}
+	return s.Shamir.GenerateSecret(decoded)
+}
+
+func (s shamirSharing) VerifyShare(share []byte) error {
+	r, err := s.decodeShare(share)
+	if err != nil {
+		return err
+	}
+	return s.Shamir.VerifyShare(*r)
+}
+
+// Decode share envelope. Share needs to belong to the configured field
+func (s shamirSharing) decodeShare(b []byte) (*shamir.Share, error) {
+	share, err := (&shamir.Share{}).Unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	el, err := ff.NewElement(s.ElementType)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(share.Py) != reflect.TypeOf(el) {
+		return nil, errors.New("Shamir's Secret : Share does not belong to configured field")
+	}
+	return share, nil
+}
@@ -0,0 +1,114 @@
+/*
+  Deduplicated snapshots
+
+  Backups can be kept in a deduplicated repository (see filecrypt/repo) instead of backup files.
+  Every block exported by the backup sources is stored as a snapshot item, named with the block
+  tag ("<type>.<version>"). Blocks are stored before filecrypt encryption (the repository encrypts
+  them), so data that doesn't change between backups is only stored once.
+*/
+
+package backuplib
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/iden3/go-backup/filecrypt/repo"
+)
+
+// Backup sources added to backup as a new snapshot in repository r. Sources are exported again, so
+// that snapshot captures their current state
+func (s *BackupSession) CreateSnapshot(r *repo.Repository, name string) (*repo.Snapshot, error) {
+	err := s.refreshBackup()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.registry) == 0 {
+		return nil, newErrorf(ERR_INVALID_ARG, "CreateSnapshot", "Empty backup")
+	}
+	items := make(map[string][]byte, len(s.registry))
+	for t, el := range s.registry {
+		data, err := encodeBlock(el.data)
+		if err != nil {
+			return nil, newError(ERR_SOURCE, "CreateSnapshot", err)
+		}
+		items[string(sourceTag(t, el.version))] = data
+	}
+	index := s.pendingStorageIndex()
+	snapshot, _, err := r.CreateSnapshot(name, items)
+	if err != nil {
+		return nil, repositoryError("CreateSnapshot", err)
+	}
+	s.commitStorageIndex(index)
+	return snapshot, nil
+}
+
+// Import blocks in snapshot id of repository r with the registered backup sources. Blocks from
+// unknown sources are ignored
+func (s *BackupSession) RestoreSnapshot(r *repo.Repository, id string) error {
+	items, err := r.Restore(id)
+	if err != nil {
+		return repositoryError("RestoreSnapshot", err)
+	}
+	// import in type order, as backup files
+	type block struct {
+		t, version int
+		data       []byte
+	}
+	blocks := make([]block, 0, len(items))
+	for tag, data := range items {
+		t, version, err := parseSourceTag([]byte(tag))
+		if err != nil {
+			continue
+		}
+		blocks = append(blocks, block{t, version, data})
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].t < blocks[j].t })
+
+	for _, b := range blocks {
+		src := GetBackupSource(b.t)
+		if src == nil {
+			continue
+		}
+		if b.version > src.Version() {
+			return newError(ERR_UNSUPPORTED, "RestoreSnapshot", fmt.Errorf("Unsupported %s schema version %d", src.Name(), b.version))
+		}
+		data, err := decodeBlock(b.data)
+		if err != nil {
+			return newError(ERR_INVALID_BACKUP, "RestoreSnapshot", fmt.Errorf("Decode %s : %w", src.Name(), err))
+		}
+		err = src.Import(s, data, b.version)
+		if err != nil {
+			return newError(ERR_INVALID_BACKUP, "RestoreSnapshot", fmt.Errorf("Import %s : %w", src.Name(), err))
+		}
+	}
+	return nil
+}
+
+// Encode block data as filecrypt does
+func encodeBlock(data interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(&data)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func decodeBlock(b []byte) (interface{}, error) {
+	var data interface{}
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	return data, err
+}
+
+func repositoryError(op string, err error) error {
+	if errors.Is(err, repo.ErrCorrupted) || errors.Is(err, repo.ErrNotRepository) {
+		return newError(ERR_INVALID_BACKUP, op, err)
+	}
+	return newError(ERR_STORE, op, err)
+}
@@ -0,0 +1,61 @@
+package backuplib
+
+import (
+	"os"
+	"testing"
+
+	"github.com/iden3/go-backup/filecrypt/repo"
+	"github.com/iden3/go-backup/store"
+)
+
+func TestSnapshots(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	original := createTestBackup(t, folder)
+	defer original.Close()
+
+	st, _ := store.NewLocalStore(folder + "repo")
+	r, err := repo.Init(st, original.GetkOp(), nil)
+	checkOK(t, err)
+
+	first, err := original.CreateSnapshot(r, "first")
+	checkOK(t, err)
+	if len(first.Items) != 7 {
+		t.Error("Unexpected snapshot items", first.Items)
+	}
+	objects, _ := st.List(repo.REPO_DATA_DIR)
+	nchunks := len(objects)
+
+	// unchanged sources are not stored again
+	original.SetWallet(&WalletConfig{})
+	second, err := original.CreateSnapshot(r, "second")
+	checkOK(t, err)
+	objects, _ = st.List(repo.REPO_DATA_DIR)
+	if len(objects)-nchunks > 2 {
+		t.Error("Unchanged sources stored again", len(objects)-nchunks)
+	}
+
+	// restore with a new session
+	r, err = repo.Open(st, original.GetkOp())
+	checkOK(t, err)
+	session, _ := NewBackupSession(nil, folder)
+	defer session.Close()
+	checkOK(t, session.RestoreSnapshot(r, second.ID))
+	if !checkEqual(*original.GetWallet(), *session.GetWallet()) {
+		t.Error("Retrieved Wallet .... KO")
+	}
+	if !checkEqual(*original.GetCustodians(), *session.GetCustodians()) {
+		t.Error("Retrieved Custodians .... KO")
+	}
+	if !kvEqual(original.GetStorage(), session.GetStorage()) {
+		t.Error("Retrieved Storage .... KO")
+	}
+	checkOK(t, session.RestoreSnapshot(r, first.ID))
+	if checkEqual(*original.GetWallet(), *session.GetWallet()) {
+		t.Error("Retrieved latest wallet")
+	}
+
+	err = session.RestoreSnapshot(r, "missing")
+	checkErr(t, err, ErrStore)
+}
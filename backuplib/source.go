@@ -0,0 +1,356 @@
+/*
+
+  Pluggable backup sources
+
+  Every type of data included in a backup file is described by a BackupSource, registered with
+  a unique type identifier. Backup blocks are tagged with the type identifier and the schema
+  version of the source that exported them ("<type>.<version>"), so that restore can hand every
+  block to the source that understands it. Blocks in backups created before versioned tags were
+  introduced are tagged with the type identifier only, and are imported as version 0.
+
+  Applications can register their own sources (claims DB, contacts, app settings, ...) with
+  type identifiers starting at USER_TYPES.
+
+*/
+
+package backuplib
+
+import (
+	"encoding/gob"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iden3/go-backup/ff"
+	"github.com/iden3/go-backup/shamir"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// First type identifier available to applications. Identifier is encoded in the block tag together
+// with the schema version, and tags are limited to 8 bytes
+const (
+	USER_TYPES     = 100
+	MAX_TYPE       = 9999
+	MAX_SRC_SCHEMA = 999
+)
+
+type BackupSource interface {
+	// Unique name of source
+	Name() string
+	// Schema version of exported data
+	Version() int
+	// Encryption policy (ENCRYPT or DONT_ENCRYPT) used when block is added with SOURCE_POLICY
+	Mode() int
+	// Values of the data types exported, to be registered with encoding/gob
+	Types() []interface{}
+	// Returns data to include in backup. If data is nil, block is not included
+	Export(s *BackupSession) (interface{}, error)
+	// Restore data exported by the source with schema version
+	Import(s *BackupSession, data interface{}, version int) error
+}
+
+type ExportFunc func(s *BackupSession) (interface{}, error)
+type ImportFunc func(s *BackupSession, data interface{}, version int) error
+
+// BackupSource built from functions
+type funcSource struct {
+	name       string
+	version    int
+	mode       int
+	types      []interface{}
+	exportData ExportFunc
+	importData ImportFunc
+}
+
+// Build a BackupSource from export and import functions
+func NewBackupSource(name string, version, mode int, types []interface{}, exportData ExportFunc, importData ImportFunc) BackupSource {
+	return &funcSource{
+		name:       name,
+		version:    version,
+		mode:       mode,
+		types:      types,
+		exportData: exportData,
+		importData: importData,
+	}
+}
+
+func (src *funcSource) Name() string         { return src.name }
+func (src *funcSource) Version() int         { return src.version }
+func (src *funcSource) Mode() int            { return src.mode }
+func (src *funcSource) Types() []interface{} { return src.types }
+
+func (src *funcSource) Export(s *BackupSession) (interface{}, error) {
+	return src.exportData(s)
+}
+
+func (src *funcSource) Import(s *BackupSession, data interface{}, version int) error {
+	return src.importData(s, data, version)
+}
+
+var (
+	sourcesMu     sync.RWMutex
+	backupSources = make(map[int]BackupSource)
+)
+
+func init() {
+	// legacy shares were gob encoded as shamir.Share values with PRIME elements
+	gob.RegisterName("github.com/iden3/go-backup/shamir.Share", legacyShare{})
+	gob.RegisterName("[]shamir.Share", []legacyShare{})
+
+	for t, src := range builtinSources() {
+		err := RegisterBackupSource(t, src)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register a new backup source with type identifier t
+func RegisterBackupSource(t int, src BackupSource) error {
+	if t <= START_TYPES || t > MAX_TYPE {
+		return newErrorf(ERR_INVALID_ARG, "RegisterBackupSource", "Invalid backup source type")
+	}
+	if src.Version() < 0 || src.Version() > MAX_SRC_SCHEMA {
+		return newErrorf(ERR_INVALID_ARG, "RegisterBackupSource", "Invalid backup source version")
+	}
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if _, ok := backupSources[t]; ok {
+		return newErrorf(ERR_INVALID_ARG, "RegisterBackupSource", "Backup source already registered")
+	}
+	for _, registered := range backupSources {
+		if registered.Name() == src.Name() {
+			return newErrorf(ERR_INVALID_ARG, "RegisterBackupSource", "Backup source name already registered")
+		}
+	}
+	for _, el := range src.Types() {
+		gob.Register(el)
+	}
+	backupSources[t] = src
+	return nil
+}
+
+// Returns backup source registered with type identifier t, or nil
+func GetBackupSource(t int) BackupSource {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return backupSources[t]
+}
+
+// Returns sorted type identifiers of registered backup sources
+func BackupSourceTypes() []int {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	types := make([]int, 0, len(backupSources))
+	for t := range backupSources {
+		types = append(types, t)
+	}
+	sort.Ints(types)
+	return types
+}
+
+// Block tag : "<type>.<version>"
+func sourceTag(t, version int) []byte {
+	return []byte(strconv.Itoa(t) + "." + strconv.Itoa(version))
+}
+
+// Returns type identifier and schema version from block tag. Legacy tags only include type
+func parseSourceTag(tag []byte) (int, int, error) {
+	fields := strings.Split(string(tag), ".")
+	if len(fields) > 2 {
+		return 0, 0, errors.New("Invalid block tag")
+	}
+	t, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, errors.New("Invalid block tag")
+	}
+	version := 0
+	if len(fields) == 2 {
+		version, err = strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, 0, errors.New("Invalid block tag")
+		}
+	}
+	return t, version, nil
+}
+
+// Sources included in the library
+func builtinSources() map[int]BackupSource {
+	el, _ := ff.NewElement(PRIME)
+	return map[int]BackupSource{
+		WALLET_CONFIG: NewBackupSource("wallet", 1, ENCRYPT,
+			[]interface{}{&WalletConfig{}},
+			exportWallet, importWallet),
+		CUSTODIAN: NewBackupSource("custodians", 1, DONT_ENCRYPT,
+			[]interface{}{&Custodians{}, []Custodian{}},
+			exportCustodians, importCustodians),
+		// backups created before scheme identifiers were introduced store shamir.Shamir
+		SSHARING: NewBackupSource("sharing", 1, DONT_ENCRYPT,
+			[]interface{}{&SecretSharingCfg{}, &shamir.Shamir{}},
+			exportSSharing, importSSharing),
+		// schema version 2 stores shares in the scheme encoding. Older versions store legacy shares
+		SHARES: NewBackupSource("shares", 2, ENCRYPT,
+			[]interface{}{el, &Shares{}},
+			exportShares, importShares),
+		PKEYS: NewBackupSource("privatekeys", 1, ENCRYPT,
+			[]interface{}{&PrivateKeys{}, (*babyjub.PrivateKey)(nil)},
+			exportPrivateKeys, importPrivateKeys),
+		// schema version 1 stores []db.KV
+		STORAGE: NewBackupSource("storage", 2, ENCRYPT,
+			[]interface{}{&db.KV{}, []db.KV{}, &StorageBackup{}},
+			exportStorage, importStorage),
+		POLICY: NewBackupSource("policy", 1, DONT_ENCRYPT,
+			[]interface{}{&shamir.Policy{}},
+			exportPolicy, importPolicy),
+	}
+}
+
+func exportWallet(s *BackupSession) (interface{}, error) {
+	return s.GetWallet(), nil
+}
+
+func importWallet(s *BackupSession, data interface{}, version int) error {
+	wallet := retrieveWallet([]interface{}{data})
+	if wallet == nil {
+		return errors.New("Invalid Wallet Format")
+	}
+	s.SetWallet(wallet)
+	return nil
+}
+
+func exportCustodians(s *BackupSession) (interface{}, error) {
+	return s.GetCustodians(), nil
+}
+
+func importCustodians(s *BackupSession, data interface{}, version int) error {
+	rxCustodians := retrieveCustodians([]interface{}{data})
+	if rxCustodians == nil {
+		return errors.New("Invalid Custodian Format")
+	}
+	custodians := initCustodians()
+	custodians.Data = rxCustodians
+	s.SetCustodians(custodians)
+	return nil
+}
+
+func exportSSharing(s *BackupSession) (interface{}, error) {
+	return describeSecretSharing(s.GetSecretCfgOriginal()), nil
+}
+
+func importSSharing(s *BackupSession, data interface{}, version int) error {
+	rxSecretCfg := retrieveSSharing([]interface{}{data})
+	if rxSecretCfg == nil {
+		return errors.New("Invalid Secret Sharing Format")
+	}
+	secretCfg, err := NewSecretSharing(rxSecretCfg)
+	if err != nil {
+		return err
+	}
+	s.SetSecretCfg(&Secret{secretCfg})
+	return nil
+}
+
+func exportShares(s *BackupSession) (interface{}, error) {
+	return s.GetShares(), nil
+}
+
+func importShares(s *BackupSession, data interface{}, version int) error {
+	retrievedShares := retrieveShares([]interface{}{data}, s.GetSecretCfg().GetElType())
+	if retrievedShares == nil {
+		return errors.New("Invalid shares Format")
+	}
+	s.SetShares(&Shares{Data: fromShares(retrievedShares)})
+	return nil
+}
+
+func exportPrivateKeys(s *BackupSession) (interface{}, error) {
+	id := s.identity()
+	if id == nil {
+		return nil, newErrorf(ERR_IDENTITY, "AddToBackup", "No identity")
+	}
+	pass := s.GetkOp()
+	_, keystore := id.Export(pass)
+	if keystore == nil {
+		return nil, newErrorf(ERR_IDENTITY, "AddToBackup", "Identity key store export failed")
+	}
+	pK, err := keyStore2PK(keystore, pass)
+	if err != nil {
+		return nil, newError(ERR_IDENTITY, "AddToBackup", err)
+	}
+	s.SetPrivateKeys(pK)
+	return s.GetPrivateKeys(), nil
+}
+
+func importPrivateKeys(s *BackupSession, data interface{}, version int) error {
+	retrievedPrivateKeys := retrievePrivateKeys([]interface{}{data})
+	if retrievedPrivateKeys == nil {
+		return errors.New("Invalid Private Keys Format")
+	}
+	s.SetPrivateKeys(retrievedPrivateKeys)
+	return nil
+}
+
+func exportStorage(s *BackupSession) (interface{}, error) {
+	id := s.identity()
+	if id == nil {
+		return nil, newErrorf(ERR_IDENTITY, "AddToBackup", "No identity")
+	}
+	storage, _ := id.Export(s.GetkOp())
+	if storage == nil {
+		return nil, newErrorf(ERR_IDENTITY, "AddToBackup", "Identity storage export failed")
+	}
+	kv, err := storage2KV(storage)
+	if err != nil {
+		return nil, newError(ERR_IDENTITY, "AddToBackup", err)
+	}
+
+	// storage index is updated when backup is created
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	block, pending := s.storageIndex.backup(kv, s.storageMode)
+	s.storagePending = pending
+	s.data.storage = kv
+	s.data.storageID = block.ID
+	s.data.storageChain = nil
+	return block, nil
+}
+
+func importStorage(s *BackupSession, data interface{}, version int) error {
+	if version < 2 {
+		retrievedStorage := retrieveStorage([]interface{}{data})
+		if retrievedStorage == nil {
+			return errors.New("Invalid Storage Format")
+		}
+		s.SetStorage(retrievedStorage)
+		return nil
+	}
+	block, ok := data.(*StorageBackup)
+	if !ok {
+		return errors.New("Invalid Storage Format")
+	}
+	return s.importStorageBlock(block)
+}
+
+func exportPolicy(s *BackupSession) (interface{}, error) {
+	policy := s.GetPolicy()
+	if policy == nil {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+func importPolicy(s *BackupSession, data interface{}, version int) error {
+	policy := retrievePolicy([]interface{}{data})
+	if policy == nil {
+		return errors.New("Invalid Policy Format")
+	}
+	// policy is checked against custodians and secret sharing configuration in NeededCustodians,
+	// as they may be imported afterwards
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.policy = policy
+	return nil
+}
@@ -0,0 +1,278 @@
+/*
+  Incremental and differential storage backups
+
+  Identity storage can be large, so STORAGE blocks don't need to include every KV pair :
+    - STORAGE_FULL : all KV pairs
+    - STORAGE_INCREMENTAL : KV pairs added, changed or deleted since the last backup
+    - STORAGE_DIFFERENTIAL : KV pairs added, changed or deleted since the last full backup
+
+  Changes are found comparing content hashes of KV pairs with a storage index, that records the
+  hashes included in the last full backup (base) and in the last backup. The index is updated when
+  a backup including STORAGE is created, and can be saved and loaded with SaveStorageIndex and
+  LoadStorageIndex. Without base, a full backup is generated.
+
+  Every block is identified by the hash of the storage contents after applying it. Restore imports
+  a full block and the chain of incremental (or differential) blocks built on it, and
+  restoreStorage replays the chain onto the base.
+*/
+
+package backuplib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"os"
+	"sort"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// Storage backup modes
+const (
+	STORAGE_FULL = iota
+	STORAGE_INCREMENTAL
+	STORAGE_DIFFERENTIAL
+)
+
+// STORAGE block (schema version 2)
+type StorageBackup struct {
+	Mode     int
+	ID       []byte   // hash of storage contents after applying block
+	BaseID   []byte   // full backup the block is built on. Empty for full backups
+	ParentID []byte   // backup the changes are relative to. BaseID for differential backups
+	Put      []db.KV  // KV pairs added or changed. All KV pairs for full backups
+	Deleted  [][]byte // keys deleted
+}
+
+// Content hashes of KV pairs, indexed by key
+type storageHashes map[string][]byte
+
+type storageState struct {
+	ID     []byte
+	Hashes storageHashes
+}
+
+// Storage index. Exported fields are encoded with SaveStorageIndex
+type storageIndex struct {
+	Base *storageState // last full backup
+	Last *storageState // last backup
+}
+
+// Content hashes of KV pairs
+func hashStorage(kv []db.KV) storageHashes {
+	hashes := make(storageHashes, len(kv))
+	for _, el := range kv {
+		h := sha256.Sum256(el.V)
+		hashes[string(el.K)] = h[:]
+	}
+	return hashes
+}
+
+// Storage identifier : hash of the sorted keys and the hashes of their values
+func (hashes storageHashes) id() []byte {
+	keys := make([]string, 0, len(hashes))
+	for k := range hashes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	var l [8]byte
+	for _, k := range keys {
+		binary.BigEndian.PutUint64(l[:], uint64(len(k)))
+		h.Write(l[:])
+		h.Write([]byte(k))
+		h.Write(hashes[k])
+	}
+	return h.Sum(nil)
+}
+
+// Returns KV pairs added or changed, and keys deleted since prev
+func diffStorage(kv []db.KV, hashes, prev storageHashes) ([]db.KV, [][]byte) {
+	put := make([]db.KV, 0)
+	for _, el := range kv {
+		if !bytes.Equal(prev[string(el.K)], hashes[string(el.K)]) {
+			put = append(put, el)
+		}
+	}
+	deleted := make([][]byte, 0)
+	for k := range prev {
+		if _, ok := hashes[k]; !ok {
+			deleted = append(deleted, []byte(k))
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return bytes.Compare(deleted[i], deleted[j]) < 0 })
+	return put, deleted
+}
+
+// Generate STORAGE block for storage contents kv. Returns block and index state after backup
+func (idx *storageIndex) backup(kv []db.KV, mode int) (*StorageBackup, *storageIndex) {
+	hashes := hashStorage(kv)
+	state := &storageState{ID: hashes.id(), Hashes: hashes}
+	if idx == nil || idx.Base == nil || mode == STORAGE_FULL {
+		return &StorageBackup{Mode: STORAGE_FULL, ID: state.ID, Put: kv}, &storageIndex{Base: state, Last: state}
+	}
+
+	prev := idx.Last
+	if mode == STORAGE_DIFFERENTIAL {
+		prev = idx.Base
+	}
+	put, deleted := diffStorage(kv, hashes, prev.Hashes)
+	return &StorageBackup{Mode: mode,
+		ID:       state.ID,
+		BaseID:   idx.Base.ID,
+		ParentID: prev.ID,
+		Put:      put,
+		Deleted:  deleted,
+	}, &storageIndex{Base: idx.Base, Last: state}
+}
+
+// Replay chain of incremental or differential blocks onto base storage contents. Returns
+// storage contents sorted by key
+func replayStorage(base []db.KV, chain []StorageBackup) ([]db.KV, error) {
+	contents := make(map[string][]byte, len(base))
+	for _, el := range base {
+		contents[string(el.K)] = el.V
+	}
+	for _, block := range chain {
+		for _, el := range block.Put {
+			contents[string(el.K)] = el.V
+		}
+		for _, k := range block.Deleted {
+			delete(contents, string(k))
+		}
+	}
+
+	kv := make([]db.KV, 0, len(contents))
+	for k, v := range contents {
+		kv = append(kv, db.KV{K: []byte(k), V: v})
+	}
+	sort.Slice(kv, func(i, j int) bool { return bytes.Compare(kv[i].K, kv[j].K) < 0 })
+	if len(chain) > 0 && !bytes.Equal(hashStorage(kv).id(), chain[len(chain)-1].ID) {
+		return nil, errors.New("Storage contents don't match backup")
+	}
+	return kv, nil
+}
+
+// Add STORAGE block to imported chain
+func (s *BackupSession) importStorageBlock(block *StorageBackup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if block.Mode == STORAGE_FULL {
+		if !bytes.Equal(hashStorage(block.Put).id(), block.ID) {
+			return errors.New("Storage contents don't match backup")
+		}
+		s.data.storage = append([]db.KV(nil), block.Put...)
+		s.data.storageID = block.ID
+		s.data.storageChain = nil
+		return nil
+	}
+	if block.Mode != STORAGE_INCREMENTAL && block.Mode != STORAGE_DIFFERENTIAL {
+		return errors.New("Invalid storage backup mode")
+	}
+	if s.data.storageID == nil || !bytes.Equal(block.BaseID, s.data.storageID) {
+		return errors.New("Storage base backup missing")
+	}
+	head := s.data.storageID
+	if len(s.data.storageChain) > 0 {
+		head = s.data.storageChain[len(s.data.storageChain)-1].ID
+	}
+	switch {
+	case bytes.Equal(block.ParentID, head):
+		s.data.storageChain = append(s.data.storageChain, *block)
+	case bytes.Equal(block.ParentID, block.BaseID):
+		// changes relative to base (differential backups) replace chain
+		s.data.storageChain = []StorageBackup{*block}
+	default:
+		return errors.New("Storage backup chain broken")
+	}
+	return nil
+}
+
+// Storage index to commit once the backup being created is stored, or nil if backup doesn't
+// include STORAGE. Called with lock held
+func (s *BackupSession) pendingStorageIndex() *storageIndex {
+	if _, ok := s.registry[STORAGE]; !ok {
+		return nil
+	}
+	return s.storagePending
+}
+
+// Next incremental storage backups are relative to the stored backup with storage index index.
+// Called with lock held
+func (s *BackupSession) commitStorageIndex(index *storageIndex) {
+	if index == nil {
+		return
+	}
+	s.storageIndex = index
+	if s.storagePending == index {
+		s.storagePending = nil
+	}
+}
+
+// Select how STORAGE is backed up (STORAGE_FULL, STORAGE_INCREMENTAL or STORAGE_DIFFERENTIAL)
+func (s *BackupSession) SetStorageBackupMode(mode int) error {
+	if mode != STORAGE_FULL && mode != STORAGE_INCREMENTAL && mode != STORAGE_DIFFERENTIAL {
+		return newErrorf(ERR_INVALID_ARG, "SetStorageBackupMode", "Invalid storage backup mode")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.storageMode = mode
+	return nil
+}
+
+// Returns storage contents restored from imported full backup and chain of incremental backups
+func (s *BackupSession) GetRestoredStorage() ([]db.KV, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kv, err := replayStorage(s.data.storage, s.data.storageChain)
+	if err != nil {
+		return nil, newError(ERR_INVALID_BACKUP, "GetRestoredStorage", err)
+	}
+	return kv, nil
+}
+
+// Save storage index to file fname, so that incremental backups can continue after the session
+// is closed
+func (s *BackupSession) SaveStorageIndex(fname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return newError(ERR_IO, "SaveStorageIndex", err)
+	}
+	defer file.Close()
+	index := s.storageIndex
+	if index == nil {
+		index = &storageIndex{}
+	}
+	err = gob.NewEncoder(file).Encode(index)
+	if err != nil {
+		return newError(ERR_IO, "SaveStorageIndex", err)
+	}
+	return nil
+}
+
+// Load storage index saved with SaveStorageIndex
+func (s *BackupSession) LoadStorageIndex(fname string) error {
+	file, err := os.Open(fname)
+	if err != nil {
+		return newError(ERR_IO, "LoadStorageIndex", err)
+	}
+	defer file.Close()
+	var index storageIndex
+	err = gob.NewDecoder(file).Decode(&index)
+	if err != nil {
+		return newError(ERR_INVALID_ARG, "LoadStorageIndex", err)
+	}
+	if index.Base == nil || index.Last == nil {
+		return newErrorf(ERR_INVALID_ARG, "LoadStorageIndex", "Empty storage index")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.storageIndex = &index
+	s.storagePending = nil
+	return nil
+}
@@ -0,0 +1,173 @@
+package backuplib
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/iden3/go-backup/store"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+)
+
+// failingStore fails every Put
+type failingStore struct {
+	store.BackupStore
+}
+
+func (f failingStore) Put(name string, data []byte) error {
+	return os.ErrPermission
+}
+
+func kvEqual(kv1, kv2 []db.KV) bool {
+	hashes1, hashes2 := hashStorage(kv1), hashStorage(kv2)
+	return len(kv1) == len(kv2) && bytes.Equal(hashes1.id(), hashes2.id())
+}
+
+func TestStorageChain(t *testing.T) {
+	s0 := []db.KV{{K: []byte("a"), V: []byte("1")}, {K: []byte("b"), V: []byte("2")}, {K: []byte("c"), V: []byte("3")}}
+	s1 := []db.KV{{K: []byte("a"), V: []byte("1")}, {K: []byte("b"), V: []byte("20")}, {K: []byte("c"), V: []byte("3")}, {K: []byte("d"), V: []byte("4")}}
+	s2 := []db.KV{{K: []byte("b"), V: []byte("20")}, {K: []byte("d"), V: []byte("4")}}
+
+	var index *storageIndex
+	full, index := index.backup(s0, STORAGE_INCREMENTAL)
+	if full.Mode != STORAGE_FULL || len(full.Put) != 3 {
+		t.Error("First backup is not full", full)
+	}
+	incr1, index := index.backup(s1, STORAGE_INCREMENTAL)
+	if incr1.Mode != STORAGE_INCREMENTAL || len(incr1.Put) != 2 || len(incr1.Deleted) != 0 ||
+		!bytes.Equal(incr1.ParentID, full.ID) || !bytes.Equal(incr1.BaseID, full.ID) {
+		t.Error("Unexpected incremental backup", incr1)
+	}
+	incr2, index := index.backup(s2, STORAGE_INCREMENTAL)
+	if len(incr2.Put) != 0 || len(incr2.Deleted) != 2 || !bytes.Equal(incr2.ParentID, incr1.ID) {
+		t.Error("Unexpected incremental backup", incr2)
+	}
+	diff, _ := index.backup(s2, STORAGE_DIFFERENTIAL)
+	if diff.Mode != STORAGE_DIFFERENTIAL || len(diff.Put) != 2 || len(diff.Deleted) != 2 || !bytes.Equal(diff.ParentID, full.ID) {
+		t.Error("Unexpected differential backup", diff)
+	}
+
+	// replay
+	kv, err := replayStorage(s0, []StorageBackup{*incr1, *incr2})
+	if err != nil || !kvEqual(kv, s2) {
+		t.Error("Unexpected replayed storage", kv, err)
+	}
+	if kv, err = replayStorage(s0, []StorageBackup{*diff}); err != nil || !kvEqual(kv, s2) {
+		t.Error("Unexpected replayed storage", kv, err)
+	}
+	if _, err = replayStorage(s0, []StorageBackup{*incr2}); err == nil {
+		t.Error("Incomplete chain replayed")
+	}
+
+	// import
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+	session, _ := NewBackupSession(nil, folder)
+	if session.importStorageBlock(incr1) == nil {
+		t.Error("Incremental backup imported without base")
+	}
+	checkOK(t, session.importStorageBlock(full))
+	if session.importStorageBlock(incr2) == nil {
+		t.Error("Incremental backup imported out of order")
+	}
+	checkOK(t, session.importStorageBlock(incr1))
+	checkOK(t, session.importStorageBlock(incr2))
+	if kv, err = session.GetRestoredStorage(); err != nil || !kvEqual(kv, s2) {
+		t.Error("Unexpected restored storage", kv, err)
+	}
+	// differential replaces chain
+	checkOK(t, session.importStorageBlock(diff))
+	if kv, err = session.GetRestoredStorage(); err != nil || !kvEqual(kv, s2) {
+		t.Error("Unexpected restored storage", kv, err)
+	}
+	tampered := *full
+	tampered.Put = s1
+	if session.importStorageBlock(&tampered) == nil {
+		t.Error("Tampered backup imported")
+	}
+	checkErr(t, session.SetStorageBackupMode(7), ErrInvalidArg)
+}
+
+func TestIncrementalBackup(t *testing.T) {
+	folder := tmpFolder(t)
+	defer os.RemoveAll(folder)
+
+	// backup.bk includes full storage
+	original := createTestBackup(t, folder)
+	defer original.Close()
+	full := original.registry[STORAGE].data.(*StorageBackup)
+	if full.Mode != STORAGE_FULL {
+		t.Error("Unexpected storage backup mode")
+	}
+
+	// add KV pairs to identity storage
+	storage, _ := original.identity().Export(original.GetkOp())
+	tx, _ := storage.NewTx()
+	tx.Put([]byte("new key 0"), []byte("value 0"))
+	checkOK(t, tx.Commit())
+
+	st, _ := store.NewLocalStore(folder + "remote")
+	g := NewGenerations(st, "")
+	checkOK(t, original.SetStorageBackupMode(STORAGE_INCREMENTAL))
+
+	// a generation that isn't stored doesn't move the storage index
+	_, err := original.CreateGeneration(NewGenerations(failingStore{st}, "failed"))
+	checkErr(t, err, ErrStore)
+	if !bytes.Equal(original.storageIndex.Last.ID, full.ID) {
+		t.Error("Storage index committed for a failed generation")
+	}
+
+	incr1, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	block := original.registry[STORAGE].data.(*StorageBackup)
+	if block.Mode != STORAGE_INCREMENTAL || len(block.Put) != 1 || !bytes.Equal(block.ParentID, full.ID) {
+		t.Error("Unexpected incremental backup", block.Mode, len(block.Put))
+	}
+
+	// an index loaded in a new session continues the chain
+	checkOK(t, original.SaveStorageIndex(folder+"index.dat"))
+	other, _ := NewBackupSession(nil, folder)
+	checkErr(t, other.LoadStorageIndex(folder+"missing.dat"), ErrIO)
+	checkOK(t, other.LoadStorageIndex(folder+"index.dat"))
+	if !bytes.Equal(other.storageIndex.Last.ID, block.ID) || !bytes.Equal(other.storageIndex.Base.ID, full.ID) {
+		t.Error("Unexpected storage index")
+	}
+
+	tx, _ = storage.NewTx()
+	tx.Put([]byte("new key 1"), []byte("value 1"))
+	tx.Put([]byte("new key 2"), []byte("value 2"))
+	checkOK(t, tx.Commit())
+	expected, _ := storage2KV(storage)
+
+	incr2, err := original.CreateGeneration(g)
+	checkOK(t, err)
+	block = original.registry[STORAGE].data.(*StorageBackup)
+	if block.Mode != STORAGE_INCREMENTAL || len(block.Put) != 2 || len(block.Deleted) != 0 || len(block.Put) >= len(full.Put) {
+		t.Error("Unexpected incremental backup", block.Mode, len(block.Put))
+	}
+
+	// restore full backup and incremental chain
+	restored, _ := NewBackupSession(nil, folder)
+	defer restored.Close()
+	restored.SetkOp(original.GetkOp())
+	checkOK(t, restored.FetchGeneration(g, incr1.ID, folder+"incr1.bk"))
+	checkOK(t, restored.FetchGeneration(g, incr2.ID, folder+"incr2.bk"))
+	checkErr(t, restored.DecodeEncrypted(folder+"incr1.bk"), ErrInvalidBackup)
+	checkOK(t, restored.DecodeEncrypted(folder+"backup.bk"))
+	checkErr(t, restored.DecodeEncrypted(folder+"incr2.bk"), ErrInvalidBackup)
+	checkOK(t, restored.DecodeEncrypted(folder+"incr1.bk"))
+	checkOK(t, restored.DecodeEncrypted(folder+"incr2.bk"))
+	kv, err := restored.GetRestoredStorage()
+	if err != nil || !kvEqual(kv, expected) {
+		t.Error("Unexpected restored storage", err)
+	}
+	id, err := restored.RestoreIdentity(folder, keystore.StandardKeyStoreParams)
+	checkOK(t, err)
+	restoredStorage, _ := id.Export(original.GetkOp())
+	kv, _ = storage2KV(restoredStorage)
+	id.Stop()
+	if !kvEqual(kv, expected) {
+		t.Error("Unexpected restored identity storage")
+	}
+}
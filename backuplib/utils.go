@@ -11,9 +11,6 @@ import (
 	"reflect"
 )
 
-// tmp dirs to be deleted
-var rmDirs []string
-
 // expected == obtained
 func checkEqual(expected, obtained interface{}) bool {
 	flag := false
@@ -38,11 +35,15 @@ func genRandomBytes(noncesize int) ([]byte, error) {
 	return nonce, nil
 }
 
-func KeyOperational() []byte {
-	key, _ := ff.NewElement(PRIME)
+// random element of type elType, little endian
+func keyOperational(elType int) []byte {
+	key, err := ff.NewElement(elType)
+	if err != nil {
+		return nil
+	}
 	key.SetRandom().ToMont()
 
-	return key.ToByte()
+	return key.ToBytesLE()
 }
 
 func clone(b0 []byte) []byte {
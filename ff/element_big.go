@@ -0,0 +1,382 @@
+/*
+ Generic finite field element backed by math/big.
+
+ Supports any prime modulus chosen at runtime. It is slower than goff generated elements, but follows
+ the same conventions so both can be used interchangeably through the Element interface:
+   - Elements are stored in Montgomery form (a * R mod q, with R = 2^(64 * limbs))
+   - GetUint64, SetFromArray, ToBigInt and SetRandom work with the Montgomery representation
+   - ToByte/FromByte use the little endian regular representation (limbs * 8 bytes)
+
+ New primes are registered with RegisterPrime, which returns an element type that can be used with
+ NewElement and IsValid. Element types are derived from the modulus, so they don't depend on the
+ registration order. Registration is process local, so data using a registered type can only be
+ decoded after registering the same prime again.
+*/
+
+package ff
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// First element type assigned to registered primes. Types need to fit in a byte
+const (
+	FF_BIG_START = 0x10
+	FF_BIG_END   = 0xfe
+)
+
+type bigField struct {
+	modulus *big.Int
+	limbs   int
+	r       *big.Int // R mod q
+	rInv    *big.Int // R^-1 mod q
+}
+
+type element_big struct {
+	v big.Int // Montgomery form
+	f *bigField
+}
+
+var bigFields = make(map[int]*bigField)
+var bigFieldsLock sync.RWMutex
+
+// Register prime modulus and return the element type identifying it. Element type is derived from
+// the modulus, so a prime gets the same type in every process. Registering the same prime again
+// returns the same element type. Primes deriving the type of a registered prime are rejected
+func RegisterPrime(modulus *big.Int) (int, error) {
+	if modulus == nil || modulus.Cmp(big.NewInt(2)) <= 0 || !modulus.ProbablyPrime(20) {
+		return 0, errors.New("Modulus needs to be an odd prime")
+	}
+	elType := bigElementType(modulus)
+
+	bigFieldsLock.Lock()
+	defer bigFieldsLock.Unlock()
+
+	if f, ok := bigFields[elType]; ok {
+		if f.modulus.Cmp(modulus) != 0 {
+			return 0, errors.New("Element type of modulus already assigned to a different prime")
+		}
+		return elType, nil
+	}
+	bigFields[elType] = newBigField(modulus)
+
+	return elType, nil
+}
+
+// Element type of modulus : SHA256 of its big endian encoding reduced to [FF_BIG_START, FF_BIG_END]
+func bigElementType(modulus *big.Int) int {
+	h := sha256.Sum256(modulus.Bytes())
+	return FF_BIG_START + int(binary.BigEndian.Uint32(h[:4])%(FF_BIG_END-FF_BIG_START+1))
+}
+
+func newBigField(modulus *big.Int) *bigField {
+	f := &bigField{
+		modulus: new(big.Int).Set(modulus),
+		limbs:   (modulus.BitLen() + 63) / 64,
+	}
+	R := new(big.Int).Lsh(big.NewInt(1), uint(64*f.limbs))
+	f.r = new(big.Int).Mod(R, modulus)
+	f.rInv = new(big.Int).ModInverse(f.r, modulus)
+	return f
+}
+
+func getBigField(elType int) *bigField {
+	bigFieldsLock.RLock()
+	defer bigFieldsLock.RUnlock()
+	return bigFields[elType]
+}
+
+// Returns modulus of a registered prime, or nil if element type is not registered
+func BigModulus(elType int) *big.Int {
+	f := getBigField(elType)
+	if f == nil {
+		return nil
+	}
+	return new(big.Int).Set(f.modulus)
+}
+
+func newElementBig(elType int) (Element, error) {
+	f := getBigField(elType)
+	if f == nil {
+		return nil, errors.New("Invalid FF type")
+	}
+	return &element_big{f: f}, nil
+}
+
+// Montgomery value of x as big.Int
+func (z *element_big) value(x Element) *big.Int {
+	if el, ok := x.(*element_big); ok {
+		return &el.v
+	}
+	return z.fromLimbs(x.GetUint64())
+}
+
+func (z *element_big) fromLimbs(xar []uint64) *big.Int {
+	b := make([]byte, 8*len(xar))
+	for idx, limb := range xar {
+		binary.BigEndian.PutUint64(b[8*(len(xar)-1-idx):], limb)
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func (z *element_big) reduce() Element {
+	z.v.Mod(&z.v, z.f.modulus)
+	return z
+}
+
+// GetUint64 returns Montgomery limbs, least significant first
+func (z *element_big) GetUint64() []uint64 {
+	b := z.v.Bytes()
+	padded := make([]byte, 8*z.f.limbs)
+	copy(padded[len(padded)-len(b):], b)
+	xar := make([]uint64, z.f.limbs)
+	for idx := range xar {
+		xar[idx] = binary.BigEndian.Uint64(padded[8*(z.f.limbs-1-idx):])
+	}
+	return xar
+}
+
+// SetUint64 z = v (regular form) and converts z to Montgomery form
+func (z *element_big) SetUint64(v uint64) Element {
+	z.v.SetUint64(v)
+	z.reduce()
+	return z.ToMont()
+}
+
+// SetFromArray sets z from regular form limbs and converts z to Montgomery form
+func (z *element_big) SetFromArray(xar []uint64) Element {
+	z.v.Set(z.fromLimbs(xar))
+	z.reduce()
+	return z.ToMont()
+}
+
+// Set z = x
+func (z *element_big) Set(x Element) Element {
+	z.v.Set(z.value(x))
+	return z
+}
+
+func (z *element_big) SetZero() Element {
+	z.v.SetUint64(0)
+	return z
+}
+
+// SetOne z = 1 (in Montgomery form)
+func (z *element_big) SetOne() Element {
+	z.v.Set(z.f.r)
+	return z
+}
+
+// Neg z = q - x
+func (z *element_big) Neg(x Element) Element {
+	z.v.Neg(z.value(x))
+	return z.reduce()
+}
+
+// Div z = x * y^-1 mod q
+func (z *element_big) Div(x, y Element) Element {
+	yInv := element_big{f: z.f}
+	yInv.Inverse(y)
+	return z.Mul(x, &yInv)
+}
+
+func (z *element_big) Equal(x Element) bool {
+	return z.v.Cmp(z.value(x)) == 0
+}
+
+func (z *element_big) IsZero() bool {
+	return z.v.Sign() == 0
+}
+
+// Inverse z = x^-1 mod q. If x == 0, sets and returns z = x
+func (z *element_big) Inverse(x Element) Element {
+	xv := z.value(x)
+	if xv.Sign() == 0 {
+		return z.Set(x)
+	}
+	// (a * R)^-1 * R^2 = a^-1 * R
+	inv := new(big.Int).ModInverse(xv, z.f.modulus)
+	z.v.Mul(inv, z.f.r)
+	z.v.Mul(&z.v, z.f.r)
+	return z.reduce()
+}
+
+// SetRandom sets z to a random element < q
+func (z *element_big) SetRandom() Element {
+	v, err := rand.Int(rand.Reader, z.f.modulus)
+	if err != nil {
+		panic(err)
+	}
+	z.v.Set(v)
+	return z
+}
+
+// One returns 1 (in Montgomery form)
+func (z *element_big) One() Element {
+	one := element_big{f: z.f}
+	return one.SetOne()
+}
+
+// Add z = x + y mod q
+func (z *element_big) Add(x, y Element) Element {
+	z.v.Add(z.value(x), z.value(y))
+	return z.reduce()
+}
+
+// AddAssign z = z + x mod q
+func (z *element_big) AddAssign(x Element) Element {
+	z.v.Add(&z.v, z.value(x))
+	return z.reduce()
+}
+
+// Double z = x + x mod q
+func (z *element_big) Double(x Element) Element {
+	z.v.Lsh(z.value(x), 1)
+	return z.reduce()
+}
+
+// Sub z = x - y mod q
+func (z *element_big) Sub(x, y Element) Element {
+	z.v.Sub(z.value(x), z.value(y))
+	return z.reduce()
+}
+
+// SubAssign z = z - x mod q
+func (z *element_big) SubAssign(x Element) Element {
+	z.v.Sub(&z.v, z.value(x))
+	return z.reduce()
+}
+
+// Exp z = x^exponent mod q
+// exponent (non-montgomery form) is ordered from least significant word to most significant word
+func (z *element_big) Exp(x Element, exponent ...uint64) Element {
+	e := z.fromLimbs(exponent)
+	if e.Sign() == 0 {
+		return z.SetOne()
+	}
+	var a big.Int
+	a.Mul(z.value(x), z.f.rInv)
+	a.Exp(&a, e, z.f.modulus)
+	z.v.Mul(&a, z.f.r)
+	return z.reduce()
+}
+
+// FromMont converts z in place (i.e. mutates) from Montgomery to regular representation
+func (z *element_big) FromMont() Element {
+	z.v.Mul(&z.v, z.f.rInv)
+	return z.reduce()
+}
+
+// ToMont converts z to Montgomery form
+func (z *element_big) ToMont() Element {
+	z.v.Mul(&z.v, z.f.r)
+	return z.reduce()
+}
+
+// ToRegular returns z in regular form (doesn't mutate z)
+func (z *element_big) ToRegular() Element {
+	r := element_big{f: z.f}
+	r.v.Set(&z.v)
+	return r.FromMont()
+}
+
+// String returns the decimal regular form of z
+func (z *element_big) String() string {
+	var r big.Int
+	return z.ToBigIntRegular(&r).String()
+}
+
+// ToByte returns the little endian byte form of z in regular form
+func (z *element_big) ToByte() []byte {
+	var r big.Int
+	be := z.ToBigIntRegular(&r).Bytes()
+	le := make([]byte, 8*z.f.limbs)
+	for idx, b := range be {
+		le[len(be)-1-idx] = b
+	}
+	return le
+}
+
+// FromByte sets z from the little endian byte form in regular form (mutates z)
+func (z *element_big) FromByte(x []byte) Element {
+	n := 8 * z.f.limbs
+	if len(x) < n {
+		n = len(x)
+	}
+	be := make([]byte, n)
+	for idx := range be {
+		be[n-1-idx] = x[idx]
+	}
+	z.v.SetBytes(be)
+	z.reduce()
+	return z.ToMont()
+}
+
+// ToBigInt returns z as a big.Int in Montgomery form
+func (z *element_big) ToBigInt(res *big.Int) *big.Int {
+	return res.Set(&z.v)
+}
+
+// ToBigIntRegular returns z as a big.Int in regular form
+func (z *element_big) ToBigIntRegular(res *big.Int) *big.Int {
+	res.Mul(&z.v, z.f.rInv)
+	return res.Mod(res, z.f.modulus)
+}
+
+// SetBigInt sets z to v (regular form) and returns z in Montgomery form
+func (z *element_big) SetBigInt(v *big.Int) Element {
+	z.v.Set(v)
+	z.reduce()
+	return z.ToMont()
+}
+
+// SetString sets z to s (in base 10) and returns z in Montgomery form
+func (z *element_big) SetString(s string) Element {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("element_big.SetString failed -> can't parse number in base10 into a big.Int")
+	}
+	return z.SetBigInt(x)
+}
+
+// Legendre returns the Legendre symbol of z (either +1, -1, or 0.)
+func (z *element_big) Legendre() int {
+	var r big.Int
+	return big.Jacobi(z.ToBigIntRegular(&r), z.f.modulus)
+}
+
+// Sqrt z = √x mod q
+// if the square root doesn't exist (x is not a square mod q)
+// Sqrt leaves z unchanged and returns nil
+func (z *element_big) Sqrt(x Element) Element {
+	var a, r big.Int
+	a.Mul(z.value(x), z.f.rInv)
+	a.Mod(&a, z.f.modulus)
+	if r.ModSqrt(&a, z.f.modulus) == nil {
+		return nil
+	}
+	z.v.Mul(&r, z.f.r)
+	return z.reduce()
+}
+
+// Mul z = x * y mod q (Montgomery product)
+func (z *element_big) Mul(x, y Element) Element {
+	z.v.Mul(z.value(x), z.value(y))
+	z.v.Mul(&z.v, z.f.rInv)
+	return z.reduce()
+}
+
+// MulAssign z = z * x mod q
+func (z *element_big) MulAssign(x Element) Element {
+	return z.Mul(z, x)
+}
+
+// Square z = x * x mod q
+func (z *element_big) Square(x Element) Element {
+	return z.Mul(x, x)
+}
@@ -0,0 +1,251 @@
+package ff
+
+import (
+	"crypto/rand"
+	"math/big"
+	mrand "math/rand"
+	"testing"
+)
+
+// Cross test element_big against goff generated BN256 elements. Both use R = 2^256, so
+// Montgomery representations need to match
+func TestElementBigVsBN256(t *testing.T) {
+	for _, optType := range []int{FF_BN256_FP, FF_BN256_FQ} {
+		ref, _ := NewElement(optType)
+		modulus := ref.SetOne().Neg(ref).ToBigIntRegular(new(big.Int))
+		modulus.Add(modulus, big.NewInt(1))
+
+		bigType, err := RegisterPrime(modulus)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n := 500
+		if testing.Short() {
+			n = 20
+		}
+		for i := 0; i < n; i++ {
+			b1, _ := rand.Int(rand.Reader, modulus)
+			b2, _ := rand.Int(rand.Reader, modulus)
+			rExp := mrand.Uint64()
+			switch i {
+			case 0:
+				b1.SetUint64(0)
+			case 1:
+				b2.SetUint64(0)
+			case 2:
+				b1.Sub(modulus, big.NewInt(1))
+				b2.Sub(modulus, big.NewInt(1))
+			case 3:
+				rExp = 0
+			}
+
+			o1, _ := NewElement(optType)
+			o2, _ := NewElement(optType)
+			e1, _ := NewElement(bigType)
+			e2, _ := NewElement(bigType)
+			o1.SetBigInt(b1)
+			o2.SetBigInt(b2)
+			e1.SetBigInt(b1)
+			e2.SetBigInt(b2)
+
+			cmp := func(o, e Element, name string) {
+				if !equalLimbs(o.GetUint64(), e.GetUint64()) {
+					t.Fatal(name, "failed")
+				}
+			}
+			newPair := func() (Element, Element) {
+				o, _ := NewElement(optType)
+				e, _ := NewElement(bigType)
+				return o, e
+			}
+
+			cmp(o1, e1, "SetBigInt")
+			o, e := newPair()
+			cmp(o.Mul(o1, o2), e.Mul(e1, e2), "Mul")
+			o, e = newPair()
+			cmp(o.Square(o1), e.Square(e1), "Square")
+			o, e = newPair()
+			cmp(o.Add(o1, o2), e.Add(e1, e2), "Add")
+			o, e = newPair()
+			cmp(o.Sub(o1, o2), e.Sub(e1, e2), "Sub")
+			o, e = newPair()
+			cmp(o.Double(o1), e.Double(e1), "Double")
+			o, e = newPair()
+			cmp(o.Neg(o1), e.Neg(e1), "Neg")
+			o, e = newPair()
+			cmp(o.Inverse(o1), e.Inverse(e1), "Inverse")
+			o, e = newPair()
+			cmp(o.Div(o1, o2), e.Div(e1, e2), "Div")
+			o, e = newPair()
+			cmp(o.Exp(o1, rExp), e.Exp(e1, rExp), "Exp")
+			o, e = newPair()
+			cmp(o.Set(o1).MulAssign(o2), e.Set(e1).MulAssign(e2), "MulAssign")
+			o, e = newPair()
+			cmp(o.Set(o1).AddAssign(o2), e.Set(e1).AddAssign(e2), "AddAssign")
+			o, e = newPair()
+			cmp(o.Set(o1).SubAssign(o2), e.Set(e1).SubAssign(e2), "SubAssign")
+			o, e = newPair()
+			cmp(o.Set(o1).FromMont(), e.Set(e1).FromMont(), "FromMont")
+			o, e = newPair()
+			cmp(o.Set(o1).ToMont(), e.Set(e1).ToMont(), "ToMont")
+			cmp(o1.ToRegular(), e1.ToRegular(), "ToRegular")
+			o, e = newPair()
+			cmp(o.SetUint64(rExp), e.SetUint64(rExp), "SetUint64")
+			o, e = newPair()
+			cmp(o.FromByte(o1.ToByte()), e.FromByte(e1.ToByte()), "FromByte")
+			o, e = newPair()
+			cmp(o.SetString(b1.String()), e.SetString(b1.String()), "SetString")
+			cmp(o1.One(), e1.One(), "One")
+
+			// mixing element implementations
+			o, e = newPair()
+			cmp(o.Mul(o1, o2), e.Mul(o1, e2), "Mixed Mul")
+
+			if string(o1.ToByte()) != string(e1.ToByte()) {
+				t.Fatal("ToByte failed")
+			}
+			if o1.String() != e1.String() {
+				t.Fatal("String failed")
+			}
+			if o1.Legendre() != e1.Legendre() {
+				t.Fatal("Legendre failed")
+			}
+			if o1.Equal(o2) != e1.Equal(e2) || o1.IsZero() != e1.IsZero() {
+				t.Fatal("Equal failed")
+			}
+			if i < 10 {
+				o, e = newPair()
+				oSqrt, eSqrt := o.Sqrt(o1), e.Sqrt(e1)
+				if (oSqrt == nil) != (eSqrt == nil) {
+					t.Fatal("Sqrt failed")
+				}
+				if oSqrt != nil {
+					// both roots are valid, compare squares
+					cmp(oSqrt.Square(oSqrt), eSqrt.Square(eSqrt), "Sqrt")
+					cmp(o1, eSqrt, "Sqrt")
+				}
+			}
+		}
+	}
+}
+
+func equalLimbs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// Check element_big against big.Int for moduli of different sizes
+func TestElementBigCorrectnessAgainstBigInt(t *testing.T) {
+	mersenne61 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 61), big.NewInt(1))
+	mersenne521 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 521), big.NewInt(1))
+	p97 := big.NewInt(97)
+
+	for _, modulus := range []*big.Int{p97, mersenne61, mersenne521} {
+		elType, err := RegisterPrime(modulus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsValid(elType) || BigModulus(elType).Cmp(modulus) != 0 {
+			t.Fatal("Prime not registered")
+		}
+		for i := 0; i < 100; i++ {
+			b1, _ := rand.Int(rand.Reader, modulus)
+			b2, _ := rand.Int(rand.Reader, modulus)
+			e1, _ := NewElement(elType)
+			e2, _ := NewElement(elType)
+			e1.SetBigInt(b1)
+			e2.SetBigInt(b2)
+
+			var r, expected big.Int
+			e, _ := NewElement(elType)
+			expected.Mul(b1, b2).Mod(&expected, modulus)
+			if e.Mul(e1, e2).ToBigIntRegular(&r).Cmp(&expected) != 0 {
+				t.Fatal("Mul failed")
+			}
+			expected.Sub(b1, b2).Mod(&expected, modulus)
+			if e.Sub(e1, e2).ToBigIntRegular(&r).Cmp(&expected) != 0 {
+				t.Fatal("Sub failed")
+			}
+			if b2.Sign() != 0 {
+				expected.ModInverse(b2, modulus)
+				if e.Inverse(e2).ToBigIntRegular(&r).Cmp(&expected) != 0 {
+					t.Fatal("Inverse failed")
+				}
+			}
+			e.Sqrt(e1)
+			if big.Jacobi(b1, modulus) >= 0 {
+				expected.Set(b1)
+				if e.Square(e).ToBigIntRegular(&r).Cmp(&expected) != 0 {
+					t.Fatal("Sqrt failed")
+				}
+			}
+			if len(e1.ToByte()) != 8*len(e1.GetUint64()) {
+				t.Fatal("ToByte length")
+			}
+			if !e.FromByte(e1.ToByte()).Equal(e1) {
+				t.Fatal("FromByte failed")
+			}
+		}
+	}
+}
+
+func TestElementBigRegister(t *testing.T) {
+	modulus := big.NewInt(1000003)
+	elType1, err1 := RegisterPrime(modulus)
+	elType2, err2 := RegisterPrime(big.NewInt(1000003))
+	if err1 != nil || err2 != nil || elType1 != elType2 {
+		t.Error("Same prime registered twice")
+	}
+	if elType1 < FF_BIG_START || elType1 > FF_BIG_END || elType1 != bigElementType(modulus) {
+		t.Error("Invalid element type")
+	}
+
+	// element type only depends on modulus. A different prime with the same type is rejected
+	other := new(big.Int).Add(modulus, big.NewInt(2))
+	for !other.ProbablyPrime(20) || bigElementType(other) != elType1 {
+		other.Add(other, big.NewInt(2))
+	}
+	if _, err := RegisterPrime(other); err == nil {
+		t.Error("Prime with registered element type accepted")
+	}
+	if BigModulus(elType1).Cmp(modulus) != 0 {
+		t.Error("Registered prime replaced")
+	}
+
+	for _, invalid := range []*big.Int{nil, big.NewInt(2), big.NewInt(15), big.NewInt(-7)} {
+		_, err := RegisterPrime(invalid)
+		if err == nil {
+			t.Error("Invalid modulus registered", invalid)
+		}
+	}
+	if IsValid(FF_BIG_END + 1) {
+		t.Error("Element is invalid")
+	}
+	_, err := NewElement(FF_BIG_END + 1)
+	if err == nil {
+		t.Error("Element is invalid")
+	}
+}
+
+func BenchmarkMulElementBig(b *testing.B) {
+	el, _ := NewElement(FF_BN256_FP)
+	modulus := el.SetOne().Neg(el).ToBigIntRegular(new(big.Int))
+	modulus.Add(modulus, big.NewInt(1))
+	elType, _ := RegisterPrime(modulus)
+	x, _ := NewElement(elType)
+	res, _ := NewElement(elType)
+	x.SetRandom()
+	res.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res.MulAssign(x)
+	}
+}
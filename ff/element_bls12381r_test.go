@@ -0,0 +1,362 @@
+// Copyright 2020 ConsenSys AG
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code adapted from goff (v0.2.2) generated code for the BLS12-381 scalar field.
+// Pure Go implementation (no assembly)
+
+// Package ff contains field arithmetic operations
+package ff
+
+import (
+	"crypto/rand"
+	"math/big"
+	"math/bits"
+	mrand "math/rand"
+	"testing"
+)
+
+func TestELEMENT_BLS12381RCorrectnessAgainstBigInt(t *testing.T) {
+	modulus, _ := new(big.Int).SetString("52435875175126190479447740508185965837690552500527637822603658699938581184513", 10)
+	cmpEandB := func(e *element_bls12381r, b *big.Int, name string) {
+		var _e big.Int
+		if e.FromMont().ToBigInt(&_e).Cmp(b) != 0 {
+			t.Fatal(name, "failed")
+		}
+	}
+	var modulusMinusOne, one big.Int
+	one.SetUint64(1)
+
+	modulusMinusOne.Sub(modulus, &one)
+
+	var n int
+	if testing.Short() {
+		n = 20
+	} else {
+		n = 500
+	}
+
+	sAdx := supportAdx
+
+	for i := 0; i < n; i++ {
+		if i == n/2 && sAdx {
+			supportAdx = false // testing without adx instruction
+		}
+		// sample 2 random big int
+		b1, _ := rand.Int(rand.Reader, modulus)
+		b2, _ := rand.Int(rand.Reader, modulus)
+		rExp := mrand.Uint64()
+
+		// adding edge cases
+		// TODO need more edge cases
+		switch i {
+		case 0:
+			rExp = 0
+			b1.SetUint64(0)
+		case 1:
+			b2.SetUint64(0)
+		case 2:
+			b1.SetUint64(0)
+			b2.SetUint64(0)
+		case 3:
+			rExp = 0
+		case 4:
+			rExp = 1
+		case 5:
+			rExp = ^uint64(0) // max uint
+		case 6:
+			rExp = 2
+			b1.Set(&modulusMinusOne)
+		case 7:
+			b2.Set(&modulusMinusOne)
+		case 8:
+			b1.Set(&modulusMinusOne)
+			b2.Set(&modulusMinusOne)
+		}
+
+		rbExp := new(big.Int).SetUint64(rExp)
+
+		var bMul, bAdd, bSub, bDiv, bNeg, bLsh, bInv, bExp, bExp2, bSquare big.Int
+
+		// e1 = mont(b1), e2 = mont(b2)
+		var e1, e2, eMul, eAdd, eSub, eDiv, eNeg, eLsh, eInv, eExp, eSquare, eMulAssign, eSubAssign, eAddAssign element_bls12381r
+		e1.SetBigInt(b1)
+		e2.SetBigInt(b2)
+
+		// (e1*e2).FromMont() === b1*b2 mod q ... etc
+		eSquare.Square(&e1)
+		eMul.Mul(&e1, &e2)
+		eMulAssign.Set(&e1)
+		eMulAssign.MulAssign(&e2)
+		eAdd.Add(&e1, &e2)
+		eAddAssign.Set(&e1)
+		eAddAssign.AddAssign(&e2)
+		eSub.Sub(&e1, &e2)
+		eSubAssign.Set(&e1)
+		eSubAssign.SubAssign(&e2)
+		eDiv.Div(&e1, &e2)
+		eNeg.Neg(&e1)
+		eInv.Inverse(&e1)
+		eExp.Exp(&e1, rExp)
+
+		eLsh.Double(&e1)
+
+		// same operations with big int
+		bAdd.Add(b1, b2).Mod(&bAdd, modulus)
+		bMul.Mul(b1, b2).Mod(&bMul, modulus)
+		bSquare.Mul(b1, b1).Mod(&bSquare, modulus)
+		bSub.Sub(b1, b2).Mod(&bSub, modulus)
+		bDiv.ModInverse(b2, modulus)
+		bDiv.Mul(&bDiv, b1).
+			Mod(&bDiv, modulus)
+		bNeg.Neg(b1).Mod(&bNeg, modulus)
+
+		bInv.ModInverse(b1, modulus)
+		bExp.Exp(b1, rbExp, modulus)
+		bLsh.Lsh(b1, 1).Mod(&bLsh, modulus)
+
+		cmpEandB(&eSquare, &bSquare, "Square")
+		cmpEandB(&eMul, &bMul, "Mul")
+		cmpEandB(&eMulAssign, &bMul, "MulAssign")
+		cmpEandB(&eAdd, &bAdd, "Add")
+		cmpEandB(&eAddAssign, &bAdd, "AddAssign")
+		cmpEandB(&eSub, &bSub, "Sub")
+		cmpEandB(&eSubAssign, &bSub, "SubAssign")
+		cmpEandB(&eDiv, &bDiv, "Div")
+		cmpEandB(&eNeg, &bNeg, "Neg")
+		cmpEandB(&eInv, &bInv, "Inv")
+		cmpEandB(&eExp, &bExp, "Exp")
+
+		cmpEandB(&eLsh, &bLsh, "Lsh")
+
+		// legendre symbol
+		if e1.Legendre() != big.Jacobi(b1, modulus) {
+			t.Fatal("legendre symbol computation failed")
+		}
+		if e2.Legendre() != big.Jacobi(b2, modulus) {
+			t.Fatal("legendre symbol computation failed")
+		}
+
+		// these are slow, killing circle ci
+		if n <= 5 {
+			// sqrt
+			var eSqrt, eExp2 element_bls12381r
+			var bSqrt big.Int
+			bSqrt.ModSqrt(b1, modulus)
+			eSqrt.Sqrt(&e1)
+			cmpEandB(&eSqrt, &bSqrt, "Sqrt")
+
+			bits := b2.Bits()
+			exponent := make([]uint64, len(bits))
+			for k := 0; k < len(bits); k++ {
+				exponent[k] = uint64(bits[k])
+			}
+			eExp2.Exp(&e1, exponent...)
+
+			bExp2.Exp(b1, b2, modulus)
+			cmpEandB(&eExp2, &bExp2, "Exp multi words")
+		}
+	}
+	supportAdx = sAdx
+}
+
+func TestELEMENT_BLS12381RIsRandom(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		var x, y element_bls12381r
+		x.SetRandom()
+		y.SetRandom()
+		if x.Equal(&y) {
+			t.Fatal("2 random numbers are unlikely to be equal")
+		}
+	}
+}
+
+// -------------------------------------------------------------------------------------------------
+// benchmarks
+// most benchmarks are rudimentary and should sample a large number of random inputs
+// or be run multiple times to ensure it didn't measure the fastest path of the function
+
+var benchReselement_bls12381r element_bls12381r
+
+func BenchmarkInverseELEMENT_BLS12381R(b *testing.B) {
+	var x element_bls12381r
+	x.SetRandom()
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Inverse(&x)
+	}
+
+}
+func BenchmarkExpELEMENT_BLS12381R(b *testing.B) {
+	var x element_bls12381r
+	x.SetRandom()
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Exp(&x, mrand.Uint64())
+
+	}
+}
+
+func BenchmarkDoubleELEMENT_BLS12381R(b *testing.B) {
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Double(&benchReselement_bls12381r)
+	}
+}
+
+func BenchmarkAddELEMENT_BLS12381R(b *testing.B) {
+	var x element_bls12381r
+	x.SetRandom()
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Add(&x, &benchReselement_bls12381r)
+	}
+}
+
+func BenchmarkSubELEMENT_BLS12381R(b *testing.B) {
+	var x element_bls12381r
+	x.SetRandom()
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Sub(&x, &benchReselement_bls12381r)
+	}
+}
+
+func BenchmarkNegELEMENT_BLS12381R(b *testing.B) {
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Neg(&benchReselement_bls12381r)
+	}
+}
+
+func BenchmarkDivELEMENT_BLS12381R(b *testing.B) {
+	var x element_bls12381r
+	x.SetRandom()
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Div(&x, &benchReselement_bls12381r)
+	}
+}
+
+func BenchmarkFromMontELEMENT_BLS12381R(b *testing.B) {
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.FromMont()
+	}
+}
+
+func BenchmarkToMontELEMENT_BLS12381R(b *testing.B) {
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.ToMont()
+	}
+}
+func BenchmarkSquareELEMENT_BLS12381R(b *testing.B) {
+	benchReselement_bls12381r.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Square(&benchReselement_bls12381r)
+	}
+}
+
+func BenchmarkSqrtELEMENT_BLS12381R(b *testing.B) {
+	var a element_bls12381r
+	a.SetRandom()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.Sqrt(&a)
+	}
+}
+
+func BenchmarkMulAssignELEMENT_BLS12381R(b *testing.B) {
+	x := element_bls12381r{
+		14526898881837571181,
+		3129137299524312099,
+		419701826671360399,
+		524908885293268753,
+	}
+	benchReselement_bls12381r.SetOne()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchReselement_bls12381r.MulAssign(&x)
+	}
+}
+
+func TestELEMENT_BLS12381Rreduce(t *testing.T) {
+	q := element_bls12381r{
+		18446744069414584321,
+		6034159408538082302,
+		3691218898639771653,
+		8353516859464449352,
+	}
+
+	var testData []element_bls12381r
+	{
+		a := q
+		a[3] -= 1
+		testData = append(testData, a)
+	}
+	{
+		a := q
+		a[0] -= 1
+		testData = append(testData, a)
+	}
+	{
+		a := q
+		a[3] += 1
+		testData = append(testData, a)
+	}
+	{
+		a := q
+		a[0] += 1
+		testData = append(testData, a)
+	}
+	{
+		a := q
+		testData = append(testData, a)
+	}
+
+	for _, s := range testData {
+		expected := s
+		reduceelement_bls12381r(&s)
+		expected.testReduce()
+		if !s.Equal(&expected) {
+			t.Fatal("reduce failed")
+		}
+	}
+
+}
+
+func (z *element_bls12381r) testReduce() *element_bls12381r {
+
+	// if z > q --> z -= q
+	// note: this is NOT constant time
+	if !(z[3] < 8353516859464449352 || (z[3] == 8353516859464449352 && (z[2] < 3691218898639771653 || (z[2] == 3691218898639771653 && (z[1] < 6034159408538082302 || (z[1] == 6034159408538082302 && (z[0] < 18446744069414584321))))))) {
+		var b uint64
+		z[0], b = bits.Sub64(z[0], 18446744069414584321, 0)
+		z[1], b = bits.Sub64(z[1], 6034159408538082302, b)
+		z[2], b = bits.Sub64(z[2], 3691218898639771653, b)
+		z[3], _ = bits.Sub64(z[3], 8353516859464449352, b)
+	}
+	return z
+}
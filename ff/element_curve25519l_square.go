@@ -0,0 +1,94 @@
+// Copyright 2020 ConsenSys AG
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code adapted from goff (v0.2.2) generated code for the Curve25519 group order.
+// Pure Go implementation (no assembly)
+
+// Package ff contains field arithmetic operations
+package ff
+
+// /!\ WARNING /!\
+// this code has not been audited and is provided as-is. In particular,
+// there is no security guarantees such as constant time implementation
+// or side-channel attack resistance
+// /!\ WARNING /!\
+
+import "math/bits"
+
+// Square z = x * x mod q
+// see https://hackmd.io/@zkteam/modular_multiplication
+func (z *element_curve25519l) Square(x Element) Element {
+
+	var xar = x.GetUint64()
+
+	var p [4]uint64
+
+	var u, v uint64
+	{
+		// round 0
+		u, p[0] = bits.Mul64(xar[0], xar[0])
+		m := p[0] * 15183074304973897243
+		C := madd0(m, 6346243789798364141, p[0])
+		var t uint64
+		t, u, v = madd1sb(xar[0], xar[1], u)
+		C, p[0] = madd2(m, 1503914060200516822, v, C)
+		t, u, v = madd1s(xar[0], xar[2], t, u)
+		C, p[1] = madd2(m, 0, v, C)
+		_, u, v = madd1s(xar[0], xar[3], t, u)
+		p[3], p[2] = madd3(m, 1152921504606846976, v, C, u)
+	}
+	{
+		// round 1
+		m := p[0] * 15183074304973897243
+		C := madd0(m, 6346243789798364141, p[0])
+		u, v = madd1(xar[1], xar[1], p[1])
+		C, p[0] = madd2(m, 1503914060200516822, v, C)
+		var t uint64
+		t, u, v = madd2sb(xar[1], xar[2], p[2], u)
+		C, p[1] = madd2(m, 0, v, C)
+		_, u, v = madd2s(xar[1], xar[3], p[3], t, u)
+		p[3], p[2] = madd3(m, 1152921504606846976, v, C, u)
+	}
+	{
+		// round 2
+		m := p[0] * 15183074304973897243
+		C := madd0(m, 6346243789798364141, p[0])
+		C, p[0] = madd2(m, 1503914060200516822, p[1], C)
+		u, v = madd1(xar[2], xar[2], p[2])
+		C, p[1] = madd2(m, 0, v, C)
+		_, u, v = madd2sb(xar[2], xar[3], p[3], u)
+		p[3], p[2] = madd3(m, 1152921504606846976, v, C, u)
+	}
+	{
+		// round 3
+		m := p[0] * 15183074304973897243
+		C := madd0(m, 6346243789798364141, p[0])
+		C, z[0] = madd2(m, 1503914060200516822, p[1], C)
+		C, z[1] = madd2(m, 0, p[2], C)
+		u, v = madd1(xar[3], xar[3], p[3])
+		z[3], z[2] = madd3(m, 1152921504606846976, v, C, u)
+	}
+
+	// if z > q --> z -= q
+	// note: this is NOT constant time
+	if !(z[3] < 1152921504606846976 || (z[3] == 1152921504606846976 && (z[2] < 0 || (z[2] == 0 && (z[1] < 1503914060200516822 || (z[1] == 1503914060200516822 && (z[0] < 6346243789798364141))))))) {
+		var b uint64
+		z[0], b = bits.Sub64(z[0], 6346243789798364141, 0)
+		z[1], b = bits.Sub64(z[1], 1503914060200516822, b)
+		z[2], b = bits.Sub64(z[2], 0, b)
+		z[3], _ = bits.Sub64(z[3], 1152921504606846976, b)
+	}
+	return z
+
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 ConsenSys AG
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code adapted from goff (v0.2.2) generated code for the secp256k1 group order.
+// Pure Go implementation (no assembly)
+
+// Package ff contains field arithmetic operations
+package ff
+
+// /!\ WARNING /!\
+// this code has not been audited and is provided as-is. In particular,
+// there is no security guarantees such as constant time implementation
+// or side-channel attack resistance
+// /!\ WARNING /!\
+
+import "math/bits"
+
+// Mul z = x * y mod q
+// q uses all the bits of the last word, so the CIOS montgomery multiplication
+// keeps an extra carry word
+func (z *element_secp256k1n) Mul(x, y Element) Element {
+	z.mul(x.GetUint64(), y.GetUint64())
+	return z
+}
+
+// MulAssign z = z * x mod q
+func (z *element_secp256k1n) MulAssign(x Element) Element {
+	z.mul(z.GetUint64(), x.GetUint64())
+	return z
+}
+
+func (z *element_secp256k1n) mul(xar, yar []uint64) {
+
+	var t [5]uint64
+	var C, D uint64
+	for i := 0; i < 4; i++ {
+		// t = t + xar[i] * yar
+		C, t[0] = madd1(xar[i], yar[0], t[0])
+		C, t[1] = madd2(xar[i], yar[1], t[1], C)
+		C, t[2] = madd2(xar[i], yar[2], t[2], C)
+		C, t[3] = madd2(xar[i], yar[3], t[3], C)
+		t[4], D = bits.Add64(t[4], C, 0)
+
+		// t = (t + m * q) / 2^64
+		m := t[0] * 5408259542528602431
+		C = madd0(m, 13822214165235122497, t[0])
+		C, t[0] = madd2(m, 13451932020343611451, t[1], C)
+		C, t[1] = madd2(m, 18446744073709551614, t[2], C)
+		C, t[2] = madd2(m, 18446744073709551615, t[3], C)
+		t[3], C = bits.Add64(t[4], C, 0)
+		t[4] = D + C
+	}
+	z[0], z[1], z[2], z[3] = t[0], t[1], t[2], t[3]
+
+	// if z > q --> z -= q
+	// note: this is NOT constant time
+	if t[4] != 0 || !(z[3] < 18446744073709551615 || (z[3] == 18446744073709551615 && (z[2] < 18446744073709551614 || (z[2] == 18446744073709551614 && (z[1] < 13451932020343611451 || (z[1] == 13451932020343611451 && (z[0] < 13822214165235122497))))))) {
+		var b uint64
+		z[0], b = bits.Sub64(z[0], 13822214165235122497, 0)
+		z[1], b = bits.Sub64(z[1], 13451932020343611451, b)
+		z[2], b = bits.Sub64(z[2], 18446744073709551614, b)
+		z[3], _ = bits.Sub64(z[3], 18446744073709551615, b)
+	}
+}
+
+func mulAssignelement_secp256k1n(res, y *element_secp256k1n) {
+	res.MulAssign(y)
+}
+
+// fromMontelement_secp256k1n sets res = res * 1
+// with a modified CIOS montgomery multiplication
+func fromMontelement_secp256k1n(res *element_secp256k1n) {
+	z := res
+	{
+		// m = z[0]n'[0] mod W
+		m := z[0] * 5408259542528602431
+		C := madd0(m, 13822214165235122497, z[0])
+		C, z[0] = madd2(m, 13451932020343611451, z[1], C)
+		C, z[1] = madd2(m, 18446744073709551614, z[2], C)
+		C, z[2] = madd2(m, 18446744073709551615, z[3], C)
+		z[3] = C
+	}
+	{
+		// m = z[0]n'[0] mod W
+		m := z[0] * 5408259542528602431
+		C := madd0(m, 13822214165235122497, z[0])
+		C, z[0] = madd2(m, 13451932020343611451, z[1], C)
+		C, z[1] = madd2(m, 18446744073709551614, z[2], C)
+		C, z[2] = madd2(m, 18446744073709551615, z[3], C)
+		z[3] = C
+	}
+	{
+		// m = z[0]n'[0] mod W
+		m := z[0] * 5408259542528602431
+		C := madd0(m, 13822214165235122497, z[0])
+		C, z[0] = madd2(m, 13451932020343611451, z[1], C)
+		C, z[1] = madd2(m, 18446744073709551614, z[2], C)
+		C, z[2] = madd2(m, 18446744073709551615, z[3], C)
+		z[3] = C
+	}
+	{
+		// m = z[0]n'[0] mod W
+		m := z[0] * 5408259542528602431
+		C := madd0(m, 13822214165235122497, z[0])
+		C, z[0] = madd2(m, 13451932020343611451, z[1], C)
+		C, z[1] = madd2(m, 18446744073709551614, z[2], C)
+		C, z[2] = madd2(m, 18446744073709551615, z[3], C)
+		z[3] = C
+	}
+	reduceelement_secp256k1n(res)
+}
+
+// reduceelement_secp256k1n sets res = res mod q, for res < 2q
+func reduceelement_secp256k1n(res *element_secp256k1n) {
+	z := res
+
+	// if z > q --> z -= q
+	// note: this is NOT constant time
+	if !(z[3] < 18446744073709551615 || (z[3] == 18446744073709551615 && (z[2] < 18446744073709551614 || (z[2] == 18446744073709551614 && (z[1] < 13451932020343611451 || (z[1] == 13451932020343611451 && (z[0] < 13822214165235122497))))))) {
+		var b uint64
+		z[0], b = bits.Sub64(z[0], 13822214165235122497, 0)
+		z[1], b = bits.Sub64(z[1], 13451932020343611451, b)
+		z[2], b = bits.Sub64(z[2], 18446744073709551614, b)
+		z[3], _ = bits.Sub64(z[3], 18446744073709551615, b)
+	}
+}
@@ -0,0 +1,401 @@
+/*
+ Canonical serialization of finite field elements.
+
+ Elements are encoded in regular (non Montgomery) form:
+   - Binary : fixed length big endian or little endian integer of ByteLen(q) = ceil(bits(q) / 8) bytes
+   - Text   : "0x" followed by the big endian binary encoding in hex (MarshalText), or decimal (MarshalJSON)
+ Decoding is strict. Binary encodings need to have exactly ByteLen(q) bytes, and every encoding needs to
+ represent an integer below the modulus. Values are never reduced.
+
+ UnmarshalText and UnmarshalJSON accept both hex ("0x" prefix) and decimal strings. UnmarshalJSON also
+ accepts JSON numbers.
+*/
+
+package ff
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Returns number of bytes of the canonical binary encoding of elements of the given type
+func ByteLen(elType int) (int, error) {
+	el, err := NewElement(elType)
+	if err != nil {
+		return 0, err
+	}
+	return len(el.ToBytesBE()), nil
+}
+
+// Errors returned when encoding bytes as elements
+var (
+	ErrBytesTooLong    = errors.New("Bytes longer than element encoding")
+	ErrElementOverflow = errors.New("Bytes encode a value larger than modulus")
+	ErrBytesTooShort   = errors.New("Element does not fit in requested length")
+)
+
+// Encode b as an element. b is read as a little endian integer (same layout as ToByte and ToBytesLE),
+// so it can have at most ByteLen(elType) bytes and needs to encode a value below the modulus. Bytes
+// are never reduced: ErrBytesTooLong or ErrElementOverflow are returned instead
+func EncodeBytes(b []byte, elType int) (Element, error) {
+	el, err := NewElement(elType)
+	if err != nil {
+		return nil, err
+	}
+	n := len(el.ToBytesLE())
+	if len(b) > n {
+		return nil, ErrBytesTooLong
+	}
+	padded := make([]byte, n)
+	copy(padded, b)
+	if _, err = el.SetBytesLE(padded); err != nil {
+		return nil, ErrElementOverflow
+	}
+	return el, nil
+}
+
+// Decode n bytes encoded with EncodeBytes. Returns ErrBytesTooShort if the element does not fit in n bytes
+func DecodeBytes(el Element, n int) ([]byte, error) {
+	le := el.ToBytesLE()
+	if n < len(le) {
+		for _, v := range le[n:] {
+			if v != 0 {
+				return nil, ErrBytesTooShort
+			}
+		}
+		return le[:n], nil
+	}
+	b := make([]byte, n)
+	copy(b, le)
+	return b, nil
+}
+
+// Returns modulus of element type
+func modulusOf(z Element) *big.Int {
+	switch el := z.(type) {
+	case *element_bn256p:
+		return element_bn256pModulus()
+	case *element_bn256q:
+		return element_bn256qModulus()
+	case *element_bls12381r:
+		return element_bls12381rModulus()
+	case *element_secp256k1n:
+		return element_secp256k1nModulus()
+	case *element_curve25519l:
+		return element_curve25519lModulus()
+	case *element_big:
+		return el.f.modulus
+	}
+	return nil
+}
+
+func modulusByteLen(modulus *big.Int) int {
+	return (modulus.BitLen() + 7) / 8
+}
+
+func toBytesBE(z Element, modulus *big.Int) []byte {
+	var v big.Int
+	vb := z.ToBigIntRegular(&v).Bytes()
+	b := make([]byte, modulusByteLen(modulus))
+	copy(b[len(b)-len(vb):], vb)
+	return b
+}
+
+func toBytesLE(z Element, modulus *big.Int) []byte {
+	return reverseBytes(toBytesBE(z, modulus))
+}
+
+func setBytesBE(z Element, b []byte, modulus *big.Int) (Element, error) {
+	if len(b) != modulusByteLen(modulus) {
+		return nil, errors.New("Invalid element encoding length")
+	}
+	return setCanonical(z, new(big.Int).SetBytes(b), modulus)
+}
+
+func setBytesLE(z Element, b []byte, modulus *big.Int) (Element, error) {
+	return setBytesBE(z, reverseBytes(b), modulus)
+}
+
+// Set z = v if 0 <= v < modulus
+func setCanonical(z Element, v *big.Int, modulus *big.Int) (Element, error) {
+	if v.Sign() < 0 || v.Cmp(modulus) >= 0 {
+		return nil, errors.New("Element out of range")
+	}
+	return z.SetBigInt(v), nil
+}
+
+func marshalText(z Element, modulus *big.Int) ([]byte, error) {
+	return []byte("0x" + hex.EncodeToString(toBytesBE(z, modulus))), nil
+}
+
+func unmarshalText(z Element, text []byte, modulus *big.Int) error {
+	s := string(text)
+	v := new(big.Int)
+	ok := false
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		digits := s[2:]
+		if len(digits)%2 != 0 {
+			digits = "0" + digits
+		}
+		b, err := hex.DecodeString(digits)
+		if err != nil || len(digits) == 0 {
+			return errors.New("Invalid element hex encoding")
+		}
+		v.SetBytes(b)
+		ok = true
+	} else if len(s) > 0 && s[0] != '+' && s[0] != '-' {
+		_, ok = v.SetString(s, 10)
+	}
+	if !ok {
+		return errors.New("Invalid element text encoding")
+	}
+	_, err := setCanonical(z, v, modulus)
+	return err
+}
+
+func marshalJSON(z Element) ([]byte, error) {
+	var v big.Int
+	return json.Marshal(z.ToBigIntRegular(&v).String())
+}
+
+func unmarshalJSON(z Element, data []byte, modulus *big.Int) error {
+	var s string
+	if len(data) > 0 && data[0] != '"' {
+		// JSON number
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		s = n.String()
+	} else if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return unmarshalText(z, []byte(s), modulus)
+}
+
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for idx, v := range b {
+		r[len(b)-1-idx] = v
+	}
+	return r
+}
+
+// ToBytesBE returns the canonical big endian encoding of z in regular form
+func (z *element_bn256p) ToBytesBE() []byte {
+	return toBytesBE(z, element_bn256pModulus())
+}
+
+// ToBytesLE returns the canonical little endian encoding of z in regular form
+func (z *element_bn256p) ToBytesLE() []byte {
+	return toBytesLE(z, element_bn256pModulus())
+}
+
+// SetBytesBE sets z from its canonical big endian encoding and returns z in Montgomery form
+func (z *element_bn256p) SetBytesBE(b []byte) (Element, error) {
+	return setBytesBE(z, b, element_bn256pModulus())
+}
+
+// SetBytesLE sets z from its canonical little endian encoding and returns z in Montgomery form
+func (z *element_bn256p) SetBytesLE(b []byte) (Element, error) {
+	return setBytesLE(z, b, element_bn256pModulus())
+}
+
+func (z *element_bn256p) MarshalText() ([]byte, error) {
+	return marshalText(z, element_bn256pModulus())
+}
+
+func (z *element_bn256p) UnmarshalText(text []byte) error {
+	return unmarshalText(z, text, element_bn256pModulus())
+}
+
+func (z *element_bn256p) MarshalJSON() ([]byte, error) {
+	return marshalJSON(z)
+}
+
+func (z *element_bn256p) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(z, data, element_bn256pModulus())
+}
+
+// ToBytesBE returns the canonical big endian encoding of z in regular form
+func (z *element_bn256q) ToBytesBE() []byte {
+	return toBytesBE(z, element_bn256qModulus())
+}
+
+// ToBytesLE returns the canonical little endian encoding of z in regular form
+func (z *element_bn256q) ToBytesLE() []byte {
+	return toBytesLE(z, element_bn256qModulus())
+}
+
+// SetBytesBE sets z from its canonical big endian encoding and returns z in Montgomery form
+func (z *element_bn256q) SetBytesBE(b []byte) (Element, error) {
+	return setBytesBE(z, b, element_bn256qModulus())
+}
+
+// SetBytesLE sets z from its canonical little endian encoding and returns z in Montgomery form
+func (z *element_bn256q) SetBytesLE(b []byte) (Element, error) {
+	return setBytesLE(z, b, element_bn256qModulus())
+}
+
+func (z *element_bn256q) MarshalText() ([]byte, error) {
+	return marshalText(z, element_bn256qModulus())
+}
+
+func (z *element_bn256q) UnmarshalText(text []byte) error {
+	return unmarshalText(z, text, element_bn256qModulus())
+}
+
+func (z *element_bn256q) MarshalJSON() ([]byte, error) {
+	return marshalJSON(z)
+}
+
+func (z *element_bn256q) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(z, data, element_bn256qModulus())
+}
+
+// ToBytesBE returns the canonical big endian encoding of z in regular form
+func (z *element_bls12381r) ToBytesBE() []byte {
+	return toBytesBE(z, element_bls12381rModulus())
+}
+
+// ToBytesLE returns the canonical little endian encoding of z in regular form
+func (z *element_bls12381r) ToBytesLE() []byte {
+	return toBytesLE(z, element_bls12381rModulus())
+}
+
+// SetBytesBE sets z from its canonical big endian encoding and returns z in Montgomery form
+func (z *element_bls12381r) SetBytesBE(b []byte) (Element, error) {
+	return setBytesBE(z, b, element_bls12381rModulus())
+}
+
+// SetBytesLE sets z from its canonical little endian encoding and returns z in Montgomery form
+func (z *element_bls12381r) SetBytesLE(b []byte) (Element, error) {
+	return setBytesLE(z, b, element_bls12381rModulus())
+}
+
+func (z *element_bls12381r) MarshalText() ([]byte, error) {
+	return marshalText(z, element_bls12381rModulus())
+}
+
+func (z *element_bls12381r) UnmarshalText(text []byte) error {
+	return unmarshalText(z, text, element_bls12381rModulus())
+}
+
+func (z *element_bls12381r) MarshalJSON() ([]byte, error) {
+	return marshalJSON(z)
+}
+
+func (z *element_bls12381r) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(z, data, element_bls12381rModulus())
+}
+
+// ToBytesBE returns the canonical big endian encoding of z in regular form
+func (z *element_secp256k1n) ToBytesBE() []byte {
+	return toBytesBE(z, element_secp256k1nModulus())
+}
+
+// ToBytesLE returns the canonical little endian encoding of z in regular form
+func (z *element_secp256k1n) ToBytesLE() []byte {
+	return toBytesLE(z, element_secp256k1nModulus())
+}
+
+// SetBytesBE sets z from its canonical big endian encoding and returns z in Montgomery form
+func (z *element_secp256k1n) SetBytesBE(b []byte) (Element, error) {
+	return setBytesBE(z, b, element_secp256k1nModulus())
+}
+
+// SetBytesLE sets z from its canonical little endian encoding and returns z in Montgomery form
+func (z *element_secp256k1n) SetBytesLE(b []byte) (Element, error) {
+	return setBytesLE(z, b, element_secp256k1nModulus())
+}
+
+func (z *element_secp256k1n) MarshalText() ([]byte, error) {
+	return marshalText(z, element_secp256k1nModulus())
+}
+
+func (z *element_secp256k1n) UnmarshalText(text []byte) error {
+	return unmarshalText(z, text, element_secp256k1nModulus())
+}
+
+func (z *element_secp256k1n) MarshalJSON() ([]byte, error) {
+	return marshalJSON(z)
+}
+
+func (z *element_secp256k1n) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(z, data, element_secp256k1nModulus())
+}
+
+// ToBytesBE returns the canonical big endian encoding of z in regular form
+func (z *element_curve25519l) ToBytesBE() []byte {
+	return toBytesBE(z, element_curve25519lModulus())
+}
+
+// ToBytesLE returns the canonical little endian encoding of z in regular form
+func (z *element_curve25519l) ToBytesLE() []byte {
+	return toBytesLE(z, element_curve25519lModulus())
+}
+
+// SetBytesBE sets z from its canonical big endian encoding and returns z in Montgomery form
+func (z *element_curve25519l) SetBytesBE(b []byte) (Element, error) {
+	return setBytesBE(z, b, element_curve25519lModulus())
+}
+
+// SetBytesLE sets z from its canonical little endian encoding and returns z in Montgomery form
+func (z *element_curve25519l) SetBytesLE(b []byte) (Element, error) {
+	return setBytesLE(z, b, element_curve25519lModulus())
+}
+
+func (z *element_curve25519l) MarshalText() ([]byte, error) {
+	return marshalText(z, element_curve25519lModulus())
+}
+
+func (z *element_curve25519l) UnmarshalText(text []byte) error {
+	return unmarshalText(z, text, element_curve25519lModulus())
+}
+
+func (z *element_curve25519l) MarshalJSON() ([]byte, error) {
+	return marshalJSON(z)
+}
+
+func (z *element_curve25519l) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(z, data, element_curve25519lModulus())
+}
+
+// ToBytesBE returns the canonical big endian encoding of z in regular form
+func (z *element_big) ToBytesBE() []byte {
+	return toBytesBE(z, z.f.modulus)
+}
+
+// ToBytesLE returns the canonical little endian encoding of z in regular form
+func (z *element_big) ToBytesLE() []byte {
+	return toBytesLE(z, z.f.modulus)
+}
+
+// SetBytesBE sets z from its canonical big endian encoding and returns z in Montgomery form
+func (z *element_big) SetBytesBE(b []byte) (Element, error) {
+	return setBytesBE(z, b, z.f.modulus)
+}
+
+// SetBytesLE sets z from its canonical little endian encoding and returns z in Montgomery form
+func (z *element_big) SetBytesLE(b []byte) (Element, error) {
+	return setBytesLE(z, b, z.f.modulus)
+}
+
+func (z *element_big) MarshalText() ([]byte, error) {
+	return marshalText(z, z.f.modulus)
+}
+
+func (z *element_big) UnmarshalText(text []byte) error {
+	return unmarshalText(z, text, z.f.modulus)
+}
+
+func (z *element_big) MarshalJSON() ([]byte, error) {
+	return marshalJSON(z)
+}
+
+func (z *element_big) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(z, data, z.f.modulus)
+}
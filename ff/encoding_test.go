@@ -0,0 +1,141 @@
+package ff
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestCanonicalBytes(t *testing.T) {
+	modulus := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	bigType, _ := RegisterPrime(modulus)
+
+	for _, elType := range []int{FF_BN256_FP, FF_BN256_FQ, FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L, bigType} {
+		el, _ := NewElement(elType)
+		el.SetRandom().ToMont()
+		n, _ := ByteLen(elType)
+
+		be := el.ToBytesBE()
+		le := el.ToBytesLE()
+		if len(be) != n || len(le) != n || !bytes.Equal(be, reverseBytes(le)) {
+			t.Error("Unexpected encoding length", elType)
+		}
+		// little endian encoding matches ToByte layout
+		if !bytes.Equal(le, el.ToByte()[:n]) {
+			t.Error("Little endian encoding differs from ToByte", elType)
+		}
+		var regular big.Int
+		if new(big.Int).SetBytes(be).Cmp(el.ToBigIntRegular(&regular)) != 0 {
+			t.Error("Big endian encoding is not regular form", elType)
+		}
+
+		el2, _ := NewElement(elType)
+		if _, err := el2.SetBytesBE(be); err != nil || !el2.Equal(el) {
+			t.Error("Error decoding big endian", elType)
+		}
+		el2.SetZero()
+		if _, err := el2.SetBytesLE(le); err != nil || !el2.Equal(el) {
+			t.Error("Error decoding little endian", elType)
+		}
+
+		// modulus and wrong lengths are rejected
+		q := el.One().Neg(el.One()).ToBigIntRegular(new(big.Int))
+		q.Add(q, big.NewInt(1))
+		qBytes := make([]byte, n)
+		copy(qBytes[n-len(q.Bytes()):], q.Bytes())
+		if _, err := el2.SetBytesBE(qBytes); err == nil {
+			t.Error("Modulus accepted", elType)
+		}
+		if _, err := el2.SetBytesLE(append(le, 0)); err == nil {
+			t.Error("Long encoding accepted", elType)
+		}
+		if _, err := el2.SetBytesBE(be[1:]); err == nil {
+			t.Error("Short encoding accepted", elType)
+		}
+	}
+}
+
+func TestTextJSON(t *testing.T) {
+	el, _ := NewElement(FF_BN256_FP)
+	el.SetUint64(255)
+
+	text, _ := el.MarshalText()
+	if string(text) != "0x00000000000000000000000000000000000000000000000000000000000000ff" {
+		t.Error("Unexpected text encoding", string(text))
+	}
+	data, _ := json.Marshal(el)
+	if string(data) != `"255"` {
+		t.Error("Unexpected JSON encoding", string(data))
+	}
+
+	for _, enc := range []string{`"255"`, `"0xff"`, `"0x0ff"`, `255`, `"0x00000000000000000000000000000000000000000000000000000000000000ff"`} {
+		el2, _ := NewElement(FF_BN256_FP)
+		if err := json.Unmarshal([]byte(enc), el2); err != nil || !el2.Equal(el) {
+			t.Error("Error decoding JSON", enc, err)
+		}
+	}
+
+	invalid := []string{
+		`"21888242871839275222246405745257275088548364400416034343698204186575808495617"`,
+		`"0x30644e72e131a029b85045b68181585d2833e84879b9709143e1f593f0000001"`,
+		`"-1"`, `"+1"`, `"0x"`, `"0xzz"`, `""`, `"1.5"`, `-1`, `true`,
+	}
+	for _, enc := range invalid {
+		el2, _ := NewElement(FF_BN256_FP)
+		if err := json.Unmarshal([]byte(enc), el2); err == nil {
+			t.Error("Invalid JSON accepted", enc)
+		}
+	}
+
+	// elements inside structures
+	type pair struct {
+		X Element `json:"x"`
+		Y Element `json:"y"`
+	}
+	x, _ := NewElement(FF_SECP256K1_N)
+	y, _ := NewElement(FF_SECP256K1_N)
+	p := pair{X: x.SetUint64(1), Y: y.Neg(y.SetUint64(1))}
+	data, _ = json.Marshal(p)
+	rx, _ := NewElement(FF_SECP256K1_N)
+	ry, _ := NewElement(FF_SECP256K1_N)
+	p2 := pair{X: rx, Y: ry}
+	if err := json.Unmarshal(data, &p2); err != nil || !p2.X.Equal(p.X) || !p2.Y.Equal(p.Y) {
+		t.Error("Error in JSON round trip", string(data))
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	key, _ := NewElement(FF_BN256_FP)
+	key.SetRandom().ToMont()
+
+	// element bytes round trip
+	el, err := EncodeBytes(key.ToByte(), FF_BN256_FP)
+	if err != nil || !el.Equal(key) {
+		t.Error("Error encoding element bytes")
+	}
+	b, err := DecodeBytes(el, 32)
+	if err != nil || !bytes.Equal(b, key.ToByte()) {
+		t.Error("Error decoding element bytes")
+	}
+
+	// short input
+	el, err = EncodeBytes([]byte{1, 2, 3}, FF_BN256_FP)
+	if err != nil || !el.Equal(key.One().SetUint64(0x030201)) {
+		t.Error("Error encoding short bytes")
+	}
+	if b, _ = DecodeBytes(el, 3); !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Error("Error decoding short bytes")
+	}
+	if _, err = DecodeBytes(el, 2); err != ErrBytesTooShort {
+		t.Error("Truncated decoding accepted")
+	}
+
+	// overflow
+	if _, err = EncodeBytes(bytes.Repeat([]byte{0xff}, 32), FF_BN256_FP); err != ErrElementOverflow {
+		t.Error("Overflow not detected")
+	}
+	if _, err = EncodeBytes(make([]byte, 33), FF_BN256_FP); err != ErrBytesTooLong {
+		t.Error("Long bytes accepted")
+	}
+}
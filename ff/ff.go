@@ -14,7 +14,10 @@
 package ff
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
+	"io"
 	"math/big"
 	"strconv"
 )
@@ -33,6 +36,7 @@ type Element interface {
 	IsZero() bool
 	Inverse(x Element) Element
 	SetRandom() Element
+	SetRandomFrom(r io.Reader) (Element, error)
 	One() Element
 	Add(x, y Element) Element
 	AddAssign(x Element) Element
@@ -46,6 +50,14 @@ type Element interface {
 	String() string
 	ToByte() []byte
 	FromByte(x []byte) Element
+	ToBytesBE() []byte
+	ToBytesLE() []byte
+	SetBytesBE(b []byte) (Element, error)
+	SetBytesLE(b []byte) (Element, error)
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+	json.Marshaler
+	json.Unmarshaler
 	ToBigInt(res *big.Int) *big.Int
 	ToBigIntRegular(res *big.Int) *big.Int
 	SetBigInt(v *big.Int) Element
@@ -63,6 +75,15 @@ const (
 	FF_BN256_FQ = iota
 	//21888242871839275222246405745257275088548364400416034343698204186575808495617
 	FF_BN256_FP
+	// BLS12-381 scalar field r
+	// 52435875175126190479447740508185965837690552500527637822603658699938581184513
+	FF_BLS12381_FR
+	// secp256k1 group order n
+	// 115792089237316195423570985008687907852837564279074904382605163141518161494337
+	FF_SECP256K1_N
+	// Curve25519 (Ed25519) group order l
+	// 7237005577332262213973186563042994240857116359379907606001950938285454250989
+	FF_CURVE25519_L
 	// Add more primes
 )
 
@@ -81,8 +102,21 @@ func NewElement(t int) (Element, error) {
 		var el element_bn256p
 		return &el, nil
 
+	case FF_BLS12381_FR:
+		var el element_bls12381r
+		return &el, nil
+
+	case FF_SECP256K1_N:
+		var el element_secp256k1n
+		return &el, nil
+
+	case FF_CURVE25519_L:
+		var el element_curve25519l
+		return &el, nil
+
 	default:
-		return nil, errors.New("Invalid FF type")
+		// primes registered at runtime
+		return newElementBig(t)
 	}
 }
 
@@ -122,8 +156,11 @@ func IsValid(elType int) bool {
 	case FF_BN256_FQ:
 		return true
 
+	case FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L:
+		return true
+
 	default:
-		return false
+		return getBigField(elType) != nil
 	}
 
 }
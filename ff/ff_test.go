@@ -1,6 +1,8 @@
 package ff
 
 import (
+	"bytes"
+	"math/big"
 	"testing"
 )
 
@@ -34,8 +36,62 @@ func TestFFOK(t *testing.T) {
 		t.Error(err)
 	}
 
+	for _, elType := range []int{FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L} {
+		el, err = NewElement(elType)
+		if err != nil || !IsValid(elType) {
+			t.Error("Element is invalid ", elType)
+		}
+		seven, _ := NewElement(elType)
+		seven.SetUint64(7)
+		el.Inverse(seven).MulAssign(seven)
+		if !el.Equal(el.One()) {
+			t.Error("Element arithmetic incorrect ", elType)
+		}
+	}
+
 	if IsValid(FF_BN256_FQ + 12) {
 		t.Error("Element is invalid ")
 	}
 
 }
+
+func TestSetRandomFrom(t *testing.T) {
+	modulus := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	bigType, _ := RegisterPrime(modulus)
+
+	for _, elType := range []int{FF_BN256_FP, FF_BN256_FQ, FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L, bigType} {
+		el1, _ := NewElement(elType)
+		el2, _ := NewElement(elType)
+		src1 := NewSeededReader([]byte("seed"))
+		src2 := NewSeededReader([]byte("seed"))
+		for iter := 0; iter < 20; iter++ {
+			_, err1 := el1.SetRandomFrom(src1)
+			_, err2 := el2.SetRandomFrom(src2)
+			if err1 != nil || err2 != nil || !el1.Equal(el2) {
+				t.Error("Seeded elements differ", elType)
+			}
+		}
+
+		// values are in Montgomery form, so regular value is the sampled one
+		el1.SetRandomFrom(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 7}[32-len(el1.ToByte()):]))
+		seven, _ := NewElement(elType)
+		if !el1.Equal(seven.SetUint64(7)) {
+			t.Error("Unexpected random element", elType)
+		}
+
+		// source exhausted
+		if _, err := el1.SetRandomFrom(bytes.NewReader([]byte{1, 2, 3})); err == nil {
+			t.Error("Short random source accepted", elType)
+		}
+	}
+
+	// values >= q are rejected : 0xff.. is rejected and next sample is used
+	el, _ := NewElement(FF_SECP256K1_N)
+	sample := append(bytes.Repeat([]byte{0xff}, 32), make([]byte, 32)...)
+	sample[63] = 5
+	el.SetRandomFrom(bytes.NewReader(sample))
+	five, _ := NewElement(FF_SECP256K1_N)
+	if !el.Equal(five.SetUint64(5)) {
+		t.Error("Out of range element not rejected")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ Hash to field.
+
+ Implements hash_to_field from the IETF hash-to-curve specification (RFC 9380, section 5) with
+ expand_message_xmd and SHA-256:
+   - L = ceil((ceil(log2(q)) + k) / 8) bytes per element, with security parameter k = 128
+   - uniform_bytes = expand_message_xmd(msg, DST, count * L)
+   - e[i] = OS2IP(uniform_bytes[i*L : (i+1)*L]) mod q
+ Every element is derived from k bits more than the modulus, so the bias of the reduction is negligible.
+
+ domain is the domain separation tag (DST). Different applications need to use different tags.
+*/
+
+package ff
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+const (
+	HASH_TO_FIELD_K  = 128 // security parameter in bits
+	XMD_MAX_DST_SIZE = 255
+)
+
+// Tag prefix to hash domain separation tags longer than XMD_MAX_DST_SIZE
+const XMD_OVERSIZE_DST_PREFIX = "H2C-OVERSIZE-DST-"
+
+// Hash msg into an element of type elType
+func HashToField(domain, msg []byte, elType int) (Element, error) {
+	el, err := HashToFieldN(domain, msg, 1, elType)
+	if err != nil {
+		return nil, err
+	}
+	return el[0], nil
+}
+
+// Hash msg into count independent elements of type elType
+func HashToFieldN(domain, msg []byte, count, elType int) ([]Element, error) {
+	if count <= 0 {
+		return nil, errors.New("Invalid number of elements")
+	}
+	el, err := NewElement(elType)
+	if err != nil {
+		return nil, err
+	}
+	modulus := modulusOf(el)
+	L := (modulus.BitLen() + HASH_TO_FIELD_K + 7) / 8
+
+	uniform, err := ExpandMessageXMD(msg, domain, count*L)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := make([]Element, count)
+	v := new(big.Int)
+	for idx := range elements {
+		v.SetBytes(uniform[idx*L : (idx+1)*L])
+		v.Mod(v, modulus)
+		elements[idx], _ = NewElement(elType)
+		elements[idx].SetBigInt(v)
+	}
+	return elements, nil
+}
+
+// expand_message_xmd with SHA-256. Returns lenInBytes pseudorandom bytes derived from msg and domain
+// separation tag dst
+func ExpandMessageXMD(msg, dst []byte, lenInBytes int) ([]byte, error) {
+	bInBytes := sha256.Size
+	sInBytes := sha256.BlockSize
+
+	ell := (lenInBytes + bInBytes - 1) / bInBytes
+	if lenInBytes <= 0 || ell > 255 || lenInBytes > 0xffff {
+		return nil, errors.New("Invalid expand_message_xmd length")
+	}
+	if len(dst) > XMD_MAX_DST_SIZE {
+		h := sha256.New()
+		h.Write([]byte(XMD_OVERSIZE_DST_PREFIX))
+		h.Write(dst)
+		dst = h.Sum(nil)
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	// b_0 = H(Z_pad || msg || l_i_b_str || I2OSP(0, 1) || DST_prime)
+	lib := make([]byte, 2)
+	binary.BigEndian.PutUint16(lib, uint16(lenInBytes))
+	h := sha256.New()
+	h.Write(make([]byte, sInBytes))
+	h.Write(msg)
+	h.Write(lib)
+	h.Write([]byte{0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	// b_1 = H(b_0 || I2OSP(1, 1) || DST_prime)
+	// b_i = H(strxor(b_0, b_(i-1)) || I2OSP(i, 1) || DST_prime)
+	uniform := make([]byte, 0, ell*bInBytes)
+	bi := make([]byte, bInBytes)
+	for i := 1; i <= ell; i++ {
+		for idx := range bi {
+			bi[idx] ^= b0[idx]
+		}
+		h.Reset()
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+
+	return uniform[:lenInBytes], nil
+}
@@ -0,0 +1,72 @@
+package ff
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestExpandMessageXMD(t *testing.T) {
+	// RFC 9380, Appendix K.1
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	tests := []struct {
+		msg     string
+		length  int
+		uniform string
+	}{
+		{"", 0x20, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", 0x20, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+		{"abcdef0123456789", 0x20, "eff31487c770a893cfb36f912fbfcbff40d5661771ca4b2cb4eafe524333f5c1"},
+		{"", 0x80, "af84c27ccfd45d41914fdff5df25293e221afc53d8ad2ac06d5e3e29485dadbee0d121587713a3e0dd4d5e69e93eb7cd4f5df4cd103e188cf60cb02edc3edf18eda8576c412b18ffb658e3dd6ec849469b979d444cf7b26911a08e63cf31f9dcc541708d3491184472c2c29bb749d4286b004ceb5ee6b9a7fa5b646c993f0ced"},
+	}
+	for _, test := range tests {
+		uniform, err := ExpandMessageXMD([]byte(test.msg), dst, test.length)
+		if err != nil || hex.EncodeToString(uniform) != test.uniform {
+			t.Error("Unexpected uniform bytes", test.msg, test.length)
+		}
+	}
+
+	for _, length := range []int{0, 255*32 + 1, 0x10000} {
+		if _, err := ExpandMessageXMD([]byte("abc"), dst, length); err == nil {
+			t.Error("Invalid length accepted", length)
+		}
+	}
+	// long tags are hashed
+	if _, err := ExpandMessageXMD([]byte("abc"), bytes.Repeat([]byte{'a'}, 300), 32); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHashToField(t *testing.T) {
+	domain := []byte("go-backup-test")
+	for _, elType := range []int{FF_BN256_FP, FF_BN256_FQ, FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L} {
+		el1, err := HashToField(domain, []byte("msg"), elType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		el2, _ := HashToField(domain, []byte("msg"), elType)
+		el3, _ := HashToField([]byte("go-backup-other"), []byte("msg"), elType)
+		el4, _ := HashToField(domain, []byte("msg2"), elType)
+		if !el1.Equal(el2) || el1.Equal(el3) || el1.Equal(el4) {
+			t.Error("Unexpected hash to field", elType)
+		}
+
+		// reference : OS2IP(uniform_bytes) mod q
+		els, _ := HashToFieldN(domain, []byte("msg"), 3, elType)
+		modulus := modulusOf(el1)
+		L := (modulus.BitLen() + HASH_TO_FIELD_K + 7) / 8
+		uniform, _ := ExpandMessageXMD([]byte("msg"), domain, 3*L)
+		for idx, el := range els {
+			expected := new(big.Int).SetBytes(uniform[idx*L : (idx+1)*L])
+			expected.Mod(expected, modulus)
+			var v big.Int
+			if el.ToBigIntRegular(&v).Cmp(expected) != 0 {
+				t.Error("Unexpected hash to field element", elType, idx)
+			}
+		}
+	}
+	if _, err := HashToFieldN(domain, []byte("msg"), 0, FF_BN256_FP); err == nil {
+		t.Error("Invalid number of elements accepted")
+	}
+}
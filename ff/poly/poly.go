@@ -0,0 +1,312 @@
+/*
+ Polynomials with coefficients in a finite field defined in ff.
+
+ A polynomial is stored as its list of coefficients, lowest degree first:
+    p(x) = Coeffs[0] + Coeffs[1] * x + ... + Coeffs[n] * x^n
+ Coefficients are ff elements in Montgomery form, all of the same ElementType. Polynomials are kept
+ normalized (no leading zero coefficients), so the zero polynomial has no coefficients and degree -1.
+
+ Operations never modify their operands and return new polynomials.
+*/
+
+package poly
+
+import (
+	"errors"
+	"io"
+
+	"github.com/iden3/go-backup/ff"
+)
+
+type Polynomial struct {
+	Coeffs      []ff.Element // Coeffs[i] is coefficient of x^i
+	ElementType int
+}
+
+// Create polynomial from coefficients (lowest degree first). Coefficients are copied
+func New(coeffs []ff.Element, elType int) *Polynomial {
+	p := &Polynomial{
+		Coeffs:      make([]ff.Element, len(coeffs)),
+		ElementType: elType,
+	}
+	for idx, c := range coeffs {
+		p.Coeffs[idx] = p.newElement().Set(c)
+	}
+	return p.normalize()
+}
+
+// Create zero polynomial
+func Zero(elType int) *Polynomial {
+	return &Polynomial{Coeffs: make([]ff.Element, 0), ElementType: elType}
+}
+
+// Create random polynomial of the given degree with constant coefficient c0. Coefficients are read from r
+func NewRandom(r io.Reader, degree int, c0 ff.Element, elType int) (*Polynomial, error) {
+	p := &Polynomial{
+		Coeffs:      make([]ff.Element, degree+1),
+		ElementType: elType,
+	}
+	p.Coeffs[0] = p.newElement().Set(c0)
+	for idx := 1; idx <= degree; idx++ {
+		c, err := p.newElement().SetRandomFrom(r)
+		if err != nil {
+			return nil, err
+		}
+		p.Coeffs[idx] = c
+	}
+	return p.normalize(), nil
+}
+
+// Create polynomial Prod_j (x - roots[j])
+func FromRoots(roots []ff.Element, elType int) *Polynomial {
+	coeffs := make([]ff.Element, 1, len(roots)+1)
+	p := &Polynomial{ElementType: elType}
+	coeffs[0] = p.newElement().SetOne()
+	tmp := p.newElement()
+
+	// multiply by (x - r) one root at a time : c[idx] = c[idx-1] - r * c[idx]
+	for _, r := range roots {
+		coeffs = append(coeffs, p.newElement().SetZero())
+		for idx := len(coeffs) - 1; idx > 0; idx-- {
+			tmp.Mul(coeffs[idx], r)
+			coeffs[idx].Sub(coeffs[idx-1], tmp)
+		}
+		tmp.Mul(coeffs[0], r)
+		coeffs[0].Neg(tmp)
+	}
+	p.Coeffs = coeffs
+	return p
+}
+
+// Compute polynomial of degree < len(px) going through points (px[j], py[j]) (Lagrange interpolation)
+// p(x) = Sum_j py[j] * Prod_m!=j (x - px[m]) / (px[j] - px[m])
+// x-coordinates need to be distinct
+func Interpolate(px, py []ff.Element, elType int) (*Polynomial, error) {
+	if len(px) == 0 {
+		return nil, errors.New("Polynomial : No points provided")
+	}
+	if len(px) != len(py) {
+		return nil, errors.New("Polynomial : Number of x and y coordinates differ")
+	}
+	for idx, x := range px {
+		for _, prev := range px[:idx] {
+			if prev.Equal(x) {
+				return nil, errors.New("Polynomial : Duplicated x-coordinate")
+			}
+		}
+	}
+
+	// m(x) = Prod_j (x - px[j]), and Prod_m!=j (x - px[m]) = m(x) / (x - px[j])
+	// Denominators Prod_m!=j (px[j] - px[m]) = m'(px[j])
+	m := FromRoots(px, elType)
+	den := m.Derivative().EvalMulti(px)
+	ff.BatchInverse(den)
+
+	result := make([]ff.Element, len(px))
+	for idx := range result {
+		result[idx], _ = ff.NewElement(elType)
+		result[idx].SetZero()
+	}
+	w, _ := ff.NewElement(elType)
+	for j := range px {
+		// w = py[j] / m'(px[j])
+		w.Mul(py[j], den[j])
+		if w.IsZero() {
+			continue
+		}
+		lj := m.divLinear(px[j])
+		for idx, c := range lj {
+			c.MulAssign(w)
+			result[idx].AddAssign(c)
+		}
+	}
+
+	return (&Polynomial{Coeffs: result, ElementType: elType}).normalize(), nil
+}
+
+// Returns polynomial degree. Zero polynomial has degree -1
+func (p *Polynomial) Degree() int {
+	return len(p.Coeffs) - 1
+}
+
+func (p *Polynomial) IsZero() bool {
+	return len(p.Coeffs) == 0
+}
+
+// Returns coefficient of x^idx
+func (p *Polynomial) Coeff(idx int) ff.Element {
+	c := p.newElement().SetZero()
+	if idx >= 0 && idx < len(p.Coeffs) {
+		c.Set(p.Coeffs[idx])
+	}
+	return c
+}
+
+func (p *Polynomial) Equal(q *Polynomial) bool {
+	if len(p.Coeffs) != len(q.Coeffs) {
+		return false
+	}
+	for idx, c := range p.Coeffs {
+		if !c.Equal(q.Coeffs[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate p(x) using Horner's rule
+// p(x) = c[0] + x * (c[1] + x * (c[2] + ... + x * c[n]))
+func (p *Polynomial) Eval(x ff.Element) ff.Element {
+	y := p.newElement().SetZero()
+	for idx := len(p.Coeffs) - 1; idx >= 0; idx-- {
+		y.MulAssign(x)
+		y.AddAssign(p.Coeffs[idx])
+	}
+	return y
+}
+
+// Evaluate p at every point in xs
+func (p *Polynomial) EvalMulti(xs []ff.Element) []ff.Element {
+	ys := make([]ff.Element, len(xs))
+	for idx, x := range xs {
+		ys[idx] = p.Eval(x)
+	}
+	return ys
+}
+
+// Returns p + q
+func (p *Polynomial) Add(q *Polynomial) *Polynomial {
+	r := p.resized(max(len(p.Coeffs), len(q.Coeffs)))
+	for idx, c := range q.Coeffs {
+		r.Coeffs[idx].AddAssign(c)
+	}
+	return r.normalize()
+}
+
+// Returns p - q
+func (p *Polynomial) Sub(q *Polynomial) *Polynomial {
+	r := p.resized(max(len(p.Coeffs), len(q.Coeffs)))
+	for idx, c := range q.Coeffs {
+		r.Coeffs[idx].SubAssign(c)
+	}
+	return r.normalize()
+}
+
+// Returns c * p
+func (p *Polynomial) Scale(c ff.Element) *Polynomial {
+	r := p.resized(len(p.Coeffs))
+	for _, rc := range r.Coeffs {
+		rc.MulAssign(c)
+	}
+	return r.normalize()
+}
+
+// Returns p * q
+func (p *Polynomial) Mul(q *Polynomial) *Polynomial {
+	if p.IsZero() || q.IsZero() {
+		return Zero(p.ElementType)
+	}
+	r := Zero(p.ElementType).resized(len(p.Coeffs) + len(q.Coeffs) - 1)
+	tmp := p.newElement()
+	for i, pc := range p.Coeffs {
+		for j, qc := range q.Coeffs {
+			tmp.Mul(pc, qc)
+			r.Coeffs[i+j].AddAssign(tmp)
+		}
+	}
+	return r.normalize()
+}
+
+// Returns quotient and remainder of p / d, such that p = quo * d + rem and deg(rem) < deg(d)
+func (p *Polynomial) DivMod(d *Polynomial) (*Polynomial, *Polynomial, error) {
+	if d.IsZero() {
+		return nil, nil, errors.New("Polynomial : Division by zero polynomial")
+	}
+	rem := p.resized(len(p.Coeffs))
+	if len(p.Coeffs) < len(d.Coeffs) {
+		return Zero(p.ElementType), rem, nil
+	}
+
+	quo := Zero(p.ElementType).resized(len(p.Coeffs) - len(d.Coeffs) + 1)
+	lcInv := p.newElement().Inverse(d.Coeffs[d.Degree()])
+	tmp := p.newElement()
+	for idx := quo.Degree(); idx >= 0; idx-- {
+		// quo[idx] = rem[idx + deg(d)] / lc(d), and rem -= quo[idx] * x^idx * d
+		c := quo.Coeffs[idx].Mul(rem.Coeffs[idx+d.Degree()], lcInv)
+		if c.IsZero() {
+			continue
+		}
+		for dIdx, dc := range d.Coeffs {
+			tmp.Mul(c, dc)
+			rem.Coeffs[idx+dIdx].SubAssign(tmp)
+		}
+	}
+
+	return quo.normalize(), rem.normalize(), nil
+}
+
+// Returns formal derivative p'(x) = c[1] + 2 * c[2] * x + ... + n * c[n] * x^(n-1)
+func (p *Polynomial) Derivative() *Polynomial {
+	if len(p.Coeffs) <= 1 {
+		return Zero(p.ElementType)
+	}
+	r := Zero(p.ElementType).resized(len(p.Coeffs) - 1)
+	n := p.newElement()
+	for idx := range r.Coeffs {
+		n.SetUint64(uint64(idx + 1))
+		r.Coeffs[idx].Mul(p.Coeffs[idx+1], n)
+	}
+	return r.normalize()
+}
+
+// Returns coefficients of p(x) / (x - a) when a is a root of p (synthetic division). Remainder is discarded
+func (p *Polynomial) divLinear(a ff.Element) []ff.Element {
+	if len(p.Coeffs) <= 1 {
+		return nil
+	}
+	quo := make([]ff.Element, len(p.Coeffs)-1)
+	acc := p.newElement().SetZero()
+	for idx := len(p.Coeffs) - 1; idx > 0; idx-- {
+		acc.MulAssign(a)
+		acc.AddAssign(p.Coeffs[idx])
+		quo[idx-1] = p.newElement().Set(acc)
+	}
+	return quo
+}
+
+// Returns a copy of p with n coefficients, padded with zeros
+func (p *Polynomial) resized(n int) *Polynomial {
+	r := &Polynomial{
+		Coeffs:      make([]ff.Element, n),
+		ElementType: p.ElementType,
+	}
+	for idx := range r.Coeffs {
+		r.Coeffs[idx] = p.newElement().SetZero()
+		if idx < len(p.Coeffs) {
+			r.Coeffs[idx].Set(p.Coeffs[idx])
+		}
+	}
+	return r
+}
+
+// Remove leading zero coefficients
+func (p *Polynomial) normalize() *Polynomial {
+	n := len(p.Coeffs)
+	for n > 0 && p.Coeffs[n-1].IsZero() {
+		n--
+	}
+	p.Coeffs = p.Coeffs[:n]
+	return p
+}
+
+func (p *Polynomial) newElement() ff.Element {
+	el, _ := ff.NewElement(p.ElementType)
+	return el
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,159 @@
+package poly
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/iden3/go-backup/ff"
+)
+
+var testPrimes = []int{ff.FF_BN256_FP, ff.FF_BN256_FQ, ff.FF_BLS12381_FR, ff.FF_SECP256K1_N, ff.FF_CURVE25519_L}
+
+func newUint(v uint64, elType int) ff.Element {
+	el, _ := ff.NewElement(elType)
+	return el.SetUint64(v)
+}
+
+func newRandomPoly(degree int, c0 ff.Element, elType int) *Polynomial {
+	p, _ := NewRandom(rand.Reader, degree, c0, elType)
+	return p
+}
+
+func newRandom(elType int) ff.Element {
+	el, _ := ff.NewElement(elType)
+	return el.SetRandom().ToMont()
+}
+
+func TestPolyEval(t *testing.T) {
+	for _, prime := range testPrimes {
+		// p(x) = 3 + 2x + x^2
+		p := New([]ff.Element{newUint(3, prime), newUint(2, prime), newUint(1, prime)}, prime)
+		if p.Degree() != 2 {
+			t.Error("Unexpected degree")
+		}
+		ys := p.EvalMulti([]ff.Element{newUint(0, prime), newUint(1, prime), newUint(5, prime)})
+		for idx, expected := range []uint64{3, 6, 38} {
+			if !ys[idx].Equal(newUint(expected, prime)) {
+				t.Error("Polynomial evaluation incorrect", prime, idx)
+			}
+		}
+
+		// leading zeros are removed
+		p = New([]ff.Element{newUint(1, prime), newUint(0, prime), newUint(0, prime)}, prime)
+		if p.Degree() != 0 || Zero(prime).Degree() != -1 || !Zero(prime).Eval(newUint(7, prime)).IsZero() {
+			t.Error("Unexpected degree")
+		}
+	}
+}
+
+func TestPolyArith(t *testing.T) {
+	for _, prime := range testPrimes {
+		for iter := 0; iter < 10; iter++ {
+			p := newRandomPoly(1+iter, newRandom(prime), prime)
+			q := newRandomPoly(iter/2, newRandom(prime), prime)
+			x := newRandom(prime)
+
+			// (p + q)(x) = p(x) + q(x),  (p - q)(x) = p(x) - q(x), (p * q)(x) = p(x) * q(x)
+			px, qx := p.Eval(x), q.Eval(x)
+			y, _ := ff.NewElement(prime)
+			if !p.Add(q).Eval(x).Equal(y.Add(px, qx)) {
+				t.Error("Polynomial addition incorrect")
+			}
+			if !p.Sub(q).Eval(x).Equal(y.Sub(px, qx)) {
+				t.Error("Polynomial subtraction incorrect")
+			}
+			if !p.Mul(q).Eval(x).Equal(y.Mul(px, qx)) || p.Mul(q).Degree() != p.Degree()+q.Degree() {
+				t.Error("Polynomial multiplication incorrect")
+			}
+			if !p.Sub(p).IsZero() || !p.Mul(Zero(prime)).IsZero() {
+				t.Error("Expected zero polynomial")
+			}
+			if !p.Scale(x).Eval(x).Equal(y.Mul(px, x)) {
+				t.Error("Polynomial scaling incorrect")
+			}
+
+			// p = quo * q + rem
+			quo, rem, err := p.DivMod(q)
+			if err != nil || rem.Degree() >= q.Degree() || !quo.Mul(q).Add(rem).Equal(p) {
+				t.Error("Polynomial division incorrect")
+			}
+			quo, rem, _ = p.Mul(q).DivMod(q)
+			if !rem.IsZero() || !quo.Equal(p) {
+				t.Error("Polynomial exact division incorrect")
+			}
+		}
+
+		_, _, err := newRandomPoly(3, newRandom(prime), prime).DivMod(Zero(prime))
+		if err == nil {
+			t.Error("Division by zero polynomial accepted")
+		}
+	}
+}
+
+func TestPolyDerivative(t *testing.T) {
+	for _, prime := range testPrimes {
+		// p(x) = 3 + 2x + 4x^3 -> p'(x) = 2 + 12x^2
+		p := New([]ff.Element{newUint(3, prime), newUint(2, prime), newUint(0, prime), newUint(4, prime)}, prime)
+		expected := New([]ff.Element{newUint(2, prime), newUint(0, prime), newUint(12, prime)}, prime)
+		if !p.Derivative().Equal(expected) {
+			t.Error("Polynomial derivative incorrect")
+		}
+		if !New([]ff.Element{newUint(3, prime)}, prime).Derivative().IsZero() {
+			t.Error("Derivative of constant not zero")
+		}
+
+		// (x - r0) (x - r1) has roots r0 and r1
+		roots := []ff.Element{newRandom(prime), newRandom(prime)}
+		m := FromRoots(roots, prime)
+		if m.Degree() != 2 || !m.Eval(roots[0]).IsZero() || !m.Eval(roots[1]).IsZero() {
+			t.Error("Polynomial from roots incorrect")
+		}
+	}
+}
+
+func TestPolyInterpolate(t *testing.T) {
+	for _, prime := range testPrimes {
+		for degree := 0; degree < 12; degree++ {
+			p := newRandomPoly(degree, newRandom(prime), prime)
+			px := make([]ff.Element, degree+1)
+			for idx := range px {
+				px[idx] = newRandom(prime)
+			}
+			if degree > 2 {
+				px[0].SetZero()
+			}
+			q, err := Interpolate(px, p.EvalMulti(px), prime)
+			if err != nil || !q.Equal(p) {
+				t.Error("Polynomial interpolation incorrect", prime, degree)
+			}
+		}
+
+		px := []ff.Element{newUint(1, prime), newUint(2, prime), newUint(1, prime)}
+		_, err := Interpolate(px, px, prime)
+		if err == nil {
+			t.Error("Duplicated x-coordinates accepted")
+		}
+		_, err = Interpolate(px, px[:2], prime)
+		if err == nil {
+			t.Error("Inconsistent points accepted")
+		}
+		_, err = Interpolate(nil, nil, prime)
+		if err == nil {
+			t.Error("Empty points accepted")
+		}
+	}
+}
+
+func BenchmarkInterpolate(b *testing.B) {
+	prime := ff.FF_BN256_FP
+	p := newRandomPoly(15, newRandom(prime), prime)
+	px := make([]ff.Element, 16)
+	for idx := range px {
+		px[idx] = newUint(uint64(idx+1), prime)
+	}
+	py := p.EvalMulti(px)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Interpolate(px, py, prime)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ Random element generation from a caller provided randomness source.
+
+ SetRandomFrom reads ceil(bits(q) / 8) bytes from the source, interprets them as a big endian integer,
+ masks the bits above bits(q) and rejects the value if it is not below q (rejection sampling). Accepted
+ values are uniform in [0, q), with no modulo bias. The sequence of bytes read only depends on the
+ modulus, so a deterministic source (see NewSeededReader) generates the same elements on every platform.
+*/
+
+package ff
+
+import (
+	"errors"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Maximum number of rejected samples before giving up. With q > 2^(bits(q)-1) every sample is
+// accepted with probability > 1/2
+const RANDOM_MAX_TRIES = 128
+
+// Returns a reader producing a deterministic byte stream from seed (SHAKE256 XOF)
+func NewSeededReader(seed []byte) io.Reader {
+	xof := sha3.NewShake256()
+	xof.Write(seed)
+	return xof
+}
+
+// Returns a uniformly random integer in [0, modulus) read from r
+func randomBelow(r io.Reader, modulus *big.Int) (*big.Int, error) {
+	bitLen := modulus.BitLen()
+	b := make([]byte, (bitLen+7)/8)
+	v := new(big.Int)
+	for try := 0; try < RANDOM_MAX_TRIES; try++ {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if extraBits := 8*len(b) - bitLen; extraBits > 0 {
+			b[0] &= byte(0xff >> uint(extraBits))
+		}
+		if v.SetBytes(b).Cmp(modulus) < 0 {
+			return v, nil
+		}
+	}
+	return nil, errors.New("Random source failed to generate element")
+}
+
+func setRandomFrom(z Element, r io.Reader, modulus *big.Int) (Element, error) {
+	v, err := randomBelow(r, modulus)
+	if err != nil {
+		return nil, err
+	}
+	return z.SetBigInt(v), nil
+}
+
+// SetRandomFrom sets z to a uniformly random element read from r, in Montgomery form
+func (z *element_bn256p) SetRandomFrom(r io.Reader) (Element, error) {
+	return setRandomFrom(z, r, element_bn256pModulus())
+}
+
+// SetRandomFrom sets z to a uniformly random element read from r, in Montgomery form
+func (z *element_bn256q) SetRandomFrom(r io.Reader) (Element, error) {
+	return setRandomFrom(z, r, element_bn256qModulus())
+}
+
+// SetRandomFrom sets z to a uniformly random element read from r, in Montgomery form
+func (z *element_bls12381r) SetRandomFrom(r io.Reader) (Element, error) {
+	return setRandomFrom(z, r, element_bls12381rModulus())
+}
+
+// SetRandomFrom sets z to a uniformly random element read from r, in Montgomery form
+func (z *element_secp256k1n) SetRandomFrom(r io.Reader) (Element, error) {
+	return setRandomFrom(z, r, element_secp256k1nModulus())
+}
+
+// SetRandomFrom sets z to a uniformly random element read from r, in Montgomery form
+func (z *element_curve25519l) SetRandomFrom(r io.Reader) (Element, error) {
+	return setRandomFrom(z, r, element_curve25519lModulus())
+}
+
+// SetRandomFrom sets z to a uniformly random element read from r, in Montgomery form
+func (z *element_big) SetRandomFrom(r io.Reader) (Element, error) {
+	return setRandomFrom(z, r, z.f.modulus)
+}
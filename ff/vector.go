@@ -0,0 +1,138 @@
+/*
+ Operations on vectors of finite field elements.
+
+ Vectors are slices of Element of the same type. Results are new elements of the type of the first
+ input element, so inputs are never modified unless stated otherwise.
+*/
+
+package ff
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Invert all elements in place with a single field inversion (Montgomery's trick). Zero elements are
+// left unchanged, as with Inverse
+func BatchInverse(el []Element) {
+	if len(el) == 0 {
+		return
+	}
+	// prefix[i] = product of nonzero el[0] ... el[i-1]
+	prefix := make([]Element, len(el))
+	acc := el[0].One()
+	for idx, x := range el {
+		prefix[idx] = el[0].One().Set(acc)
+		if !x.IsZero() {
+			acc.MulAssign(x)
+		}
+	}
+
+	// acc = 1 / (product of nonzero elements)
+	acc.Inverse(acc)
+	tmp := el[0].One()
+	for idx := len(el) - 1; idx >= 0; idx-- {
+		if el[idx].IsZero() {
+			continue
+		}
+		// 1/el[idx] = prefix[idx] * acc, then acc = acc * el[idx]
+		tmp.Set(el[idx])
+		el[idx].Mul(prefix[idx], acc)
+		acc.MulAssign(tmp)
+	}
+}
+
+// Returns Sum_i a[i] * b[i]
+func InnerProduct(a, b []Element) (Element, error) {
+	if err := checkVectors(a, b); err != nil {
+		return nil, err
+	}
+	result := a[0].One().SetZero()
+	tmp := a[0].One()
+	for idx := range a {
+		tmp.Mul(a[idx], b[idx])
+		result.AddAssign(tmp)
+	}
+	return result, nil
+}
+
+// Returns element-wise product a[i] * b[i]
+func VecMul(a, b []Element) ([]Element, error) {
+	if err := checkVectors(a, b); err != nil {
+		return nil, err
+	}
+	result := make([]Element, len(a))
+	for idx := range a {
+		result[idx] = a[0].One().Mul(a[idx], b[idx])
+	}
+	return result, nil
+}
+
+// Returns element-wise sum a[i] + b[i]
+func VecAdd(a, b []Element) ([]Element, error) {
+	if err := checkVectors(a, b); err != nil {
+		return nil, err
+	}
+	result := make([]Element, len(a))
+	for idx := range a {
+		result[idx] = a[0].One().Add(a[idx], b[idx])
+	}
+	return result, nil
+}
+
+// Returns element-wise difference a[i] - b[i]
+func VecSub(a, b []Element) ([]Element, error) {
+	if err := checkVectors(a, b); err != nil {
+		return nil, err
+	}
+	result := make([]Element, len(a))
+	for idx := range a {
+		result[idx] = a[0].One().Sub(a[idx], b[idx])
+	}
+	return result, nil
+}
+
+// Returns vector Sum_j coeffs[j] * vecs[j]. All vectors need to have the same length
+func LinearCombination(coeffs []Element, vecs [][]Element) ([]Element, error) {
+	if len(coeffs) == 0 || len(coeffs) != len(vecs) {
+		return nil, errors.New("Number of coefficients and vectors differ")
+	}
+	for _, vec := range vecs {
+		if err := checkVectors(vecs[0], vec); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]Element, len(vecs[0]))
+	for idx := range result {
+		result[idx] = coeffs[0].One().SetZero()
+	}
+	tmp := coeffs[0].One()
+	for j, vec := range vecs {
+		if reflect.TypeOf(coeffs[j]) != reflect.TypeOf(vec[0]) {
+			return nil, errors.New("Vector element types differ")
+		}
+		for idx, x := range vec {
+			tmp.Mul(coeffs[j], x)
+			result[idx].AddAssign(tmp)
+		}
+	}
+	return result, nil
+}
+
+// Check vectors are not empty, have the same length and element type
+func checkVectors(a, b []Element) error {
+	if len(a) == 0 {
+		return errors.New("Empty vector")
+	}
+	if len(a) != len(b) {
+		return errors.New("Vector lengths differ")
+	}
+	elType := reflect.TypeOf(a[0])
+	for idx := range a {
+		if reflect.TypeOf(a[idx]) != elType || reflect.TypeOf(b[idx]) != elType {
+			return errors.New("Vector element types differ")
+		}
+	}
+	return nil
+}
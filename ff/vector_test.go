@@ -0,0 +1,111 @@
+package ff
+
+import (
+	"testing"
+)
+
+func randomVector(n, elType int) []Element {
+	vec := make([]Element, n)
+	for idx := range vec {
+		vec[idx], _ = NewElement(elType)
+		vec[idx].SetRandom().ToMont()
+	}
+	return vec
+}
+
+func copyVector(vec []Element) []Element {
+	cp := make([]Element, len(vec))
+	for idx, x := range vec {
+		cp[idx] = x.One().Set(x)
+	}
+	return cp
+}
+
+func TestBatchInverse(t *testing.T) {
+	for _, elType := range []int{FF_BN256_FP, FF_BN256_FQ, FF_BLS12381_FR, FF_SECP256K1_N, FF_CURVE25519_L} {
+		el := randomVector(17, elType)
+		el[3].SetZero()
+		inv := copyVector(el)
+		BatchInverse(inv)
+		for idx := range el {
+			expected := el[idx].One().Inverse(el[idx])
+			if !inv[idx].Equal(expected) {
+				t.Error("Batch inverse incorrect", elType, idx)
+			}
+		}
+		BatchInverse(nil)
+	}
+}
+
+func TestVectorOps(t *testing.T) {
+	elType := FF_BN256_FP
+	a := randomVector(8, elType)
+	b := randomVector(8, elType)
+
+	ip, err := InnerProduct(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mul, _ := VecMul(a, b)
+	add, _ := VecAdd(a, b)
+	sub, _ := VecSub(add, b)
+	expected := a[0].One().SetZero()
+	for idx := range a {
+		expected.AddAssign(mul[idx])
+		if !mul[idx].Equal(a[idx].One().Mul(a[idx], b[idx])) {
+			t.Error("Element-wise multiplication incorrect")
+		}
+		if !sub[idx].Equal(a[idx]) {
+			t.Error("Element-wise addition/subtraction incorrect")
+		}
+	}
+	if !ip.Equal(expected) {
+		t.Error("Inner product incorrect")
+	}
+
+	// 2 * a - b
+	two, _ := NewElement(elType)
+	two.SetUint64(2)
+	minusOne := two.One().Neg(two.One())
+	lc, err := LinearCombination([]Element{two, minusOne}, [][]Element{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := range lc {
+		if !lc[idx].Equal(a[idx].One().Sub(a[idx].One().Double(a[idx]), b[idx])) {
+			t.Error("Linear combination incorrect")
+		}
+	}
+
+	// invalid vectors
+	if _, err = InnerProduct(a, b[1:]); err == nil {
+		t.Error("Vectors with different lengths accepted")
+	}
+	if _, err = VecAdd(nil, nil); err == nil {
+		t.Error("Empty vectors accepted")
+	}
+	if _, err = VecMul(a, randomVector(8, FF_BN256_FQ)); err == nil {
+		t.Error("Vectors with different element types accepted")
+	}
+	if _, err = LinearCombination([]Element{two}, [][]Element{a, b}); err == nil {
+		t.Error("Inconsistent linear combination accepted")
+	}
+}
+
+func BenchmarkInverse16(b *testing.B) {
+	el := randomVector(16, FF_BN256_FP)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range el {
+			x.Inverse(x)
+		}
+	}
+}
+
+func BenchmarkBatchInverse16(b *testing.B) {
+	el := randomVector(16, FF_BN256_FP)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BatchInverse(el)
+	}
+}
@@ -234,6 +234,13 @@ func NewFromFile(hmacKey []byte, fname string) (*FileCrypt, error) {
 		return nil, fmt.Errorf("readNBytesFromFile : %w", err)
 	}
 	nBlocks := int64(binary.LittleEndian.Uint64(buf[FC_HDR_REG_NBLOCKS_OFFSET:FC_HDR_REG_NONCE_OFFSET]))
+	stats, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Stat file : %w", err)
+	}
+	if nBlocks < 0 || nBlocks > stats.Size() {
+		return nil, fmt.Errorf("Incorrect number of blocks")
+	}
 	fcLen := blockLen(nBlocks)
 
 	buf2, err := readNBytesFromFile(file, int(fcLen))
@@ -244,8 +251,11 @@ func NewFromFile(hmacKey []byte, fname string) (*FileCrypt, error) {
 	fileCrypt := NewFromBytes(buf)
 
 	fileCrypt.fname = fname
-	fileCrypt.hmacKey = make([]byte, len(hmacKey))
-	copy(fileCrypt.hmacKey, hmacKey)
+	// without HMAC key, seal is not checked (only clear blocks can be trusted)
+	if hmacKey != nil {
+		fileCrypt.hmacKey = make([]byte, len(hmacKey))
+		copy(fileCrypt.hmacKey, hmacKey)
+	}
 
 	return fileCrypt, nil
 }
@@ -255,6 +265,9 @@ func (fc FileCrypt) ListTags() [][]byte {
 	var newTag [][]byte
 	for _, el := range fc.blocks {
 		lenEl := el.tag[0]
+		if lenEl > FC_HDR_REG_BTAG_SIZE-1 {
+			lenEl = FC_HDR_REG_BTAG_SIZE - 1
+		}
 		tag := make([]byte, lenEl)
 		copy(tag, el.tag[1:lenEl+1])
 		newTag = append(newTag, tag)
@@ -267,6 +280,44 @@ func (fc FileCrypt) NBlocks() int {
 	return int(fc.nBlocks)
 }
 
+// Description of a FileCrypt block
+type BlockInfo struct {
+	Tag     []byte
+	EncType int   // FC_CLEAR, FC_GCM or FC_RSA
+	Size    int64 // bytes stored, including encryption header
+}
+
+// Returns description of blocks read from their encryption headers. Blocks are not decrypted
+func (fc FileCrypt) ListBlocks() ([]BlockInfo, error) {
+	file, err := openFileR(fc.fname)
+	if err != nil {
+		return nil, fmt.Errorf("Open file : %w", err)
+	}
+	defer file.Close()
+	tags := fc.ListTags()
+	blocks := make([]BlockInfo, 0, len(tags))
+	for idx, tag := range tags {
+		_, err := file.Seek(fc.blocks[idx].offset, 0)
+		if err != nil {
+			return nil, fmt.Errorf("Seek file : %w", err)
+		}
+		hdrE, err := newHdrEncryptFromFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("newHdrEncryptFromFile : %w", err)
+		}
+		hdrB, err := hdrE.toBytes()
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, BlockInfo{
+			Tag:     tag,
+			EncType: int(hdrB[FC_HDR_FCTYPE_OFFSET]),
+			Size:    int64(len(hdrB)) + hdrE.getNBlockBytes(),
+		})
+	}
+	return blocks, nil
+}
+
 // Returns nonce used to derive hmac key
 func (fc *FileCrypt) Nonce() []byte {
 	return fc.nonce
@@ -335,8 +386,8 @@ func (fc FileCrypt) DecryptAll(keyIn []byte) ([]interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Open file : %w", err)
 	}
-	if fc.hmacKey != nil && !fc.checkSeal() {
-		return nil, fmt.Errorf("HMAC error")
+	if err = fc.verify(); err != nil {
+		return nil, err
 	}
 
 	var result []interface{}
@@ -369,8 +420,8 @@ func (fc FileCrypt) DecryptSingle(tag []byte, keyIn []byte) (interface{}, error)
 	if err != nil {
 		return nil, fmt.Errorf("Exceeded number of blocks")
 	}
-	if fc.hmacKey != nil && !fc.checkSeal() {
-		return nil, fmt.Errorf("HMAC error")
+	if err = fc.verify(); err != nil {
+		return nil, err
 	}
 	// open file for reading
 	file, err := openFileR(fc.fname)
@@ -406,6 +457,26 @@ func (fc FileCrypt) HMACRead() ([]byte, error) {
 	return readNBytesFromFile(file, FC_SEAL_LEN)
 }
 
+// Check seal if HMAC key is available, and load key header
+func (fc *FileCrypt) verify() error {
+	if fc.hmacKey != nil {
+		if !fc.checkSeal() {
+			return fmt.Errorf("HMAC error")
+		}
+		return nil
+	}
+	file, err := openFileR(fc.fname)
+	defer file.Close()
+	if err != nil {
+		return fmt.Errorf("Open file : %w", err)
+	}
+	fc.hdrK, err = retrieveKHdr(blockLen(fc.nBlocks)+FC_HDR_REG_BDATA_OFFSET, file)
+	if err != nil {
+		return fmt.Errorf("retrieveKHdr : %w", err)
+	}
+	return nil
+}
+
 // Checks if hmac computed is equal to hmac in FileCrypt object
 func (fc *FileCrypt) checkSeal() bool {
 	// open file for reading
@@ -547,6 +547,20 @@ func TestFCPbkdf2ClearGCM(t *testing.T) {
 			t.Error(err)
 		}
 	}
+	blocks, err := newFC.ListBlocks()
+	if err != nil || len(blocks) != 3 {
+		t.Error("Unexpected blocks", blocks, err)
+	}
+	for idx, encType := range []int{FC_CLEAR, FC_GCM, FC_CLEAR} {
+		if !bytes.Equal(blocks[idx].Tag, []byte(tags[idx])) || blocks[idx].EncType != encType || blocks[idx].Size <= FC_BSIZE_BYTES_128 {
+			t.Error("Unexpected block", blocks[idx])
+		}
+	}
+	missing := *newFC
+	missing.fname = "./testdata/missing.dat"
+	if _, err = missing.ListBlocks(); err == nil {
+		t.Error("Blocks listed from missing file")
+	}
 	result, err := newFC.DecryptAll(key)
 	if err != nil {
 		t.Error(err)
@@ -78,6 +78,18 @@ func openFileR(fname string) (*os.File, error) {
 }
 
 func readNBytesFromFile(f *os.File, n int) ([]byte, error) {
+	// corrupted headers may request more bytes than available
+	stats, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	pos, err := f.Seek(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int64(n) > stats.Size()-pos {
+		return nil, errors.New("Incorrect file format")
+	}
 	buf := make([]byte, n)
 	bytesRead, err := f.Read(buf)
 	if bytesRead < n || err != nil {
@@ -13,14 +13,9 @@ type GcmFc struct {
 // Encrypt data structure and write it/append it as bytetream to a file using GCM 128/256
 //   bits depending on key length
 func (hdr *GcmFc) encrypt(fname string, key []byte, cleartext interface{}) error {
-	cphr, err := aes.NewCipher(key)
-	if err != nil {
-		return fmt.Errorf("NewCipher : %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(cphr)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return fmt.Errorf("NewGCM : %w", err)
+		return err
 	}
 
 	// Encode cleartext to byte stream
@@ -87,14 +82,9 @@ func (hdr *GcmFc) encrypt(fname string, key []byte, cleartext interface{}) error
 // Resulting bytestream is decoded and original data structure retrieved
 func (hdr GcmFc) decrypt(block, key []byte) (interface{}, error) {
 	// init cypher
-	cypher, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("NewCipher : %w", err)
-	}
-
-	gcmDecrypt, err := cipher.NewGCM(cypher)
+	gcmDecrypt, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("NewGCM : %w", err)
+		return nil, err
 	}
 
 	// read nonce
@@ -118,3 +108,45 @@ func (hdr GcmFc) decrypt(block, key []byte) (interface{}, error) {
 	}
 
 }
+
+// Encrypt byte stream in memory using GCM 128/256 bits depending on key length. Additional data ad
+//  is authenticated. Returns nonce followed by ciphertext
+func EncryptGCM(key, cleartext, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := genRandomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, fmt.Errorf("genRandomBytes : %w", err)
+	}
+	return gcm.Seal(nonce, nonce, cleartext, ad), nil
+}
+
+// Decrypt and authenticate byte stream generated by EncryptGCM
+func DecryptGCM(key, sealed, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("Open : ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("Open : %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	cphr, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("NewCipher : %w", err)
+	}
+	gcm, err := cipher.NewGCM(cphr)
+	if err != nil {
+		return nil, fmt.Errorf("NewGCM : %w", err)
+	}
+	return gcm, nil
+}
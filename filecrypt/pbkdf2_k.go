@@ -207,7 +207,7 @@ func (hdr *Pbkdf2Fc) generateKey(fname string) ([]byte, error) {
 func (hdr *Pbkdf2Fc) computeKey() error {
 	switch hdr.hashtype {
 	case FC_HASH_SHA256:
-		hdr.keyOut = pbkdf2.Key(hdr.keyIn, hdr.salt, hdr.iter, hdr.outlen, sha256.New)
+		hdr.keyOut = DeriveKeyPBKDF2(hdr.keyIn, hdr.salt, hdr.iter, hdr.outlen)
 
 	default:
 		return errors.New("Hash not implemented")
@@ -215,3 +215,8 @@ func (hdr *Pbkdf2Fc) computeKey() error {
 
 	return nil
 }
+
+// Derive key of outlen bytes from password using PBKDF2 with SHA256
+func DeriveKeyPBKDF2(password, salt []byte, iter, outlen int) []byte {
+	return pbkdf2.Key(password, salt, iter, outlen, sha256.New)
+}
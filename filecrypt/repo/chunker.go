@@ -0,0 +1,90 @@
+/*
+  Content defined chunking
+
+  Data is split where a gear rolling hash of the last 64 bytes has its AvgBits most significant bits
+  set to zero, so boundaries only depend on local content : inserting or changing bytes only
+  changes the chunks around the modification. Chunks are at least MinSize and at most MaxSize bytes,
+  and AvgBits sets the average chunk size (2^AvgBits bytes after MinSize).
+
+  The gear table is derived from a secret seed, so that chunk sizes don't reveal known contents.
+*/
+
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+type ChunkerParams struct {
+	MinSize int `json:"min"`
+	AvgBits int `json:"avgbits"`
+	MaxSize int `json:"max"`
+}
+
+// Backups are small (KB to few MB). Chunks are 2 KB to 64 KB, 10 KB on average
+var DefaultChunkerParams = ChunkerParams{
+	MinSize: 2 << 10,
+	AvgBits: 13,
+	MaxSize: 64 << 10,
+}
+
+const (
+	CHUNKER_MIN_AVGBITS = 6
+	CHUNKER_MAX_AVGBITS = 30
+	CHUNKER_MAX_SIZE    = 64 << 20
+)
+
+func (p *ChunkerParams) check() error {
+	if p.MinSize <= 0 || p.MaxSize < p.MinSize || p.MaxSize > CHUNKER_MAX_SIZE ||
+		p.AvgBits < CHUNKER_MIN_AVGBITS || p.AvgBits > CHUNKER_MAX_AVGBITS {
+		return errors.New("Repository : invalid chunker parameters")
+	}
+	return nil
+}
+
+type chunker struct {
+	params ChunkerParams
+	gear   [256]uint64
+}
+
+func newChunker(params ChunkerParams, seed []byte) *chunker {
+	c := &chunker{params: params}
+	for idx := range c.gear {
+		h := sha256.Sum256(append(append([]byte{}, seed...), byte(idx)))
+		c.gear[idx] = binary.LittleEndian.Uint64(h[:8])
+	}
+	return c
+}
+
+// Split data into chunks. Chunks share data memory
+func (c *chunker) split(data []byte) [][]byte {
+	chunks := make([][]byte, 0, len(data)>>uint(c.params.AvgBits)+1)
+	for len(data) > 0 {
+		n := c.cut(data)
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+// Returns length of next chunk
+func (c *chunker) cut(data []byte) int {
+	if len(data) <= c.params.MinSize {
+		return len(data)
+	}
+	end := len(data)
+	if end > c.params.MaxSize {
+		end = c.params.MaxSize
+	}
+	shift := uint(64 - c.params.AvgBits)
+	var h uint64
+	for idx := c.params.MinSize; idx < end; idx++ {
+		h = (h << 1) + c.gear[data[idx]]
+		if h>>shift == 0 {
+			return idx + 1
+		}
+	}
+	return end
+}
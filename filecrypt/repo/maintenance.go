@@ -0,0 +1,140 @@
+/*
+  Repository maintenance
+
+  Prune removes chunks not referenced by any snapshot (after snapshots are removed with Forget).
+  Check verifies that snapshots can be decrypted and that all the chunks they reference are
+  stored. Optionally, chunks are read and their contents verified.
+
+  Prune must not run while other clients create snapshots in the same repository.
+*/
+
+package repo
+
+import (
+	"fmt"
+	"strings"
+)
+
+type PruneStats struct {
+	Snapshots    int   // snapshots in repository
+	Chunks       int   // chunks kept
+	Removed      int   // chunks removed
+	RemovedBytes int64 // size of encrypted chunks removed
+}
+
+type CheckResult struct {
+	Snapshots    int     // snapshots checked
+	Chunks       int     // chunks stored
+	Unreferenced int     // chunks not referenced by snapshots. Removed by Prune
+	Errors       []error // problems found
+}
+
+// Remove chunks not referenced by any snapshot
+func (r *Repository) Prune() (*PruneStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// all snapshots need to be readable, so that referenced chunks are not removed
+	snapshots, err := r.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	referenced := referencedChunks(snapshots)
+	objects, err := r.st.List(REPO_DATA_DIR)
+	if err != nil {
+		return nil, fmt.Errorf("List chunks : %w", err)
+	}
+
+	stats := &PruneStats{Snapshots: len(snapshots)}
+	for _, obj := range objects {
+		id := chunkID(obj.Name)
+		if referenced[id] {
+			stats.Chunks++
+			continue
+		}
+		err = r.st.Delete(obj.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Delete %s : %w", obj.Name, err)
+		}
+		stats.Removed++
+		stats.RemovedBytes += obj.Size
+	}
+	return stats, nil
+}
+
+// Verify repository. If readData is set, chunks are decrypted and their ids verified
+func (r *Repository) Check(readData bool) (*CheckResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := &CheckResult{Errors: make([]error, 0)}
+
+	snapshotObjects, err := r.st.List(REPO_SNAPSHOT_DIR)
+	if err != nil {
+		return nil, fmt.Errorf("List snapshots : %w", err)
+	}
+	snapshots := make([]Snapshot, 0, len(snapshotObjects))
+	for _, obj := range snapshotObjects {
+		snapshot, err := r.LoadSnapshot(strings.TrimPrefix(obj.Name, REPO_SNAPSHOT_DIR))
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	result.Snapshots = len(snapshotObjects)
+
+	chunkObjects, err := r.st.List(REPO_DATA_DIR)
+	if err != nil {
+		return nil, fmt.Errorf("List chunks : %w", err)
+	}
+	stored := make(map[string]bool, len(chunkObjects))
+	for _, obj := range chunkObjects {
+		id := chunkID(obj.Name)
+		if !validID(id) || obj.Name != chunkName(id) {
+			result.Errors = append(result.Errors, fmt.Errorf("%s : unexpected object", obj.Name))
+			continue
+		}
+		stored[id] = true
+	}
+	result.Chunks = len(stored)
+
+	referenced := referencedChunks(snapshots)
+	for _, snapshot := range snapshots {
+		for _, item := range snapshot.Items {
+			for _, id := range item.Chunks {
+				if !stored[id] {
+					result.Errors = append(result.Errors,
+						fmt.Errorf("Snapshot %s item %s : missing chunk %s", snapshot.ID, item.Name, id))
+				}
+			}
+		}
+	}
+	for id := range stored {
+		if !referenced[id] {
+			result.Unreferenced++
+		}
+		if readData {
+			if _, err = r.readChunk(id); err != nil {
+				result.Errors = append(result.Errors, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func referencedChunks(snapshots []Snapshot) map[string]bool {
+	referenced := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		for _, item := range snapshot.Items {
+			for _, id := range item.Chunks {
+				referenced[id] = true
+			}
+		}
+	}
+	return referenced
+}
+
+// Returns chunk id from object name
+func chunkID(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
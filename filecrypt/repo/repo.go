@@ -0,0 +1,257 @@
+// Package repo implements a content addressed, deduplicated backup repository.
+//
+// Backup payloads are split into content defined chunks. Every chunk is encrypted and stored once,
+// identified by a keyed hash of its contents, so data that doesn't change between backups is not
+// stored again. Every backup is a snapshot : a small encrypted tree with the names of the backed up
+// items and the chunks they are made of.
+//
+// Repository objects are kept in a store.BackupStore :
+//
+//	config               Repository configuration and master keys encrypted with the password
+//	data/<xx>/<id>       Encrypted chunk. id is the keyed hash of the chunk contents
+//	snapshots/<id>       Encrypted snapshot. id is the keyed hash of the snapshot contents
+//
+// Encrypted objects format :
+//
+//	version    [1 Byte]
+//	nonce      [12 Bytes]
+//	ciphertext [Variable size] : AES-256 GCM. Object name is authenticated as additional data
+//
+// Master keys (encryption key, id key and chunker seed) are generated at random when the repository
+// is initialized, and are encrypted with a key derived from the password with PBKDF2. Encryption
+// and key derivation use package filecrypt.
+package repo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/iden3/go-backup/filecrypt"
+	"github.com/iden3/go-backup/store"
+)
+
+// Repository layout
+const (
+	REPO_VERSION      = 1
+	REPO_CONFIG       = "config"
+	REPO_DATA_DIR     = "data/"
+	REPO_SNAPSHOT_DIR = "snapshots/"
+)
+
+// Key derivation and encryption
+const (
+	REPO_KDF_NITER   = 60000
+	REPO_KDF_SALTLEN = 16
+	REPO_KEY_LEN     = 32
+	REPO_OBJ_VERSION = 1
+)
+
+var (
+	ErrExists        = errors.New("Repository : already initialized")
+	ErrNotRepository = errors.New("Repository : not initialized")
+	ErrWrongPassword = errors.New("Repository : wrong password")
+	ErrCorrupted     = errors.New("Repository : corrupted object")
+)
+
+// Repository configuration. Stored unencrypted, except master keys
+type config struct {
+	Version int           `json:"version"`
+	Salt    []byte        `json:"salt"`
+	Iter    int           `json:"iter"`
+	Keys    []byte        `json:"keys"` // encrypted master keys
+	Chunker ChunkerParams `json:"chunker"`
+}
+
+// Master keys
+type masterKeys struct {
+	EncKey []byte `json:"enc"`  // chunk and snapshot encryption
+	IDKey  []byte `json:"id"`   // keyed hash of chunks and snapshots
+	Seed   []byte `json:"seed"` // chunker gear table
+}
+
+type Repository struct {
+	mu      sync.Mutex
+	st      store.BackupStore
+	cfg     config
+	keys    masterKeys
+	chunker *chunker
+}
+
+// Initialize repository in st protected by password. If params is nil, default chunker
+// parameters are used
+func Init(st store.BackupStore, password []byte, params *ChunkerParams) (*Repository, error) {
+	var err error
+	keys := masterKeys{}
+	for _, k := range []*[]byte{&keys.EncKey, &keys.IDKey, &keys.Seed} {
+		*k, err = genRandomBytes(REPO_KEY_LEN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return initRepository(st, password, params, keys)
+}
+
+// Initialize repository with master keys keys
+func initRepository(st store.BackupStore, password []byte, params *ChunkerParams, keys masterKeys) (*Repository, error) {
+	_, err := st.Stat(REPO_CONFIG)
+	if err == nil {
+		return nil, ErrExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("Stat config : %w", err)
+	}
+	if params == nil {
+		params = &DefaultChunkerParams
+	}
+	if err = params.check(); err != nil {
+		return nil, err
+	}
+
+	salt, err := genRandomBytes(REPO_KDF_SALTLEN)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config{
+		Version: REPO_VERSION,
+		Salt:    salt,
+		Iter:    REPO_KDF_NITER,
+		Chunker: *params,
+	}
+	plainKeys, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Keys, err = encrypt(deriveKey(password, &cfg), REPO_CONFIG, plainKeys)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = st.Put(REPO_CONFIG, data)
+	if err != nil {
+		return nil, fmt.Errorf("Put config : %w", err)
+	}
+	return newRepository(st, cfg, keys), nil
+}
+
+// Open repository in st
+func Open(st store.BackupStore, password []byte) (*Repository, error) {
+	data, err := st.Get(REPO_CONFIG)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrNotRepository
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Get config : %w", err)
+	}
+	var cfg config
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid config : %w", err)
+	}
+	if cfg.Version != REPO_VERSION {
+		return nil, fmt.Errorf("Repository : unsupported version %d", cfg.Version)
+	}
+	if err = cfg.Chunker.check(); err != nil {
+		return nil, err
+	}
+	if cfg.Iter <= 0 || cfg.Iter > 10*REPO_KDF_NITER {
+		return nil, fmt.Errorf("Invalid config : incorrect key derivation parameters")
+	}
+	plainKeys, err := decrypt(deriveKey(password, &cfg), REPO_CONFIG, cfg.Keys)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	var keys masterKeys
+	err = json.Unmarshal(plainKeys, &keys)
+	if err != nil || len(keys.EncKey) != REPO_KEY_LEN || len(keys.IDKey) != REPO_KEY_LEN || len(keys.Seed) != REPO_KEY_LEN {
+		return nil, ErrCorrupted
+	}
+	return newRepository(st, cfg, keys), nil
+}
+
+func newRepository(st store.BackupStore, cfg config, keys masterKeys) *Repository {
+	return &Repository{
+		st:      st,
+		cfg:     cfg,
+		keys:    keys,
+		chunker: newChunker(cfg.Chunker, keys.Seed),
+	}
+}
+
+// Key to encrypt master keys
+func deriveKey(password []byte, cfg *config) []byte {
+	return filecrypt.DeriveKeyPBKDF2(password, cfg.Salt, cfg.Iter, REPO_KEY_LEN)
+}
+
+// Keyed hash identifying contents
+func (r *Repository) id(data []byte) string {
+	h := hmac.New(sha256.New, r.keys.IDKey)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Store encrypted object. Name is authenticated, so objects cannot be swapped
+func (r *Repository) putObject(name string, data []byte) error {
+	ciphertext, err := encrypt(r.keys.EncKey, name, data)
+	if err != nil {
+		return err
+	}
+	err = r.st.Put(name, ciphertext)
+	if err != nil {
+		return fmt.Errorf("Put %s : %w", name, err)
+	}
+	return nil
+}
+
+func (r *Repository) getObject(name string) ([]byte, error) {
+	ciphertext, err := r.st.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("Get %s : %w", name, err)
+	}
+	data, err := decrypt(r.keys.EncKey, name, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", name, ErrCorrupted)
+	}
+	return data, nil
+}
+
+func chunkName(id string) string {
+	return REPO_DATA_DIR + id[:2] + "/" + id
+}
+
+func snapshotName(id string) string {
+	return REPO_SNAPSHOT_DIR + id
+}
+
+func encrypt(key []byte, name string, cleartext []byte) ([]byte, error) {
+	sealed, err := filecrypt.EncryptGCM(key, cleartext, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{REPO_OBJ_VERSION}, sealed...), nil
+}
+
+func decrypt(key []byte, name string, obj []byte) ([]byte, error) {
+	if len(obj) < 1 || obj[0] != REPO_OBJ_VERSION {
+		return nil, ErrCorrupted
+	}
+	return filecrypt.DecryptGCM(key, obj[1:], []byte(name))
+}
+
+// Generate N random bytes.
+func genRandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,287 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/iden3/go-backup/store"
+)
+
+const TEST_PASSWORD = "my repository password"
+
+func newTestStore(t *testing.T) (*store.LocalStore, string) {
+	dir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st, dir
+}
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+// Number of chunks referenced by s and not by others
+func uniqueChunks(s *Snapshot, others ...*Snapshot) int {
+	referenced := make(map[string]bool)
+	for _, other := range others {
+		for _, item := range other.Items {
+			for _, id := range item.Chunks {
+				referenced[id] = true
+			}
+		}
+	}
+	unique := make(map[string]bool)
+	for _, item := range s.Items {
+		for _, id := range item.Chunks {
+			if !referenced[id] {
+				unique[id] = true
+			}
+		}
+	}
+	return len(unique)
+}
+
+func TestInitOpen(t *testing.T) {
+	st, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := Open(st, []byte(TEST_PASSWORD)); !errors.Is(err, ErrNotRepository) {
+		t.Error("Opened missing repository", err)
+	}
+	if _, err := Init(st, []byte(TEST_PASSWORD), &ChunkerParams{MinSize: 10, AvgBits: 2, MaxSize: 100}); err == nil {
+		t.Error("Invalid chunker parameters accepted")
+	}
+	r, err := Init(st, []byte(TEST_PASSWORD), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Init(st, []byte(TEST_PASSWORD), nil); !errors.Is(err, ErrExists) {
+		t.Error("Repository initialized twice", err)
+	}
+	if _, err = Open(st, []byte("wrong password")); !errors.Is(err, ErrWrongPassword) {
+		t.Error("Opened with wrong password", err)
+	}
+	r2, err := Open(st, []byte(TEST_PASSWORD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r.keys.EncKey, r2.keys.EncKey) || r.id([]byte("x")) != r2.id([]byte("x")) {
+		t.Error("Unexpected keys")
+	}
+	// master keys are not stored in clear
+	config, _ := st.Get(REPO_CONFIG)
+	if bytes.Contains(config, r.keys.EncKey) || bytes.Contains(config, r.keys.IDKey) {
+		t.Error("Master keys stored in clear")
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	st, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+	// fixed keys, so that chunk boundaries don't change between runs
+	keys := masterKeys{EncKey: randomBytes(10, REPO_KEY_LEN), IDKey: randomBytes(11, REPO_KEY_LEN), Seed: randomBytes(12, REPO_KEY_LEN)}
+	r, err := initRepository(st, []byte(TEST_PASSWORD), nil, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet := randomBytes(1, 256<<10)
+	items := map[string][]byte{"wallet": wallet, "config/settings": []byte("small item"), "empty": {}}
+	s1, stats, err := r.CreateSnapshot("day 1", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NewChunks != stats.Chunks || stats.Chunks < 10 || stats.Bytes != int64(len(wallet)+10) {
+		t.Error("Unexpected stats", stats)
+	}
+
+	// small change -> few new chunks
+	wallet2 := append([]byte{}, wallet...)
+	copy(wallet2[100<<10:], []byte("modified contents"))
+	items["wallet"] = wallet2
+	s2, stats, err := r.CreateSnapshot("day 2", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NewChunks != 1 || uniqueChunks(s2, s1) != 1 || stats.Chunks != 30 || stats.StoredBytes > stats.Bytes/10 {
+		t.Error("Unchanged data stored again", stats)
+	}
+	// reopened repository knows stored chunks
+	r, _ = Open(st, []byte(TEST_PASSWORD))
+	s3, stats, err := r.CreateSnapshot("day 3", items)
+	if err != nil || stats.NewChunks != 0 {
+		t.Error("Unchanged data stored again", stats, err)
+	}
+
+	snapshots, err := r.ListSnapshots()
+	if err != nil || len(snapshots) != 3 || snapshots[0].ID != s1.ID || snapshots[2].Name != "day 3" {
+		t.Error("Unexpected snapshots", snapshots, err)
+	}
+	restored, err := r.Restore(s1.ID)
+	if err != nil || !bytes.Equal(restored["wallet"], wallet) || string(restored["config/settings"]) != "small item" ||
+		len(restored["empty"]) != 0 || len(restored) != 3 {
+		t.Error("Unexpected restored snapshot", err)
+	}
+	data, err := r.ReadItem(s2, "wallet")
+	if err != nil || !bytes.Equal(data, wallet2) {
+		t.Error("Unexpected item", err)
+	}
+	if _, err = r.ReadItem(s2, "missing"); err == nil {
+		t.Error("Missing item read")
+	}
+	dst, _ := store.NewLocalStore(dir + "/restored")
+	if err = r.RestoreTo(s3.ID, dst); err != nil {
+		t.Error(err)
+	}
+	if data, _ = ioutil.ReadFile(dir + "/restored/config/settings"); string(data) != "small item" {
+		t.Error("Unexpected restored file")
+	}
+
+	// prune
+	stats2, err := r.Prune()
+	if err != nil || stats2.Removed != 0 {
+		t.Error("Referenced chunks pruned", stats2, err)
+	}
+	if err = r.Forget(s1.ID); err != nil {
+		t.Error(err)
+	}
+	stats2, err = r.Prune()
+	if err != nil || stats2.Removed == 0 || stats2.Removed != uniqueChunks(s1, s2, s3) || stats2.Snapshots != 2 {
+		t.Error("Unexpected prune", stats2, err)
+	}
+	if _, err = r.Restore(s1.ID); err == nil {
+		t.Error("Forgotten snapshot restored")
+	}
+	if restored, err = r.Restore(s2.ID); err != nil || !bytes.Equal(restored["wallet"], wallet2) {
+		t.Error("Unexpected restored snapshot", err)
+	}
+	result, err := r.Check(true)
+	if err != nil || len(result.Errors) != 0 || result.Snapshots != 2 || result.Unreferenced != 0 {
+		t.Error("Unexpected check", result, err)
+	}
+	if err = r.Forget("../config"); err == nil {
+		t.Error("Invalid snapshot id accepted")
+	}
+}
+
+// Chunks removed by other clients are stored again
+func TestRemovedChunks(t *testing.T) {
+	st, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+	r, _ := Init(st, []byte(TEST_PASSWORD), nil)
+	items := map[string][]byte{"data": randomBytes(5, 64<<10)}
+	snapshot, _, err := r.CreateSnapshot("day 1", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another client forgets the snapshot and prunes its chunks
+	other, _ := Open(st, []byte(TEST_PASSWORD))
+	if err = other.Forget(snapshot.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = other.Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, stats, err := r.CreateSnapshot("day 2", items)
+	if err != nil || stats.NewChunks != stats.Chunks {
+		t.Error("Removed chunks not stored again", stats, err)
+	}
+	if restored, err := r.Restore(snapshot.ID); err != nil || !bytes.Equal(restored["data"], items["data"]) {
+		t.Error("Unexpected restored snapshot", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	st, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+	r, _ := Init(st, []byte(TEST_PASSWORD), nil)
+	snapshot, _, err := r.CreateSnapshot("backup", map[string][]byte{"data": randomBytes(2, 64<<10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := snapshot.Items[0].Chunks
+	if len(chunks) < 3 {
+		t.Fatal("Not enough chunks")
+	}
+
+	// swapped chunk : name is authenticated
+	swapped, _ := st.Get(chunkName(chunks[1]))
+	st.Put(chunkName(chunks[0]), swapped)
+	// corrupted chunk
+	st.Put(chunkName(chunks[1]), []byte("corrupted"))
+	// missing chunk
+	st.Delete(chunkName(chunks[2]))
+	// corrupted snapshot
+	st.Put(snapshotName(chunks[2]), swapped)
+
+	result, err := r.Check(false)
+	if err != nil || len(result.Errors) != 2 || result.Snapshots != 2 {
+		t.Error("Unexpected check", result, err)
+	}
+	result, err = r.Check(true)
+	if err != nil || len(result.Errors) != 4 {
+		t.Error("Unexpected check", result, err)
+	}
+	if _, err = r.Restore(snapshot.ID); !errors.Is(err, ErrCorrupted) {
+		t.Error("Corrupted snapshot restored", err)
+	}
+	if _, err = r.Prune(); err == nil {
+		t.Error("Pruned with unreadable snapshot")
+	}
+}
+
+func TestChunker(t *testing.T) {
+	params := ChunkerParams{MinSize: 256, AvgBits: 10, MaxSize: 4096}
+	c := newChunker(params, []byte("seed"))
+	data := randomBytes(3, 1<<20)
+	chunks := c.split(data)
+
+	var joined []byte
+	for idx, chunk := range chunks {
+		if len(chunk) > params.MaxSize || (len(chunk) < params.MinSize && idx != len(chunks)-1) {
+			t.Error("Unexpected chunk size", len(chunk))
+		}
+		joined = append(joined, chunk...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("Chunks don't match data")
+	}
+	if avg := len(data) / len(chunks); avg < 1000 || avg > 1600 {
+		t.Error("Unexpected average chunk size", avg)
+	}
+
+	// inserted bytes only change neighbour chunks
+	ids := make(map[string]bool)
+	for _, chunk := range chunks {
+		ids[string(chunk)] = true
+	}
+	shifted := append(randomBytes(4, 100), data...)
+	same := 0
+	for _, chunk := range c.split(shifted) {
+		if ids[string(chunk)] {
+			same++
+		}
+	}
+	if same < len(chunks)-2 {
+		t.Error("Chunk boundaries depend on offset", same, len(chunks))
+	}
+
+	// boundaries depend on seed
+	other := newChunker(params, []byte("other seed")).split(data)
+	if len(other[0]) == len(chunks[0]) && len(other[1]) == len(chunks[1]) && len(other[2]) == len(chunks[2]) {
+		t.Error("Chunk boundaries don't depend on seed")
+	}
+}
@@ -0,0 +1,241 @@
+/*
+  Snapshots
+
+  A snapshot records the items (named byte streams) included in a backup and the chunks each item
+  is made of. Chunks already stored in the repository are not uploaded again. Stored chunks are
+  listed every time a snapshot is created, so chunks removed by other clients are uploaded again.
+*/
+
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-backup/store"
+)
+
+type Snapshot struct {
+	ID    string    `json:"-"`
+	Name  string    `json:"name"`
+	Time  time.Time `json:"time"`
+	Items []Item    `json:"items"` // sorted by name
+}
+
+type Item struct {
+	Name   string   `json:"name"`
+	Size   int64    `json:"size"`
+	Chunks []string `json:"chunks"` // chunk ids
+}
+
+// Statistics of snapshot creation
+type SnapshotStats struct {
+	Chunks      int   // chunks referenced by snapshot
+	NewChunks   int   // chunks stored
+	Bytes       int64 // size of items
+	StoredBytes int64 // size of chunks stored
+}
+
+// Create snapshot with items (item name -> contents)
+func (r *Repository) CreateSnapshot(name string, items map[string][]byte) (*Snapshot, *SnapshotStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	known, err := r.storedChunks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(items))
+	for itemName := range items {
+		names = append(names, itemName)
+	}
+	sort.Strings(names)
+
+	snapshot := &Snapshot{Name: name, Time: time.Now().UTC(), Items: make([]Item, 0, len(items))}
+	stats := &SnapshotStats{}
+	for _, itemName := range names {
+		data := items[itemName]
+		item := Item{Name: itemName, Size: int64(len(data)), Chunks: make([]string, 0)}
+		for _, chunk := range r.chunker.split(data) {
+			id := r.id(chunk)
+			item.Chunks = append(item.Chunks, id)
+			stats.Chunks++
+			if known[id] {
+				continue
+			}
+			err := r.putObject(chunkName(id), chunk)
+			if err != nil {
+				return nil, nil, err
+			}
+			known[id] = true
+			stats.NewChunks++
+			stats.StoredBytes += int64(len(chunk))
+		}
+		stats.Bytes += item.Size
+		snapshot.Items = append(snapshot.Items, item)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot.ID = r.id(data)
+	err = r.putObject(snapshotName(snapshot.ID), data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshot, stats, nil
+}
+
+// Returns snapshots sorted by time, oldest first
+func (r *Repository) ListSnapshots() ([]Snapshot, error) {
+	objects, err := r.st.List(REPO_SNAPSHOT_DIR)
+	if err != nil {
+		return nil, fmt.Errorf("List snapshots : %w", err)
+	}
+	snapshots := make([]Snapshot, 0, len(objects))
+	for _, obj := range objects {
+		snapshot, err := r.LoadSnapshot(strings.TrimPrefix(obj.Name, REPO_SNAPSHOT_DIR))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// Returns snapshot with identifier id
+func (r *Repository) LoadSnapshot(id string) (*Snapshot, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("Repository : invalid snapshot id")
+	}
+	data, err := r.getObject(snapshotName(id))
+	if err != nil {
+		return nil, err
+	}
+	if r.id(data) != id {
+		return nil, fmt.Errorf("%s : %w", snapshotName(id), ErrCorrupted)
+	}
+	var snapshot Snapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", snapshotName(id), ErrCorrupted)
+	}
+	snapshot.ID = id
+	return &snapshot, nil
+}
+
+// Returns contents of item name in snapshot
+func (r *Repository) ReadItem(snapshot *Snapshot, name string) ([]byte, error) {
+	for _, item := range snapshot.Items {
+		if item.Name == name {
+			return r.readItem(&item)
+		}
+	}
+	return nil, fmt.Errorf("Repository : item %s not found", name)
+}
+
+func (r *Repository) readItem(item *Item) ([]byte, error) {
+	var b bytes.Buffer
+	for _, id := range item.Chunks {
+		chunk, err := r.readChunk(id)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(chunk)
+	}
+	if int64(b.Len()) != item.Size {
+		return nil, fmt.Errorf("Item %s : %w", item.Name, ErrCorrupted)
+	}
+	return b.Bytes(), nil
+}
+
+// Read chunk and verify its contents match id
+func (r *Repository) readChunk(id string) ([]byte, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("Repository : invalid chunk id")
+	}
+	chunk, err := r.getObject(chunkName(id))
+	if err != nil {
+		return nil, err
+	}
+	if r.id(chunk) != id {
+		return nil, fmt.Errorf("%s : %w", chunkName(id), ErrCorrupted)
+	}
+	return chunk, nil
+}
+
+// Returns all items in snapshot id (item name -> contents)
+func (r *Repository) Restore(id string) (map[string][]byte, error) {
+	snapshot, err := r.LoadSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]byte, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		items[item.Name], err = r.readItem(&item)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Write items in snapshot id to dst (for example, a store.LocalStore with the restore folder).
+// Item names need to be valid object names
+func (r *Repository) RestoreTo(id string, dst store.BackupStore) error {
+	items, err := r.Restore(id)
+	if err != nil {
+		return err
+	}
+	for name, data := range items {
+		err = dst.Put(name, data)
+		if err != nil {
+			return fmt.Errorf("Put %s : %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Remove snapshot id. Chunks are removed by Prune
+func (r *Repository) Forget(id string) error {
+	if !validID(id) {
+		return fmt.Errorf("Repository : invalid snapshot id")
+	}
+	err := r.st.Delete(snapshotName(id))
+	if err != nil {
+		return fmt.Errorf("Delete snapshot : %w", err)
+	}
+	return nil
+}
+
+// Returns ids of chunks stored in repository
+func (r *Repository) storedChunks() (map[string]bool, error) {
+	objects, err := r.st.List(REPO_DATA_DIR)
+	if err != nil {
+		return nil, fmt.Errorf("List chunks : %w", err)
+	}
+	stored := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		stored[chunkID(obj.Name)] = true
+	}
+	return stored, nil
+}
+
+// Ids are hex encoded keyed hashes
+func validID(id string) bool {
+	if len(id) != 2*REPO_KEY_LEN {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
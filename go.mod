@@ -9,7 +9,6 @@ require (
 	github.com/iden3/iden3-mobile/go v0.0.0-20200520133806-eeafb3ac4801
 	github.com/skip2/go-qrcode v0.0.0-20191027152451-9434209cb086
 	golang.org/x/crypto v0.0.0-20200414173820-0848c9571904
-	golang.org/x/net v0.0.0-20200226121028-0de0cce0169b // indirect
+	golang.org/x/net v0.0.0-20200226121028-0de0cce0169b
 	golang.org/x/sys v0.0.0-20200413165638-669c56c373c4
 )
-
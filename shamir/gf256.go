@@ -0,0 +1,103 @@
+/*
+  GF(2^8) arithmetic used for byte-wise secret sharing.
+
+  Field is defined by the Rijndael (AES) irreducible polynomial x^8 + x^4 + x^3 + x + 1 (0x11b).
+  Multiplication and inversion use log/exp tables generated from 0x03.
+*/
+
+package shamir
+
+// Element type identifying byte-wise sharing over GF(2^8). It is outside the range of
+// ff element types
+const FF_GF256 = 0xff
+
+const (
+	GF256_POLY      = 0x11b
+	GF256_GENERATOR = 0x03
+	GF256_ORDER     = 255
+)
+
+var gf256Exp [2 * GF256_ORDER]byte
+var gf256Log [256]int
+
+func init() {
+	x := 1
+	for idx := 0; idx < GF256_ORDER; idx++ {
+		gf256Exp[idx] = byte(x)
+		gf256Exp[idx+GF256_ORDER] = byte(x)
+		gf256Log[x] = idx
+		// x = x * 0x03 = x * 0x02 + x
+		x2 := x << 1
+		if x2&0x100 != 0 {
+			x2 ^= GF256_POLY
+		}
+		x ^= x2
+	}
+}
+
+// a + b and a - b in GF(2^8)
+func gf256Add(a, b byte) byte {
+	return a ^ b
+}
+
+// a * b in GF(2^8)
+func gf256Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf256Exp[gf256Log[a]+gf256Log[b]]
+}
+
+// a / b in GF(2^8). b needs to be != 0
+func gf256Div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gf256Exp[gf256Log[a]+GF256_ORDER-gf256Log[b]]
+}
+
+// Evaluate poly at x using Horner's rule. poly[0] is the constant term
+func gf256Eval(poly []byte, x byte) byte {
+	var y byte
+	for idx := len(poly) - 1; idx >= 0; idx-- {
+		y = gf256Add(gf256Mul(y, x), poly[idx])
+	}
+	return y
+}
+
+// Lagrange interpolation at x = 0 of points (px[i], py[i])
+func gf256InterpolateZero(px, py []byte) byte {
+	var y byte
+	for j := range px {
+		l := byte(1)
+		for m := range px {
+			if m == j {
+				continue
+			}
+			// px[m] / (px[m] - px[j])
+			l = gf256Mul(l, gf256Div(px[m], gf256Add(px[m], px[j])))
+		}
+		y = gf256Add(y, gf256Mul(l, py[j]))
+	}
+	return y
+}
+
+// Lagrange interpolation at x of points (px[i], py[i])
+func gf256Interpolate(px, py []byte, x byte) byte {
+	var y byte
+	for j := range px {
+		if px[j] == x {
+			return py[j]
+		}
+		l := byte(1)
+		for m := range px {
+			if m == j {
+				continue
+			}
+			// (x - px[m]) / (px[j] - px[m])
+			l = gf256Mul(l, gf256Div(gf256Add(x, px[m]), gf256Add(px[j], px[m])))
+		}
+		y = gf256Add(y, gf256Mul(l, py[j]))
+	}
+	return y
+}
@@ -0,0 +1,164 @@
+/*
+  Precomputed Lagrange basis for secret reconstruction.
+
+  For a fixed set of x-coordinates x[0],...,x[k-1], secret is recovered as
+     secret = Sum_j y[j] * l[j],  l[j] = Prod_m!=j x[m] / (x[m] - x[j])
+  Coefficients l[j] only depend on the x-coordinates, so they can be computed once and reused to
+  reconstruct many secrets shared among the same custodians with k multiplications each.
+
+  Denominators are inverted in a batch (Montgomery's trick), requiring a single field inversion.
+*/
+
+package shamir
+
+import (
+	"errors"
+
+	"github.com/iden3/go-backup/ff"
+)
+
+type LagrangeBasis struct {
+	Px          []ff.Element // x-coordinates
+	Coeffs      []ff.Element // Lagrange coefficients at x = 0
+	ElementType int
+}
+
+// Compute Lagrange basis at x = 0 for x-coordinates px. Coordinates need to be nonzero and distinct
+func NewLagrangeBasis(px []ff.Element, elType int) (*LagrangeBasis, error) {
+	if len(px) == 0 {
+		return nil, errors.New("Shamir's Secret : No shares provided")
+	}
+	for idx, x := range px {
+		if x == nil || x.IsZero() {
+			return nil, errors.New("Shamir's Secret : Invalid share x-coordinate")
+		}
+		for _, prev := range px[:idx] {
+			if prev.Equal(x) {
+				return nil, errors.New("Shamir's Secret : Duplicated share")
+			}
+		}
+	}
+
+	k := len(px)
+	num := make([]ff.Element, k)
+	den := make([]ff.Element, k)
+	dFF, _ := ff.NewElement(elType)
+
+	// num[j] = Prod_m!=j x[m] from prefix and suffix products
+	acc, _ := ff.NewElement(elType)
+	acc.SetOne()
+	for j := range px {
+		num[j], _ = ff.NewElement(elType)
+		num[j].Set(acc)
+		acc.MulAssign(px[j])
+	}
+	acc.SetOne()
+	for j := k - 1; j >= 0; j-- {
+		num[j].MulAssign(acc)
+		acc.MulAssign(px[j])
+	}
+
+	// den[j] = Prod_m!=j (x[m] - x[j])
+	for j := range px {
+		den[j], _ = ff.NewElement(elType)
+		den[j].SetOne()
+		for m := range px {
+			if m == j {
+				continue
+			}
+			dFF.Sub(px[m], px[j])
+			den[j].MulAssign(dFF)
+		}
+	}
+	ff.BatchInverse(den)
+
+	basis := &LagrangeBasis{
+		Px:          make([]ff.Element, k),
+		Coeffs:      num,
+		ElementType: elType,
+	}
+	for j := range px {
+		basis.Px[j], _ = ff.NewElement(elType)
+		basis.Px[j].Set(px[j])
+		basis.Coeffs[j].MulAssign(den[j])
+	}
+
+	return basis, nil
+}
+
+// Compute Lagrange basis for the x-coordinates of shares
+func (s Shamir) NewLagrangeBasis(shares []Share) (*LagrangeBasis, error) {
+	if err := s.checkPrimeField(); err != nil {
+		return nil, err
+	}
+	px := make([]ff.Element, len(shares))
+	for idx, share := range shares {
+		px[idx] = share.Px
+	}
+	return NewLagrangeBasis(px, s.ElementType)
+}
+
+// Reconstruct secret from y-coordinates py. py[j] needs to be the y-coordinate at Px[j]
+func (b *LagrangeBasis) Interpolate(py []ff.Element) (ff.Element, error) {
+	if len(py) != len(b.Coeffs) {
+		return nil, errors.New("Shamir's Secret : Number of shares does not match Lagrange basis")
+	}
+	return ff.InnerProduct(b.Coeffs, py)
+}
+
+// Reconstruct secret from shares. Shares need to be in the same order as basis x-coordinates
+func (b *LagrangeBasis) GenerateSecret(shares []Share) (ff.Element, error) {
+	if len(shares) != len(b.Px) {
+		return nil, errors.New("Shamir's Secret : Number of shares does not match Lagrange basis")
+	}
+	py := make([]ff.Element, len(shares))
+	for idx, share := range shares {
+		if share.Px == nil || !share.Px.Equal(b.Px[idx]) {
+			return nil, errors.New("Shamir's Secret : Share x-coordinate does not match Lagrange basis")
+		}
+		py[idx] = share.Py
+	}
+	return b.Interpolate(py)
+}
+
+// Reconstruct a batch of secrets. Every entry in sets holds the shares of one secret. Lagrange basis
+// is computed once per distinct (ordered) x-coordinate set
+func (s Shamir) GenerateSecrets(sets [][]Share) ([]ff.Element, error) {
+	secrets := make([]ff.Element, len(sets))
+	var basis *LagrangeBasis
+	var err error
+
+	for setIdx, shares := range sets {
+		if err = s.checkThreshold(len(shares)); err != nil {
+			return nil, err
+		}
+		if err = checkShares(shares, s.MinShares, s.MaxShares); err != nil {
+			return nil, err
+		}
+		if basis == nil || !basis.matches(shares) {
+			basis, err = s.NewLagrangeBasis(shares)
+			if err != nil {
+				return nil, err
+			}
+		}
+		secrets[setIdx], err = basis.GenerateSecret(shares)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return secrets, nil
+}
+
+// Returns true if shares x-coordinates match basis
+func (b *LagrangeBasis) matches(shares []Share) bool {
+	if len(shares) != len(b.Px) {
+		return false
+	}
+	for idx, share := range shares {
+		if !share.Px.Equal(b.Px[idx]) {
+			return false
+		}
+	}
+	return true
+}
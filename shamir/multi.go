@@ -0,0 +1,215 @@
+/*
+  Multi-element secret sharing.
+
+  Secrets longer than one field element are given as []ff.Element and can be shared in two ways:
+   - MULTI_INDEPENDENT : every element is shared with its own polynomial using a common x-coordinate set.
+                         Each share holds one y-coordinate per secret element.
+   - MULTI_PACKED      : Franklin-Yung packed sharing. The l secret elements are embedded as evaluations
+                         of a single polynomial f of degree MinShares-1 at x = -1,...,-l, and the remaining
+                         MinShares-l evaluations at x = -(l+1),...,-MinShares are random. Each share holds a
+                         single y-coordinate f(i). Any MinShares shares recover all l secrets, while any
+                         MinShares-l shares reveal nothing about them.
+*/
+
+package shamir
+
+import (
+	"errors"
+
+	"github.com/iden3/go-backup/ff"
+	"github.com/iden3/go-backup/ff/poly"
+)
+
+// Multi-element sharing modes
+const (
+	MULTI_INDEPENDENT = iota
+	MULTI_PACKED
+)
+
+// Generate shares of a multi-element secret using the given mode
+func (s Shamir) GenerateMultiShares(secret []ff.Element, mode int) ([]MultiShare, error) {
+	if err := s.checkPrimeField(); err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("Shamir's Secret : Empty secret")
+	}
+	if len(secret) > MULTI_MAX_SECRETS {
+		return nil, errors.New("Shamir's Secret : Too many secret elements")
+	}
+
+	switch mode {
+	case MULTI_INDEPENDENT:
+		return s.generateIndependentShares(secret)
+
+	case MULTI_PACKED:
+		return s.generatePackedShares(secret)
+
+	default:
+		return nil, errors.New("Shamir's Secret : Unknown multi-element sharing mode")
+	}
+}
+
+// Generate multi-element secret from shares. Mode and number of secret elements are read from shares
+func (s Shamir) GenerateMultiSecret(shares []MultiShare) ([]ff.Element, error) {
+	if err := s.checkPrimeField(); err != nil {
+		return nil, err
+	}
+	if len(shares) == 0 {
+		return nil, errors.New("Shamir's Secret : No shares provided")
+	}
+	// independent sharings need MinShares shares per element, and packed sharing MinShares
+	// evaluations of its polynomial of degree MinShares-1
+	if err := s.checkThreshold(len(shares)); err != nil {
+		return nil, err
+	}
+	mode := shares[0].Mode
+	nSecrets := shares[0].NSecrets
+	for idx, share := range shares {
+		if share.Mode != mode || share.NSecrets != nSecrets {
+			return nil, errors.New("Shamir's Secret : Inconsistent share format")
+		}
+		if share.Px == nil || share.Px.IsZero() {
+			return nil, errors.New("Shamir's Secret : Invalid share x-coordinate")
+		}
+		for _, prev := range shares[:idx] {
+			if prev.Px.Equal(share.Px) {
+				return nil, errors.New("Shamir's Secret : Duplicated share")
+			}
+		}
+	}
+
+	switch mode {
+	case MULTI_INDEPENDENT:
+		return s.generateIndependentSecret(shares)
+
+	case MULTI_PACKED:
+		return s.generatePackedSecret(shares)
+
+	default:
+		return nil, errors.New("Shamir's Secret : Unknown multi-element sharing mode")
+	}
+}
+
+// Share every element independently. Element sharings use x = 1..MaxShares (RandomPx is ignored),
+// so all of them have a common x-coordinate set
+func (s Shamir) generateIndependentShares(secret []ff.Element) ([]MultiShare, error) {
+	s.RandomPx = false
+	shares := make([]MultiShare, s.MaxShares)
+	for idx := range shares {
+		px, _ := ff.NewElement(s.ElementType)
+		shares[idx] = MultiShare{
+			Px:       px.SetUint64(uint64(idx + 1)),
+			Py:       make([]ff.Element, len(secret)),
+			Mode:     MULTI_INDEPENDENT,
+			NSecrets: len(secret),
+		}
+	}
+
+	for elIdx, el := range secret {
+		elShares, err := s.GenerateShares(el)
+		if err != nil {
+			return nil, err
+		}
+		for idx, elShare := range elShares {
+			shares[idx].Py[elIdx] = elShare.Py
+		}
+	}
+
+	return shares, nil
+}
+
+func (s Shamir) generateIndependentSecret(shares []MultiShare) ([]ff.Element, error) {
+	nSecrets := shares[0].NSecrets
+	secret := make([]ff.Element, nSecrets)
+	elShares := make([]Share, len(shares))
+
+	for elIdx := range secret {
+		for idx, share := range shares {
+			if len(share.Py) != nSecrets {
+				return nil, errors.New("Shamir's Secret : Inconsistent share format")
+			}
+			elShares[idx] = Share{Px: share.Px, Py: share.Py[elIdx]}
+		}
+		el, err := s.GenerateSecret(elShares)
+		if err != nil {
+			return nil, err
+		}
+		secret[elIdx] = el
+	}
+
+	return secret, nil
+}
+
+// Packed sharing. f is defined by its values at x = -1..-MinShares (secrets followed by random
+// elements), and share i is f(i) with f computed by Lagrange interpolation
+func (s Shamir) generatePackedShares(secret []ff.Element) ([]MultiShare, error) {
+	nSecrets := len(secret)
+	if nSecrets >= s.MinShares {
+		return nil, errors.New("Shamir's Secret : Packed sharing requires less secret elements than minimum shares")
+	}
+
+	px := make([]ff.Element, s.MinShares)
+	py := make([]ff.Element, s.MinShares)
+	for idx := range px {
+		px[idx] = packedSecretPoint(idx, s.ElementType)
+		if idx < nSecrets {
+			py[idx], _ = ff.NewElement(s.ElementType)
+			py[idx].Set(secret[idx])
+		} else {
+			py[idx], _ = ff.NewElement(s.ElementType)
+			if _, err := py[idx].SetRandomFrom(s.randomSource()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f, err := poly.Interpolate(px, py, s.ElementType)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]MultiShare, s.MaxShares)
+	for idx := range shares {
+		x, _ := ff.NewElement(s.ElementType)
+		x.SetUint64(uint64(idx + 1))
+		shares[idx] = MultiShare{
+			Px:       x,
+			Py:       []ff.Element{f.Eval(x)},
+			Mode:     MULTI_PACKED,
+			NSecrets: nSecrets,
+		}
+	}
+
+	return shares, nil
+}
+
+func (s Shamir) generatePackedSecret(shares []MultiShare) ([]ff.Element, error) {
+	px := make([]ff.Element, len(shares))
+	py := make([]ff.Element, len(shares))
+	for idx, share := range shares {
+		if len(share.Py) != 1 {
+			return nil, errors.New("Shamir's Secret : Inconsistent share format")
+		}
+		px[idx] = share.Px
+		py[idx] = share.Py[0]
+	}
+
+	f, err := poly.Interpolate(px, py, s.ElementType)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]ff.Element, shares[0].NSecrets)
+	for idx := range secret {
+		secret[idx] = f.Eval(packedSecretPoint(idx, s.ElementType))
+	}
+
+	return secret, nil
+}
+
+// Evaluation point of secret idx in packed sharing : x = -(idx+1)
+func packedSecretPoint(idx, elType int) ff.Element {
+	x, _ := ff.NewElement(elType)
+	x.SetUint64(uint64(idx + 1))
+	return x.Neg(x)
+}
@@ -0,0 +1,372 @@
+/*
+  Access structure policies on top of Shamir's secret sharing.
+
+  A policy is a tree. Leaves are custodians holding Weight shares. Internal nodes define a threshold
+  over the weights of their children:
+    - Weighted threshold  : threshold node whose children are leaves with different weights
+    - Hierarchical        : threshold node whose children are other threshold nodes (for example,
+                            1 of the family members AND 2 of the friends)
+    - AND / OR            : threshold nodes requiring all or any of their children
+
+  Secret is shared at the root with a (Threshold, Sum of children weights) configuration. Internal
+  children receive a single share, which is shared again among their own children.
+*/
+
+package shamir
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/iden3/go-backup/ff"
+)
+
+type Policy struct {
+	Threshold int      // internal node : minimum weight of children required
+	Weight    int      // leaf : number of shares held by custodian
+	Custodian string   // leaf : custodian nickname
+	Children  []Policy // internal node : sub-policies
+}
+
+// Share generated by a policy. Path identifies the node (child indexes from root)
+// whose polynomial generated the share
+type PolicyShare struct {
+	Path  []int
+	Share Share
+}
+
+// Create custodian leaf holding weight shares
+func NewCustodianPolicy(nickname string, weight int) Policy {
+	return Policy{Custodian: nickname, Weight: weight}
+}
+
+// Create threshold node. Requires children with a combined weight of at least threshold
+func NewThresholdPolicy(threshold int, children ...Policy) Policy {
+	return Policy{Threshold: threshold, Children: children}
+}
+
+// Create node requiring all children
+func NewAndPolicy(children ...Policy) Policy {
+	p := Policy{Children: children}
+	p.Threshold = p.totalWeight()
+	return p
+}
+
+// Create node requiring any child
+func NewOrPolicy(children ...Policy) Policy {
+	return Policy{Threshold: 1, Children: children}
+}
+
+func (p Policy) IsLeaf() bool {
+	return len(p.Children) == 0
+}
+
+// Weight of node as seen from its parent. Internal nodes count as a single share
+func (p Policy) weight() int {
+	if p.IsLeaf() {
+		return p.Weight
+	}
+	return 1
+}
+
+// Sum of children weights
+func (p Policy) totalWeight() int {
+	w := 0
+	for _, child := range p.Children {
+		w += child.weight()
+	}
+	return w
+}
+
+// Check policy is well formed
+func (p Policy) Validate() error {
+	if p.IsLeaf() {
+		if p.Custodian == "" {
+			return errors.New("Policy : Custodian leaf without nickname")
+		}
+		if p.Weight <= 0 {
+			return errors.New("Policy : Custodian weight needs to be > 0")
+		}
+		return nil
+	}
+	if p.Threshold <= 0 || p.Threshold > p.totalWeight() {
+		return errors.New("Policy : Threshold needs to be > 0 and <= than combined children weight")
+	}
+	if p.totalWeight() > MULTI_MAX_SECRETS {
+		return errors.New("Policy : Too many shares in node")
+	}
+	for _, child := range p.Children {
+		if err := child.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List of custodians included in policy
+func (p Policy) Custodians() []string {
+	custodians := make([]string, 0)
+	p.walk(func(leaf Policy) {
+		custodians = append(custodians, leaf.Custodian)
+	})
+	return custodians
+}
+
+func (p Policy) walk(f func(leaf Policy)) {
+	if p.IsLeaf() {
+		f(p)
+		return
+	}
+	for _, child := range p.Children {
+		child.walk(f)
+	}
+}
+
+// Generate shares of secret according to policy. Returns the shares assigned to every custodian
+func (p Policy) GenerateShares(secret ff.Element, elType int) (map[string][]PolicyShare, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if p.IsLeaf() {
+		return nil, errors.New("Policy : Root needs to be a threshold node")
+	}
+	shares := make(map[string][]PolicyShare)
+	err := p.generateShares(secret, elType, []int{}, shares)
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func (p Policy) generateShares(secret ff.Element, elType int, path []int, out map[string][]PolicyShare) error {
+	cfg, err := NewConfig(p.Threshold, p.totalWeight(), elType)
+	if err != nil {
+		return err
+	}
+	shares, err := cfg.GenerateShares(secret)
+	if err != nil {
+		return err
+	}
+
+	startIdx := 0
+	for childIdx, child := range p.Children {
+		childShares := shares[startIdx : startIdx+child.weight()]
+		startIdx += child.weight()
+
+		if child.IsLeaf() {
+			for _, share := range childShares {
+				out[child.Custodian] = append(out[child.Custodian], PolicyShare{
+					Path:  clonePath(path),
+					Share: share,
+				})
+			}
+			continue
+		}
+		err = child.generateShares(childShares[0].Py, elType, append(clonePath(path), childIdx), out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Generate secret from the shares collected from custodians
+func (p Policy) GenerateSecret(shares []PolicyShare, elType int) (ff.Element, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if p.IsLeaf() {
+		return nil, errors.New("Policy : Root needs to be a threshold node")
+	}
+	return p.generateSecret(shares, elType, []int{})
+}
+
+func (p Policy) generateSecret(shares []PolicyShare, elType int, path []int) (ff.Element, error) {
+	cfg, err := NewConfig(p.Threshold, p.totalWeight(), elType)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := make([]Share, 0)
+	addShare := func(share Share) {
+		for _, prev := range pool {
+			if prev.Px.Equal(share.Px) {
+				return
+			}
+		}
+		pool = append(pool, share)
+	}
+
+	// shares generated by this node
+	for _, share := range shares {
+		if equalPath(share.Path, path) {
+			addShare(share.Share)
+		}
+	}
+
+	// shares recovered from internal children
+	startIdx := 0
+	for childIdx, child := range p.Children {
+		px, _ := ff.NewElement(elType)
+		px.SetUint64(uint64(startIdx + 1))
+		startIdx += child.weight()
+		if child.IsLeaf() {
+			continue
+		}
+		py, err := child.generateSecret(shares, elType, append(clonePath(path), childIdx))
+		if err != nil {
+			continue
+		}
+		recovered := Share{Px: px, Py: py}
+		if len(pool) > 0 {
+			// recovered share belongs to the sharing set of this node
+			recovered.SetId, recovered.MinShares, recovered.MaxShares = pool[0].SetId, pool[0].MinShares, pool[0].MaxShares
+		}
+		addShare(recovered)
+	}
+
+	if len(pool) < p.Threshold {
+		return nil, errors.New("Policy : Not enough shares to satisfy policy")
+	}
+	return cfg.GenerateSecret(pool[:p.Threshold])
+}
+
+// Returns true if custodians are enough to recover secret
+func (p Policy) IsSatisfied(custodians []string) bool {
+	return p.reachable(toSet(custodians))
+}
+
+func (p Policy) reachable(available map[string]bool) bool {
+	if p.IsLeaf() {
+		return available[p.Custodian]
+	}
+	w := 0
+	for _, child := range p.Children {
+		if child.reachable(available) {
+			w += child.weight()
+		}
+	}
+	return w >= p.Threshold
+}
+
+// Returns a minimal list of custodians that still need to be contacted to satisfy
+// policy, given the custodians whose shares are already available. Custodians appearing
+// in several branches are counted once per branch.
+func (p Policy) Needed(custodians []string) []string {
+	needed := p.needed(toSet(custodians))
+	sort.Strings(needed)
+	return needed
+}
+
+func (p Policy) needed(available map[string]bool) []string {
+	if p.IsLeaf() {
+		if available[p.Custodian] {
+			return []string{}
+		}
+		return []string{p.Custodian}
+	}
+
+	// 0/1 knapsack over children : best[w] is the cheapest set of custodians
+	// reaching a combined weight of at least w (capped at Threshold)
+	best := make([][]string, p.Threshold+1)
+	best[0] = []string{}
+	for _, child := range p.Children {
+		childNeeded := child.needed(available)
+		if childNeeded == nil {
+			continue
+		}
+		next := make([][]string, len(best))
+		copy(next, best)
+		for w, set := range best {
+			if set == nil {
+				continue
+			}
+			nw := w + child.weight()
+			if nw > p.Threshold {
+				nw = p.Threshold
+			}
+			candidate := mergeNeeded(set, childNeeded)
+			if next[nw] == nil || len(candidate) < len(next[nw]) {
+				next[nw] = candidate
+			}
+		}
+		best = next
+	}
+	return best[p.Threshold]
+}
+
+func mergeNeeded(a, b []string) []string {
+	r := make([]string, 0, len(a)+len(b))
+	r = append(r, a...)
+	for _, el := range b {
+		found := false
+		for _, prev := range a {
+			if prev == el {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r = append(r, el)
+		}
+	}
+	return r
+}
+
+func toSet(custodians []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, custodian := range custodians {
+		set[custodian] = true
+	}
+	return set
+}
+
+func clonePath(path []int) []int {
+	r := make([]int, len(path))
+	copy(r, path)
+	return r
+}
+
+func equalPath(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy share layout
+//   Path length [1 Byte]
+//   Path        [Path length * 2 Bytes]
+//   Share       [SHARE_SIZE Bytes]
+func (s PolicyShare) Marshal(p int) []byte {
+	b := make([]byte, 1+2*len(s.Path))
+	b[0] = byte(len(s.Path))
+	for idx, childIdx := range s.Path {
+		binary.LittleEndian.PutUint16(b[1+2*idx:3+2*idx], uint16(childIdx))
+	}
+	return append(b, s.Share.Marshal(p)...)
+}
+
+func (s *PolicyShare) Unmarshal(b []byte) (*PolicyShare, error) {
+	if len(b) < 1 || len(b) < 1+2*int(b[0]) {
+		return nil, errors.New("Invalid policy share length")
+	}
+	pathLen := int(b[0])
+	s.Path = make([]int, pathLen)
+	for idx := range s.Path {
+		s.Path[idx] = int(binary.LittleEndian.Uint16(b[1+2*idx : 3+2*idx]))
+	}
+	share := &Share{}
+	share, err := share.Unmarshal(b[1+2*pathLen:])
+	if err != nil {
+		return nil, err
+	}
+	s.Share = *share
+	return s, nil
+}
@@ -1,19 +1,33 @@
 package shamir
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"github.com/iden3/go-backup/ff"
-	"math"
+	"github.com/iden3/go-backup/ff/poly"
+	"io"
 )
 
 // Define shamir configuration:
 // MinShares   -> minimum number of shares to generate secret
 // MaxShares   -> maximum number of shares distributed
-// ElementType -> defines prime
+// ElementType -> defines prime (or FF_GF256 for byte-wise sharing)
+// RandomPx    -> shares x-coordinates are random distinct nonzero elements instead of 1..MaxShares
+// Randomness is read from crypto/rand unless a different source is set with SetRandomSource
 type Shamir struct {
 	MinShares   int
 	MaxShares   int
 	ElementType int
+	RandomPx    bool
+	random      io.Reader
+}
+
+// Secret sharing scheme identifier
+const SCHEME_ID = "shamir"
+
+func (s Shamir) Scheme() string {
+	return SCHEME_ID
 }
 
 func (s Shamir) GetMinShares() int {
@@ -28,76 +42,251 @@ func (s Shamir) GetElType() int {
 
 // Generate secret from shares S[0],...,S[N-1], where S[i] = (sx[i], sy[i]) = (x, poly(x))
 // secret = Sum_fromj=0_to_N-1   sy[j]   *    Prod_from_m=0,m!=j_to_m=N-1 ( sx[m] / (sx[m] - sx[j]))
-//  sx[i] is an integer, sy[i] is a FF in Montgomery
+//  sx[i] and sy[i] are FF in Montgomery. Differences are computed in field arithmetic, so sx[i] can be
+//  any nonzero element. To reconstruct many secrets from the same x-coordinates, use LagrangeBasis
+//  At least MinShares shares are needed
 func (s Shamir) GenerateSecret(shares []Share) (ff.Element, error) {
-	secret, _ := ff.NewElement(s.ElementType)
-	nFF, _ := ff.NewElement(s.ElementType)
-	dFF, _ := ff.NewElement(s.ElementType)
-	lFF, _ := ff.NewElement(s.ElementType)
-	var xm, xj int
-
-	for _, share1 := range shares {
-		jidx := share1.Px
-		lFF.SetOne()
-		for _, share2 := range shares {
-			midx := share2.Px
-			if midx == jidx {
-				continue
-			}
-			xm = midx
-			xj = jidx
-			nFF.SetUint64(uint64(xm))
-			if xm > xj {
-				dFF.SetUint64(uint64(xm - xj))
-			} else {
-				dFF.SetUint64(uint64(xj - xm))
-				dFF.Neg(dFF)
-			}
-			dFF.Inverse(dFF)
-			nFF.MulAssign(dFF)
-			lFF.MulAssign(nFF)
-		}
-		lFF.MulAssign(share1.Py)
-		secret.AddAssign(lFF)
+	if err := s.checkPrimeField(); err != nil {
+		return nil, err
+	}
+	if err := s.checkThreshold(len(shares)); err != nil {
+		return nil, err
+	}
+	if err := checkShares(shares, s.MinShares, s.MaxShares); err != nil {
+		return nil, err
+	}
+	basis, err := s.NewLagrangeBasis(shares)
+	if err != nil {
+		return nil, err
 	}
+	return basis.GenerateSecret(shares)
+}
 
-	return secret, nil
+// Check share can be used to recover a secret with this configuration
+func (s Shamir) VerifyShare(share Share) error {
+	if err := s.checkPrimeField(); err != nil {
+		return err
+	}
+	if share.Py == nil {
+		return errors.New("Shamir's Secret : Invalid share y-coordinate")
+	}
+	return checkShares([]Share{share}, s.MinShares, s.MaxShares)
 }
 
 // Generate shares in Montgomery
 // for a given poly p(x), generate N shares (N=MaxShares) s[1], s[1],...,s[N]
-// such that s[i] = p(i) for  0 < i < N and  s[0] = secret (s[0] is not a share) is in Regular fmt
+// such that s[i] = p(x[i]) for  0 < i < N and  s[0] = secret (s[0] is not a share) is in Regular fmt
+// x[i] = i, or a random distinct nonzero element if RandomPx is set
+// p(x[i]) is evaluated in field arithmetic (Horner's rule), so shares are exact for any N and MinShares
 func (s Shamir) GenerateShares(secret ff.Element) ([]Share, error) {
+	if err := s.checkPrimeField(); err != nil {
+		return nil, err
+	}
 
 	shares := make([]Share, 0)
-	val := 0.0
+
+	// random identifier of this sharing set
+	var setId [SETID_SIZE]byte
+	if _, err := io.ReadFull(s.randomSource(), setId[:]); err != nil {
+		return nil, err
+	}
 
 	//initialize Poly. Coefficients are in Montgomery
-	poly := s.generatePoly()
+	poly, err := s.generatePoly(secret)
+	if err != nil {
+		return nil, err
+	}
+	pxs, err := s.generatePx()
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate all shares
-	for idx := 1; idx <= s.MaxShares; idx++ {
-		tmpFF, _ := ff.NewElement(s.ElementType)
-
-		// FF Element storing share y-coordinate
-		py, _ := ff.NewElement(s.ElementType)
+	for _, px := range pxs {
 		newShare := Share{
-			Px: idx,
-			Py: py.Set(secret),
+			Px:        px,
+			Py:        poly.Eval(px),
+			SetId:     setId,
+			MinShares: s.MinShares,
+			MaxShares: s.MaxShares,
 		}
+		shares = append(shares, newShare)
+	}
+
+	return shares, nil
+}
 
-		for coeffIdx, coeff := range poly {
-			val = math.Pow(float64(idx), float64(coeffIdx+1))
-			tmpFF.SetUint64(uint64(val))
-			tmpFF.MulAssign(coeff)
-			newShare.Py.AddAssign(tmpFF)
+// Generate MaxShares x-coordinates. Sequential 1..MaxShares or random distinct nonzero elements
+func (s Shamir) generatePx() ([]ff.Element, error) {
+	px := make([]ff.Element, 0, s.MaxShares)
+	for len(px) < s.MaxShares {
+		x, _ := ff.NewElement(s.ElementType)
+		if !s.RandomPx {
+			px = append(px, x.SetUint64(uint64(len(px)+1)))
+			continue
+		}
+		if _, err := x.SetRandomFrom(s.randomSource()); err != nil {
+			return nil, err
+		}
+		if x.IsZero() || containsElement(px, x) {
+			continue
+		}
+		px = append(px, x)
+	}
+	return px, nil
+}
+
+// Element sharing is only defined over prime fields. FF_GF256 configurations share bytes
+// with GenerateByteShares and GenerateMnemonicShares
+func (s Shamir) checkPrimeField() error {
+	if s.ElementType == FF_GF256 {
+		return errors.New("Shamir's Secret : FF_GF256 configuration only supports byte sharing. Use GenerateByteShares")
+	}
+	return nil
+}
+
+// Fewer than MinShares shares interpolate a different secret
+func (s Shamir) checkThreshold(nShares int) error {
+	if nShares < s.MinShares {
+		return errors.New("Shamir's Secret : Not enough shares to recover secret")
+	}
+	return nil
+}
+
+func containsElement(list []ff.Element, x ff.Element) bool {
+	for _, el := range list {
+		if el.Equal(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate shares of an arbitrary length secret. Every byte of the secret is shared independently
+// over GF(2^8) using the same x-coordinates, so share i is (i, p_0(i) || p_1(i) || ... || p_L-1(i)).
+// If withLen is true, secret is prefixed with its length and padded to a multiple of BYTE_SHARE_PAD
+// bytes before being split, so that shares do not reveal its exact size.
+// Configuration needs to be initialized with FF_GF256 element type
+func (s Shamir) GenerateByteShares(secret []byte, withLen bool) ([]ByteShare, error) {
+	if err := s.checkByteConfig(); err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	data := secret
+	if withLen {
+		flags |= BYTE_SHARE_F_LEN
+		data = padSecret(secret)
+	}
+
+	shares := make([]ByteShare, s.MaxShares)
+	for idx := range shares {
+		shares[idx] = ByteShare{
+			Px:    byte(idx + 1),
+			Py:    make([]byte, len(data)),
+			Flags: flags,
+		}
+	}
+
+	// one random poly of degree MinShares-1 per secret byte. Coefficient 0 is the secret byte
+	poly := make([]byte, s.MinShares)
+	for bIdx, b := range data {
+		if _, err := io.ReadFull(s.randomSource(), poly[1:]); err != nil {
+			return nil, err
+		}
+		poly[0] = b
+		for idx := range shares {
+			shares[idx].Py[bIdx] = gf256Eval(poly, shares[idx].Px)
 		}
-		shares = append(shares, newShare)
 	}
 
 	return shares, nil
 }
 
+// Generate secret from byte shares S[0],...,S[N-1]. At least MinShares shares are needed. All shares
+// need to have the same length and flags, and different x-coordinates
+func (s Shamir) GenerateByteSecret(shares []ByteShare) ([]byte, error) {
+	if err := s.checkByteConfig(); err != nil {
+		return nil, err
+	}
+	if len(shares) == 0 {
+		return nil, errors.New("Shamir's Secret : No shares provided")
+	}
+	if err := s.checkThreshold(len(shares)); err != nil {
+		return nil, err
+	}
+	secretLen := len(shares[0].Py)
+	flags := shares[0].Flags
+
+	px := make([]byte, len(shares))
+	py := make([]byte, len(shares))
+	for idx, share := range shares {
+		if share.Px == 0 {
+			return nil, errors.New("Shamir's Secret : Invalid share x-coordinate")
+		}
+		if len(share.Py) != secretLen || share.Flags != flags {
+			return nil, errors.New("Shamir's Secret : Inconsistent share format")
+		}
+		for _, prev := range px[:idx] {
+			if prev == share.Px {
+				return nil, errors.New("Shamir's Secret : Duplicated share")
+			}
+		}
+		px[idx] = share.Px
+	}
+
+	secret := make([]byte, secretLen)
+	for bIdx := range secret {
+		for idx, share := range shares {
+			py[idx] = share.Py[bIdx]
+		}
+		secret[bIdx] = gf256InterpolateZero(px, py)
+	}
+
+	if flags&BYTE_SHARE_F_LEN != 0 {
+		return unpadSecret(secret)
+	}
+	return secret, nil
+}
+
+// Check byte sharing configuration. Configurations not built with NewConfig are not validated
+func (s Shamir) checkByteConfig() error {
+	if s.ElementType != FF_GF256 {
+		return errors.New("Shamir's Secret : Byte sharing requires FF_GF256 element type")
+	}
+	if s.MinShares <= 0 || s.MinShares > s.MaxShares {
+		return errors.New("Shamir's Secret : Minimum shares needs to be > 0 and <= than Maximum shares")
+	}
+	if s.MaxShares > GF256_ORDER {
+		return errors.New("Shamir's Secret : Maximum shares in GF(2^8) needs to be <= 255")
+	}
+	return nil
+}
+
+// Prefix secret with its length (4 bytes big endian) and zero pad to a multiple of BYTE_SHARE_PAD
+func padSecret(secret []byte) []byte {
+	dataLen := BYTE_SHARE_LEN_SIZE + len(secret)
+	if dataLen%BYTE_SHARE_PAD != 0 {
+		dataLen += BYTE_SHARE_PAD - dataLen%BYTE_SHARE_PAD
+	}
+	data := make([]byte, dataLen)
+	binary.BigEndian.PutUint32(data[:BYTE_SHARE_LEN_SIZE], uint32(len(secret)))
+	copy(data[BYTE_SHARE_LEN_SIZE:], secret)
+	return data
+}
+
+// Remove length prefix and padding added by padSecret
+func unpadSecret(data []byte) ([]byte, error) {
+	if len(data) < BYTE_SHARE_LEN_SIZE {
+		return nil, errors.New("Shamir's Secret : Invalid secret length metadata")
+	}
+	secretLen := int(binary.BigEndian.Uint32(data[:BYTE_SHARE_LEN_SIZE]))
+	if secretLen > len(data)-BYTE_SHARE_LEN_SIZE {
+		return nil, errors.New("Shamir's Secret : Invalid secret length metadata")
+	}
+	return data[BYTE_SHARE_LEN_SIZE : BYTE_SHARE_LEN_SIZE+secretLen], nil
+}
+
 // Initialize Shamir's secret sharing configuration
 func NewConfig(minShares, maxShares, elementType int) (*Shamir, error) {
 	var err error
@@ -112,9 +301,18 @@ func NewConfig(minShares, maxShares, elementType int) (*Shamir, error) {
 		err = errors.New("Shamir's Secret Config : Minimum shares needs to be <= than Maximum shares")
 		return nil, err
 	}
-	if ff.IsValid(elementType) == false {
+	if elementType == FF_GF256 {
+		if maxShares > GF256_ORDER {
+			err = errors.New("Shamir's Secret Config : Maximum shares in GF(2^8) needs to be <= 255")
+			return nil, err
+		}
+	} else if ff.IsValid(elementType) == false {
 		err = errors.New("Shamir's Secret Config : Finite Field unknown")
 		return nil, err
+	} else if el, _ := ff.NewElement(elementType); len(el.ToBytesLE()) > ELEMENT_SIZE {
+		// shares store elements in ELEMENT_SIZE bytes
+		err = errors.New("Shamir's Secret Config : Finite Field elements larger than 256 bits not supported")
+		return nil, err
 	}
 
 	cfg.MinShares = minShares
@@ -124,22 +322,34 @@ func NewConfig(minShares, maxShares, elementType int) (*Shamir, error) {
 	return &cfg, nil
 }
 
-// Generate new secret
+// Set source of randomness used to generate secrets, polynomials, x-coordinates and set identifiers.
+// A deterministic source (for example ff.NewSeededReader) makes sharings reproducible, so its seed needs
+// to be protected as the secret itself. nil restores crypto/rand
+func (s *Shamir) SetRandomSource(r io.Reader) {
+	s.random = r
+}
+
+func (s Shamir) randomSource() io.Reader {
+	if s.random == nil {
+		return rand.Reader
+	}
+	return s.random
+}
+
+// Generate new secret. Returns nil if randomness source fails or configuration uses FF_GF256
 func (s Shamir) NewSecret() ff.Element {
+	if s.checkPrimeField() != nil {
+		return nil
+	}
 	secret, _ := ff.NewElement(s.ElementType)
-	secret.SetRandom().ToMont()
+	if _, err := secret.SetRandomFrom(s.randomSource()); err != nil {
+		return nil
+	}
 	return secret
 }
 
-// Generate random coefficients a[1]...a[MinShares-1] in Montgomery belonging to Finite Field
+// Generate random polynomial of degree MinShares-1 in Montgomery belonging to Finite Field
 // f(x) = secret + a[1] * x + a[2] * x^2 + ... + a[MinShares-1] * x^(MinShares-1)
-// secret not included in poly
-func (s Shamir) generatePoly() []ff.Element {
-	poly := make([]ff.Element, s.MinShares-1)
-	for coeffIdx := range poly {
-		poly[coeffIdx], _ = ff.NewElement(s.ElementType)
-		poly[coeffIdx].SetRandom().ToMont()
-	}
-
-	return poly
+func (s Shamir) generatePoly(secret ff.Element) (*poly.Polynomial, error) {
+	return poly.NewRandom(s.randomSource(), s.MinShares-1, secret, s.ElementType)
 }
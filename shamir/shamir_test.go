@@ -1,6 +1,12 @@
 package shamir
 
 import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
 	"math/rand"
 	"reflect"
 	"testing"
@@ -72,11 +78,8 @@ func TestShamirKO(t *testing.T) {
 
 		// generate key
 		newSecret, err3 := cfg.GenerateSecret(selectedShares)
-		if err3 != nil {
-			t.Error(err3)
-		}
-		if secret.Equal(newSecret) {
-			t.Error("Secrets are equal")
+		if err3 == nil || newSecret != nil {
+			t.Error("Not enough shares accepted")
 		}
 	}
 }
@@ -90,7 +93,7 @@ func shuffleShares(pool []Share, n int) []Share {
 			found = false
 			newIdx := rand.Intn(nshares)
 			for _, share := range selected {
-				if share.Px == pool[newIdx].Px {
+				if share.Px.Equal(pool[newIdx].Px) {
 					found = true
 					continue
 				}
@@ -102,3 +105,951 @@ func shuffleShares(pool []Share, n int) []Share {
 	}
 	return selected
 }
+
+func TestShamirGF256OK(t *testing.T) {
+	// Generate Shamir config
+	var minShares, maxShares = 3, 6
+	cfg, err := NewConfig(minShares, maxShares, FF_GF256)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, secretLen := range []int{1, 16, 32, 64, 1000} {
+		for _, withLen := range []bool{false, true} {
+			// Secret
+			secret := make([]byte, secretLen)
+			crand.Read(secret)
+
+			// Generate Shares
+			shares, err2 := cfg.GenerateByteShares(secret, withLen)
+			if err2 != nil {
+				t.Error(err2)
+			}
+			if len(shares) != maxShares {
+				t.Error("Unexpected number of shares")
+			}
+			// Marshal/Unmarshal shares
+			for _, share := range shares {
+				shareRec := &ByteShare{}
+				shareRec, err = shareRec.Unmarshal(share.Marshal())
+				if err != nil || !reflect.DeepEqual(*shareRec, share) {
+					t.Error("Error in Marshall/Unmarshal")
+				}
+			}
+
+			// select shares to regenerate secret
+			for iter := 0; iter < 10; iter++ {
+				selectedShares := shuffleByteShares(shares, minShares)
+
+				newSecret, err3 := cfg.GenerateByteSecret(selectedShares)
+				if err3 != nil {
+					t.Error(err3)
+				}
+				if !bytes.Equal(secret, newSecret) {
+					t.Error("Secrets not equal")
+				}
+			}
+		}
+	}
+}
+
+func TestShamirGF256KO(t *testing.T) {
+	// Generate Shamir config
+	var minShares, maxShares = 3, 6
+	cfg, err := NewConfig(minShares, maxShares, FF_GF256)
+	if err != nil {
+		t.Error(err)
+	}
+
+	secret := make([]byte, 32)
+	crand.Read(secret)
+	shares, err := cfg.GenerateByteShares(secret, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// select insufficient shares to regenerate secret
+	for iter := 0; iter < 10; iter++ {
+		selectedShares := shuffleByteShares(shares, minShares-1)
+		_, err := cfg.GenerateByteSecret(selectedShares)
+		if err == nil {
+			t.Error("Not enough shares accepted")
+		}
+	}
+
+	// duplicated and inconsistent shares
+	_, err = cfg.GenerateByteSecret([]ByteShare{shares[0], shares[1], shares[0]})
+	if err == nil {
+		t.Error("Duplicated shares accepted")
+	}
+	shares[1].Py = shares[1].Py[1:]
+	_, err = cfg.GenerateByteSecret(shares[:minShares])
+	if err == nil {
+		t.Error("Inconsistent shares accepted")
+	}
+
+	// invalid configurations
+	_, err = NewConfig(3, 256, FF_GF256)
+	if err == nil {
+		t.Error("Too many shares accepted")
+	}
+	for _, invalid := range []Shamir{{MaxShares: maxShares, ElementType: FF_GF256}, {MinShares: 3, MaxShares: 2, ElementType: FF_GF256}} {
+		if _, err = invalid.GenerateByteShares(secret, false); err == nil {
+			t.Error("Invalid configuration accepted", invalid)
+		}
+		if _, err = invalid.GenerateByteSecret(shares); err == nil {
+			t.Error("Invalid configuration accepted", invalid)
+		}
+	}
+
+	// element sharing over GF(2^8)
+	cfg, _ = NewConfig(minShares, maxShares, FF_GF256)
+	if cfg.NewSecret() != nil {
+		t.Error("Element secret generated in GF(2^8)")
+	}
+	el, _ := ff.NewElement(ff.FF_BN256_FP)
+	_, err = cfg.GenerateShares(el.SetUint64(1))
+	if err == nil {
+		t.Error("Element sharing accepted GF(2^8)")
+	}
+	_, err = cfg.GenerateMultiShares([]ff.Element{el}, MULTI_INDEPENDENT)
+	if err == nil {
+		t.Error("Multi-element sharing accepted GF(2^8)")
+	}
+	_, err = cfg.GenerateSecret([]Share{{Px: el, Py: el}})
+	if err == nil {
+		t.Error("Element secret accepted GF(2^8)")
+	}
+
+	cfg, _ = NewConfig(minShares, maxShares, ff.FF_BN256_FP)
+	_, err = cfg.GenerateByteShares(secret, false)
+	if err == nil {
+		t.Error("Byte sharing accepted prime field")
+	}
+}
+
+func shuffleByteShares(pool []ByteShare, n int) []ByteShare {
+	selected := make([]ByteShare, 0)
+	for _, idx := range rand.Perm(len(pool))[:n] {
+		selected = append(selected, pool[idx])
+	}
+	return selected
+}
+
+func TestGF256Arith(t *testing.T) {
+	// FIPS-197 example
+	if gf256Mul(0x57, 0x83) != 0xc1 {
+		t.Error("Unexpected GF(2^8) product")
+	}
+	for a := 1; a < 256; a++ {
+		if gf256Mul(gf256Div(1, byte(a)), byte(a)) != 1 {
+			t.Error("Unexpected GF(2^8) inverse")
+		}
+	}
+}
+
+func TestShamirMultiOK(t *testing.T) {
+	// Generate Shamir config
+	var minShares, maxShares, prime = 4, 7, ff.FF_BN256_FP
+	cfg, err := NewConfig(minShares, maxShares, prime)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, mode := range []int{MULTI_INDEPENDENT, MULTI_PACKED} {
+		for nSecrets := 1; nSecrets < minShares; nSecrets++ {
+			// Secret
+			secret := make([]ff.Element, nSecrets)
+			for idx := range secret {
+				secret[idx] = cfg.NewSecret()
+			}
+
+			// Generate Shares
+			shares, err2 := cfg.GenerateMultiShares(secret, mode)
+			if err2 != nil {
+				t.Error(err2)
+			}
+			// Marshal/Unmarshal shares
+			for _, share := range shares {
+				shareRec := &MultiShare{}
+				shareRec, err = shareRec.Unmarshal(share.Marshal(prime))
+				if err != nil || !reflect.DeepEqual(*shareRec, share) {
+					t.Error("Error in Marshall/Unmarshal")
+				}
+				if mode == MULTI_PACKED && len(share.Py) != 1 {
+					t.Error("Packed share is not compact")
+				}
+			}
+
+			// select shares to regenerate secret
+			for iter := 0; iter < 10; iter++ {
+				selectedShares := shuffleMultiShares(shares, minShares)
+
+				newSecret, err3 := cfg.GenerateMultiSecret(selectedShares)
+				if err3 != nil {
+					t.Error(err3)
+				}
+				if len(newSecret) != nSecrets {
+					t.Fatal("Unexpected secret length")
+				}
+				for idx := range secret {
+					if !secret[idx].Equal(newSecret[idx]) {
+						t.Error("Secrets not equal")
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestShamirMultiKO(t *testing.T) {
+	// Generate Shamir config
+	var minShares, maxShares, prime = 4, 7, ff.FF_BN256_FQ
+	cfg, err := NewConfig(minShares, maxShares, prime)
+	if err != nil {
+		t.Error(err)
+	}
+
+	secret := []ff.Element{cfg.NewSecret(), cfg.NewSecret()}
+	for _, mode := range []int{MULTI_INDEPENDENT, MULTI_PACKED} {
+		shares, err := cfg.GenerateMultiShares(secret, mode)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// select insufficient shares to regenerate secret
+		for iter := 0; iter < 10; iter++ {
+			selectedShares := shuffleMultiShares(shares, minShares-1)
+			_, err := cfg.GenerateMultiSecret(selectedShares)
+			if err == nil {
+				t.Error("Not enough shares accepted", mode)
+			}
+		}
+
+		// duplicated shares
+		_, err = cfg.GenerateMultiSecret([]MultiShare{shares[0], shares[1], shares[2], shares[0]})
+		if err == nil {
+			t.Error("Duplicated shares accepted")
+		}
+	}
+
+	// too many secrets for packed sharing
+	secret = []ff.Element{cfg.NewSecret(), cfg.NewSecret(), cfg.NewSecret(), cfg.NewSecret()}
+	_, err = cfg.GenerateMultiShares(secret, MULTI_PACKED)
+	if err == nil {
+		t.Error("Packed sharing without randomness accepted")
+	}
+}
+
+func shuffleMultiShares(pool []MultiShare, n int) []MultiShare {
+	selected := make([]MultiShare, 0)
+	for _, idx := range rand.Perm(len(pool))[:n] {
+		selected = append(selected, pool[idx])
+	}
+	return selected
+}
+
+func TestPolicyOK(t *testing.T) {
+	var prime = ff.FF_BN256_FP
+	cfg, _ := NewConfig(1, 1, prime)
+	secret := cfg.NewSecret()
+
+	// 2 out of : [1 family member AND 2 of 3 friends] (weight 1), Notary (weight 2), Raul (weight 1)
+	family := NewOrPolicy(NewCustodianPolicy("Mum", 1), NewCustodianPolicy("Dad", 1))
+	friends := NewThresholdPolicy(2,
+		NewCustodianPolicy("Pedrito", 1),
+		NewCustodianPolicy("Faustino", 1),
+		NewCustodianPolicy("Sergio", 1))
+	policy := NewThresholdPolicy(2,
+		NewAndPolicy(family, friends),
+		NewCustodianPolicy("Notary", 2),
+		NewCustodianPolicy("Raul", 1))
+
+	shares, err := policy.GenerateShares(secret, prime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares["Notary"]) != 2 {
+		t.Error("Unexpected number of weighted shares")
+	}
+
+	tests := []struct {
+		custodians []string
+		satisfied  bool
+		needed     int
+	}{
+		{[]string{"Notary"}, true, 0},
+		{[]string{"Dad", "Pedrito", "Sergio", "Raul"}, true, 0},
+		{[]string{"Dad", "Pedrito", "Sergio"}, false, 1},
+		{[]string{"Mum", "Dad", "Faustino"}, false, 1},
+		{[]string{"Pedrito", "Sergio", "Raul"}, false, 1},
+		{[]string{"Mum", "Faustino", "Raul"}, false, 1},
+		{[]string{}, false, 1},
+	}
+	for _, test := range tests {
+		if policy.IsSatisfied(test.custodians) != test.satisfied {
+			t.Error("Unexpected policy satisfaction", test.custodians)
+		}
+		needed := policy.Needed(test.custodians)
+		if len(needed) != test.needed {
+			t.Error("Unexpected needed custodians", test.custodians, needed)
+		}
+		if !policy.IsSatisfied(append(test.custodians, needed...)) {
+			t.Error("Needed custodians do not satisfy policy", test.custodians, needed)
+		}
+
+		pool := make([]PolicyShare, 0)
+		for _, custodian := range test.custodians {
+			for _, share := range shares[custodian] {
+				// Marshal/Unmarshal shares
+				shareRec := &PolicyShare{}
+				shareRec, err = shareRec.Unmarshal(share.Marshal(prime))
+				if err != nil || !reflect.DeepEqual(*shareRec, share) {
+					t.Error("Error in Marshall/Unmarshal")
+				}
+				pool = append(pool, *shareRec)
+			}
+		}
+		newSecret, err := policy.GenerateSecret(pool, prime)
+		if test.satisfied && (err != nil || !secret.Equal(newSecret)) {
+			t.Error("Secrets not equal", test.custodians)
+		}
+		if !test.satisfied && err == nil {
+			t.Error("Secret generated with unsatisfied policy", test.custodians)
+		}
+	}
+}
+
+func TestPolicyKO(t *testing.T) {
+	policies := []Policy{
+		NewThresholdPolicy(3, NewCustodianPolicy("Pedrito", 1), NewCustodianPolicy("Faustino", 1)),
+		NewThresholdPolicy(0, NewCustodianPolicy("Pedrito", 1)),
+		NewOrPolicy(NewCustodianPolicy("", 1)),
+		NewOrPolicy(NewCustodianPolicy("Pedrito", 0)),
+		NewCustodianPolicy("Pedrito", 1),
+	}
+	cfg, _ := NewConfig(1, 1, ff.FF_BN256_FP)
+	for _, policy := range policies {
+		_, err := policy.GenerateShares(cfg.NewSecret(), ff.FF_BN256_FP)
+		if err == nil {
+			t.Error("Invalid policy accepted", policy)
+		}
+	}
+}
+
+func TestSlip39Vectors(t *testing.T) {
+	// SLIP-0039 reference test vectors
+	tests := []struct {
+		mnemonics []string
+		secret    string
+	}{
+		// Valid mnemonic without sharing (128 bits)
+		{[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"},
+			"bb54aac4b89dc868ba37d9cc21b2cece"},
+		// Mnemonic with invalid checksum (128 bits)
+		{[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision kidney"},
+			""},
+		// Basic sharing 2-of-3 (128 bits)
+		{[]string{"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+			"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking"},
+			"b43ceb7e57a0ea8766221624d01b0864"},
+		// Basic sharing 2-of-3 (128 bits), insufficient shares
+		{[]string{"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed"},
+			""},
+	}
+
+	for _, test := range tests {
+		secret, err := CombineSlip39Shares(test.mnemonics, []byte("TREZOR"))
+		if test.secret == "" {
+			if err == nil {
+				t.Error("Invalid mnemonics accepted", test.mnemonics)
+			}
+			continue
+		}
+		if err != nil || hex.EncodeToString(secret) != test.secret {
+			t.Error("Unexpected secret", test.mnemonics, err)
+		}
+	}
+}
+
+func TestSlip39OK(t *testing.T) {
+	secret := make([]byte, 32)
+	crand.Read(secret)
+	passphrase := []byte("TREZOR")
+
+	// 2 out of 3 groups : 1 of 1, 2 of 3 and 3 of 5 members
+	groups := []Slip39Group{{1, 1}, {2, 3}, {3, 5}}
+	mnemonics, err := GenerateSlip39Shares(nil, secret, passphrase, 2, groups, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse/Encode mnemonics
+	for _, group := range mnemonics {
+		for _, mnemonic := range group {
+			share := &Slip39Share{}
+			share, err = share.ParseMnemonic(mnemonic)
+			if err != nil || share.Mnemonic() != mnemonic {
+				t.Error("Error in Mnemonic/ParseMnemonic")
+			}
+		}
+	}
+
+	selected := []string{mnemonics[0][0], mnemonics[2][4], mnemonics[2][0], mnemonics[2][2]}
+	newSecret, err := CombineSlip39Shares(selected, passphrase)
+	if err != nil || !bytes.Equal(secret, newSecret) {
+		t.Error("Secrets not equal", err)
+	}
+
+	// wrong passphrase decrypts to a different secret
+	newSecret, err = CombineSlip39Shares(selected, []byte("TREZOR2"))
+	if err != nil || bytes.Equal(secret, newSecret) {
+		t.Error("Secret recovered with wrong passphrase")
+	}
+
+	// Shamir configuration with a single group
+	cfg, _ := NewConfig(3, 5, FF_GF256)
+	members, err := cfg.GenerateMnemonicShares(secret[:16], nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSecret, err = cfg.GenerateMnemonicSecret([]string{members[3], members[1], members[4]}, nil)
+	if err != nil || !bytes.Equal(secret[:16], newSecret) {
+		t.Error("Secrets not equal", err)
+	}
+
+	// same random source generates same mnemonics
+	cfg.SetRandomSource(ff.NewSeededReader([]byte("slip39")))
+	members, _ = cfg.GenerateMnemonicShares(secret[:16], nil, 1)
+	cfg.SetRandomSource(ff.NewSeededReader([]byte("slip39")))
+	newMembers, _ := cfg.GenerateMnemonicShares(secret[:16], nil, 1)
+	if !reflect.DeepEqual(members, newMembers) {
+		t.Error("Mnemonics differ with same random source")
+	}
+}
+
+func TestSlip39KO(t *testing.T) {
+	secret := make([]byte, 16)
+	crand.Read(secret)
+	mnemonics, err := GenerateSlip39Shares(nil, secret, nil, 2, []Slip39Group{{2, 3}, {2, 3}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := [][]string{
+		// insufficient groups
+		{mnemonics[0][0], mnemonics[0][1]},
+		// insufficient members
+		{mnemonics[0][0], mnemonics[0][1], mnemonics[1][0]},
+		// duplicated members
+		{mnemonics[0][0], mnemonics[0][0], mnemonics[1][0], mnemonics[1][1]},
+		// mistyped word
+		{mnemonics[0][0], mnemonics[0][1], mnemonics[1][0], "academic " + mnemonics[1][1]},
+	}
+	for _, selected := range invalid {
+		_, err = CombineSlip39Shares(selected, nil)
+		if err == nil {
+			t.Error("Invalid mnemonics accepted", selected)
+		}
+	}
+
+	// invalid configurations
+	_, err = GenerateSlip39Shares(nil, secret[:15], nil, 1, []Slip39Group{{2, 3}}, 0)
+	if err == nil {
+		t.Error("Short secret accepted")
+	}
+	_, err = GenerateSlip39Shares(nil, secret, nil, 1, []Slip39Group{{1, 3}}, 0)
+	if err == nil {
+		t.Error("Member threshold 1 with multiple members accepted")
+	}
+	_, err = GenerateSlip39Shares(nil, secret, nil, 3, []Slip39Group{{2, 3}, {2, 3}}, 0)
+	if err == nil {
+		t.Error("Group threshold > groups accepted")
+	}
+}
+
+func TestShareEnvelope(t *testing.T) {
+	// Generate two sharings with the same config
+	var minShares, maxShares, prime = 3, 6, ff.FF_BN256_FP
+	cfg, _ := NewConfig(minShares, maxShares, prime)
+	secret := cfg.NewSecret()
+	shares1, _ := cfg.GenerateShares(secret)
+	shares2, _ := cfg.GenerateShares(secret)
+
+	if shares1[0].SetId == shares2[0].SetId || !shares1[0].HasSet() {
+		t.Error("Sharing sets not identified")
+	}
+
+	// corrupted share
+	b := shares1[0].Marshal(prime)
+	b[ENV_PY_OFFSET] ^= 0x01
+	_, err := (&Share{}).Unmarshal(b)
+	if err == nil {
+		t.Error("Corrupted share accepted")
+	}
+
+	// unknown version
+	b = shares1[0].Marshal(prime)
+	b[ENV_VERSION_OFFSET] = SHARE_ENV_VERSION_2 + 1
+	_, err = (&Share{}).Unmarshal(b)
+	if err == nil {
+		t.Error("Unknown version accepted")
+	}
+
+	// y-coordinate out of range (modulus)
+	b = shares1[0].Marshal(prime)
+	modulus, _ := new(big.Int).SetString("21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+	for idx, v := range modulus.Bytes() {
+		b[ENV_CHECKSUM_OFFSET-1-idx] = v
+	}
+	copy(b[ENV_CHECKSUM_OFFSET:], envelopeChecksum(b[:ENV_CHECKSUM_OFFSET]))
+	_, err = (&Share{}).Unmarshal(b)
+	if err == nil {
+		t.Error("Share with element out of range accepted")
+	}
+
+	// legacy share
+	b = make([]byte, LEGACY_SHARE_SIZE)
+	b[PX_OFFSET] = 2
+	copy(b[PY_OFFSET:FFTYPE_OFFSET], shares1[1].Py.ToByte())
+	b[FFTYPE_OFFSET] = byte(prime)
+	legacy, err := (&Share{}).Unmarshal(b)
+	if err != nil || legacy.HasSet() || !legacy.Px.Equal(shares1[1].Px) || !legacy.Py.Equal(shares1[1].Py) {
+		t.Error("Error in legacy Unmarshal")
+	}
+	_, err = cfg.GenerateSecret([]Share{shares1[0], *legacy, shares1[2]})
+	if err == nil {
+		t.Error("Legacy share mixed with sharing set accepted")
+	}
+	legacies := make([]Share, 0, minShares)
+	for _, share := range shares1[:minShares] {
+		legacies = append(legacies, Share{Px: share.Px, Py: share.Py})
+	}
+	legacies[1] = *legacy
+	newSecret, err := cfg.GenerateSecret(legacies)
+	if err != nil || !secret.Equal(newSecret) {
+		t.Error("Secrets not equal")
+	}
+
+	// version 1 share (8 Byte Px)
+	b = make([]byte, SHARE_V1_SIZE)
+	v2 := shares1[2].Marshal(prime)
+	copy(b, v2[:ENV_PX_OFFSET])
+	b[ENV_VERSION_OFFSET] = SHARE_ENV_VERSION_1
+	b[ENV_PX_OFFSET] = 3
+	copy(b[ENV_V1_PY_OFFSET:ENV_V1_CHECKSUM_OFFSET], shares1[2].Py.ToByte())
+	copy(b[ENV_V1_CHECKSUM_OFFSET:], envelopeChecksum(b[:ENV_V1_CHECKSUM_OFFSET]))
+	v1, err := (&Share{}).Unmarshal(b)
+	if err != nil || !v1.Px.Equal(shares1[2].Px) || !v1.Py.Equal(shares1[2].Py) {
+		t.Error("Error in version 1 Unmarshal")
+	}
+	b[ENV_PX_OFFSET] = byte(maxShares + 1)
+	copy(b[ENV_V1_CHECKSUM_OFFSET:], envelopeChecksum(b[:ENV_V1_CHECKSUM_OFFSET]))
+	_, err = (&Share{}).Unmarshal(b)
+	if err == nil {
+		t.Error("Version 1 share with Px out of range accepted")
+	}
+
+	// mixed sharing sets
+	_, err = cfg.GenerateSecret([]Share{shares1[0], shares1[1], shares2[2]})
+	if err == nil {
+		t.Error("Shares from different sets accepted")
+	}
+
+	// duplicated shares
+	_, err = cfg.GenerateSecret([]Share{shares1[0], shares1[1], shares1[1]})
+	if err == nil {
+		t.Error("Duplicated shares accepted")
+	}
+
+	// mismatched configuration
+	cfg2, _ := NewConfig(minShares+1, maxShares, prime)
+	_, err = cfg2.GenerateSecret(shares1[:minShares+1])
+	if err == nil {
+		t.Error("Shares with different threshold accepted")
+	}
+}
+
+func TestShamirPolyEval(t *testing.T) {
+	var prime = ff.FF_BN256_FP
+	cfg, _ := NewConfig(16, 16, prime)
+	modulus, _ := new(big.Int).SetString("21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+
+	for iter := 0; iter < 10; iter++ {
+		secret := cfg.NewSecret()
+		poly, err := cfg.generatePoly(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := cfg.NewSecret()
+		if iter == 0 {
+			x.SetUint64(255)
+		}
+		if poly.Degree() != cfg.MinShares-1 || !poly.Coeffs[0].Equal(secret) {
+			t.Error("Unexpected polynomial")
+		}
+
+		// reference : secret + Sum poly[i] * x^i with big.Int
+		var bx, bSecret, bCoeff big.Int
+		x.ToBigIntRegular(&bx)
+		expected := new(big.Int).Set(secret.ToBigIntRegular(&bSecret))
+		xPow := big.NewInt(1)
+		for _, coeff := range poly.Coeffs[1:] {
+			xPow.Mul(xPow, &bx).Mod(xPow, modulus)
+			term := new(big.Int).Mul(coeff.ToBigIntRegular(&bCoeff), xPow)
+			expected.Add(expected, term).Mod(expected, modulus)
+		}
+
+		var obtained big.Int
+		poly.Eval(x).ToBigIntRegular(&obtained)
+		if obtained.Cmp(expected) != 0 {
+			t.Error("Polynomial evaluation not exact")
+		}
+	}
+}
+
+func TestShamirProperties(t *testing.T) {
+	pairs := [][2]int{{1, 1}, {1, 5}, {2, 2}, {2, 255}, {7, 20}, {16, 64}, {32, 255}, {100, 200}, {254, 255}, {255, 255}}
+	if !testing.Short() {
+		for iter := 0; iter < 20; iter++ {
+			n := 1 + rand.Intn(255)
+			pairs = append(pairs, [2]int{1 + rand.Intn(n), n})
+		}
+	}
+
+	for _, pair := range pairs {
+		minShares, maxShares := pair[0], pair[1]
+		for _, prime := range []int{ff.FF_BN256_FP, ff.FF_BN256_FQ, ff.FF_BLS12381_FR, ff.FF_SECP256K1_N, ff.FF_CURVE25519_L} {
+			cfg, err := NewConfig(minShares, maxShares, prime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret := cfg.NewSecret()
+			shares, err := cfg.GenerateShares(secret)
+			if err != nil || len(shares) != maxShares {
+				t.Fatal("Unexpected shares", minShares, maxShares, err)
+			}
+
+			// any minShares shares recover secret
+			newSecret, err := cfg.GenerateSecret(shuffleShares(shares, minShares))
+			if err != nil || !secret.Equal(newSecret) {
+				t.Error("Secrets not equal", minShares, maxShares)
+			}
+			// minShares-1 shares are rejected
+			if minShares > 1 {
+				_, err = cfg.GenerateSecret(shuffleShares(shares, minShares-1))
+				if err == nil {
+					t.Error("Not enough shares accepted", minShares, maxShares)
+				}
+			}
+		}
+	}
+}
+
+func TestShamirRandomPx(t *testing.T) {
+	var minShares, maxShares, prime = 4, 10, ff.FF_BN256_FQ
+	cfg, _ := NewConfig(minShares, maxShares, prime)
+	cfg.RandomPx = true
+	secret := cfg.NewSecret()
+	shares, err := cfg.GenerateShares(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// x-coordinates are nonzero, distinct and not sequential
+	sequential := true
+	for idx, share := range shares {
+		x, _ := ff.NewElement(prime)
+		x.SetUint64(uint64(idx + 1))
+		if !share.Px.Equal(x) {
+			sequential = false
+		}
+		if share.Px.IsZero() {
+			t.Error("Zero x-coordinate")
+		}
+	}
+	if sequential {
+		t.Error("Sequential x-coordinates")
+	}
+
+	// shares survive Marshal/Unmarshal
+	rxShares := make([]Share, 0)
+	for _, share := range shuffleShares(shares, minShares) {
+		rxShare, err := (&Share{}).Unmarshal(share.Marshal(prime))
+		if err != nil || !rxShare.Px.Equal(share.Px) || !rxShare.Py.Equal(share.Py) {
+			t.Fatal("Error in Unmarshal", err)
+		}
+		rxShares = append(rxShares, *rxShare)
+	}
+	newSecret, err := cfg.GenerateSecret(rxShares)
+	if err != nil || !secret.Equal(newSecret) {
+		t.Error("Secrets not equal")
+	}
+
+	_, err = cfg.GenerateSecret(rxShares[:minShares-1])
+	if err == nil {
+		t.Error("Not enough shares accepted")
+	}
+
+	// zero x-coordinate
+	rxShares[0].Px.SetZero()
+	_, err = cfg.GenerateSecret(rxShares)
+	if err == nil {
+		t.Error("Zero x-coordinate accepted")
+	}
+
+	// independent multi-element sharing ignores random x-coordinates
+	multiShares, err := cfg.GenerateMultiShares([]ff.Element{secret, cfg.NewSecret()}, MULTI_INDEPENDENT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMulti, err := cfg.GenerateMultiSecret(multiShares[:minShares])
+	if err != nil || !secret.Equal(newMulti[0]) {
+		t.Error("Secrets not equal")
+	}
+}
+
+func TestLagrangeBasis(t *testing.T) {
+	var minShares, maxShares, prime = 5, 12, ff.FF_BN256_FP
+	cfg, _ := NewConfig(minShares, maxShares, prime)
+
+	// many secrets shared with the same x-coordinates
+	nSecrets := 20
+	secrets := make([]ff.Element, nSecrets)
+	sets := make([][]Share, nSecrets)
+	for idx := range secrets {
+		secrets[idx] = cfg.NewSecret()
+		shares, _ := cfg.GenerateShares(secrets[idx])
+		sets[idx] = shares[2 : 2+minShares]
+	}
+	// one set with a different x-set
+	sets[nSecrets-1] = shuffleShares(sets[nSecrets-1], minShares)
+
+	newSecrets, err := cfg.GenerateSecrets(sets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := range secrets {
+		if !secrets[idx].Equal(newSecrets[idx]) {
+			t.Error("Secrets not equal", idx)
+		}
+	}
+
+	basis, err := cfg.NewLagrangeBasis(sets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = basis.GenerateSecret(sets[0][:minShares-1])
+	if err == nil {
+		t.Error("Shares not matching basis accepted")
+	}
+	_, err = basis.GenerateSecret(append([]Share{sets[0][1], sets[0][0]}, sets[0][2:]...))
+	if err == nil {
+		t.Error("Shares out of order accepted")
+	}
+	_, err = NewLagrangeBasis([]ff.Element{sets[0][0].Px, sets[0][1].Px, sets[0][0].Px}, prime)
+	if err == nil {
+		t.Error("Duplicated x-coordinates accepted")
+	}
+
+	// batch inversion
+	el := make([]ff.Element, 10)
+	for idx := range el {
+		el[idx] = cfg.NewSecret()
+	}
+	inv := make([]ff.Element, len(el))
+	for idx := range el {
+		inv[idx], _ = ff.NewElement(prime)
+		inv[idx].Set(el[idx])
+	}
+	ff.BatchInverse(inv)
+	for idx := range el {
+		if !inv[idx].MulAssign(el[idx]).Equal(el[idx].One()) {
+			t.Error("Batch inverse incorrect")
+		}
+	}
+
+	// reconstruction matches reference computation
+	secret, _ := cfg.GenerateSecret(sets[0])
+	if !secret.Equal(naiveGenerateSecret(sets[0], prime)) {
+		t.Error("Secret differs from reference reconstruction")
+	}
+}
+
+func benchmarkShares(b *testing.B, minShares int) (*Shamir, []Share) {
+	cfg, _ := NewConfig(minShares, minShares, ff.FF_BN256_FP)
+	shares, err := cfg.GenerateShares(cfg.NewSecret())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return cfg, shares
+}
+
+func BenchmarkGenerateSecret(b *testing.B) {
+	cfg, shares := benchmarkShares(b, 16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg.GenerateSecret(shares)
+	}
+}
+
+// Reference reconstruction inverting every denominator independently
+func naiveGenerateSecret(shares []Share, elType int) ff.Element {
+	secret, _ := ff.NewElement(elType)
+	lFF, _ := ff.NewElement(elType)
+	dFF, _ := ff.NewElement(elType)
+	secret.SetZero()
+	for j := range shares {
+		lFF.Set(shares[j].Py)
+		for m := range shares {
+			if m == j {
+				continue
+			}
+			dFF.Sub(shares[m].Px, shares[j].Px)
+			dFF.Inverse(dFF)
+			dFF.MulAssign(shares[m].Px)
+			lFF.MulAssign(dFF)
+		}
+		secret.AddAssign(lFF)
+	}
+	return secret
+}
+
+func BenchmarkGenerateSecretNaive(b *testing.B) {
+	cfg, shares := benchmarkShares(b, 16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		naiveGenerateSecret(shares, cfg.ElementType)
+	}
+}
+
+func BenchmarkLagrangeBasis(b *testing.B) {
+	cfg, shares := benchmarkShares(b, 16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg.NewLagrangeBasis(shares)
+	}
+}
+
+func BenchmarkLagrangeBasisGenerateSecret(b *testing.B) {
+	cfg, shares := benchmarkShares(b, 16)
+	basis, _ := cfg.NewLagrangeBasis(shares)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		basis.GenerateSecret(shares)
+	}
+}
+
+func BenchmarkGenerateSecrets(b *testing.B) {
+	cfg, shares := benchmarkShares(b, 16)
+	sets := make([][]Share, 100)
+	for idx := range sets {
+		sets[idx] = shares
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg.GenerateSecrets(sets)
+	}
+}
+
+func TestShamirRegisteredPrime(t *testing.T) {
+	// 2^127 - 1
+	modulus := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	prime, err := ff.RegisterPrime(modulus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var minShares, maxShares = 3, 5
+	cfg, err := NewConfig(minShares, maxShares, prime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := cfg.NewSecret()
+	shares, _ := cfg.GenerateShares(secret)
+	rxShares := make([]Share, 0)
+	for _, share := range shuffleShares(shares, minShares) {
+		rxShare, err := (&Share{}).Unmarshal(share.Marshal(prime))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rxShares = append(rxShares, *rxShare)
+	}
+	newSecret, err := cfg.GenerateSecret(rxShares)
+	if err != nil || !secret.Equal(newSecret) {
+		t.Error("Secrets not equal")
+	}
+
+	// elements need to fit in shares
+	modulus = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 521), big.NewInt(1))
+	prime, _ = ff.RegisterPrime(modulus)
+	_, err = NewConfig(minShares, maxShares, prime)
+	if err == nil {
+		t.Error("Element larger than share accepted")
+	}
+}
+
+// Deterministic sharing test vector. Elements are decimal strings in regular form
+type shamirVector struct {
+	Seed        string   `json:"seed"`
+	ElementType int      `json:"element_type"`
+	MinShares   int      `json:"min_shares"`
+	MaxShares   int      `json:"max_shares"`
+	RandomPx    bool     `json:"random_px"`
+	Secret      string   `json:"secret"`
+	SetId       string   `json:"set_id"`
+	Px          []string `json:"px"`
+	Py          []string `json:"py"`
+}
+
+// Generate secret and shares of vector configuration from its seed
+func (v *shamirVector) generate() (ff.Element, []Share, error) {
+	cfg, err := NewConfig(v.MinShares, v.MaxShares, v.ElementType)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.RandomPx = v.RandomPx
+	cfg.SetRandomSource(ff.NewSeededReader([]byte(v.Seed)))
+	secret := cfg.NewSecret()
+	shares, err := cfg.GenerateShares(secret)
+	return secret, shares, err
+}
+
+func TestShamirVectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/shamir_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []shamirVector
+	if err = json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		secret, shares, err := v.generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret.String() != v.Secret || hex.EncodeToString(shares[0].SetId[:]) != v.SetId || len(shares) != len(v.Px) {
+			t.Error("Unexpected secret", v.Seed)
+			continue
+		}
+		for idx, share := range shares {
+			if share.Px.String() != v.Px[idx] || share.Py.String() != v.Py[idx] {
+				t.Error("Unexpected share", v.Seed, idx)
+			}
+		}
+		cfg, _ := NewConfig(v.MinShares, v.MaxShares, v.ElementType)
+		newSecret, err := cfg.GenerateSecret(shuffleShares(shares, v.MinShares))
+		if err != nil || !newSecret.Equal(secret) {
+			t.Error("Secrets not equal", v.Seed)
+		}
+	}
+
+	// a different source generates a different sharing
+	v := vectors[0]
+	v.Seed += "x"
+	secret, _, _ := v.generate()
+	if secret.String() == vectors[0].Secret {
+		t.Error("Sharing does not depend on seed")
+	}
+}
@@ -1,48 +1,343 @@
 package shamir
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
+	"math/big"
 
 	"github.com/iden3/go-backup/ff"
 )
 
+// Legacy share layout
+//   Px      [8 Bytes]
+//   Py      [32 Bytes]
+//   FF type [1 Byte]
 const (
-	PX_OFFSET     = 0
-	PY_OFFSET     = 8
-	FFTYPE_OFFSET = 40
-	SHARE_SIZE    = 41
+	PX_OFFSET         = 0
+	PY_OFFSET         = 8
+	FFTYPE_OFFSET     = 40
+	LEGACY_SHARE_SIZE = 41
+)
+
+// Share envelope layout (version 2)
+//   Version   [1 Byte]
+//   FF type   [1 Byte]
+//   Set Id    [8 Bytes] : random identifier of the sharing set
+//   MinShares [2 Bytes]
+//   MaxShares [2 Bytes]
+//   Px        [32 Bytes] : canonical little endian regular form, zero padded
+//   Py        [32 Bytes] : canonical little endian regular form, zero padded
+//   Checksum  [4 Bytes] : first 4 bytes of SHA256 of previous fields
+//
+// Version 1 envelopes are identical, but Px is an 8 Byte integer
+const (
+	SHARE_ENV_VERSION_1 = 1
+	SHARE_ENV_VERSION_2 = 2
+	SETID_SIZE          = 8
+	ENV_CHECKSUM_SIZE   = 4
+
+	ENV_VERSION_OFFSET   = 0
+	ENV_FFTYPE_OFFSET    = 1
+	ENV_SETID_OFFSET     = 2
+	ENV_MINSHARES_OFFSET = ENV_SETID_OFFSET + SETID_SIZE
+	ENV_MAXSHARES_OFFSET = ENV_MINSHARES_OFFSET + 2
+	ENV_PX_OFFSET        = ENV_MAXSHARES_OFFSET + 2
+	ENV_PY_OFFSET        = ENV_PX_OFFSET + ELEMENT_SIZE
+	ENV_CHECKSUM_OFFSET  = ENV_PY_OFFSET + ELEMENT_SIZE
+	SHARE_SIZE           = ENV_CHECKSUM_OFFSET + ENV_CHECKSUM_SIZE
+
+	ENV_V1_PY_OFFSET       = ENV_PX_OFFSET + 8
+	ENV_V1_CHECKSUM_OFFSET = ENV_V1_PY_OFFSET + ELEMENT_SIZE
+	SHARE_V1_SIZE          = ENV_V1_CHECKSUM_OFFSET + ENV_CHECKSUM_SIZE
+)
+
+// Byte share layout
+//   Px    [1 Byte]
+//   Flags [1 Byte]
+//   Py    [Variable size]
+const (
+	BYTE_PX_OFFSET    = 0
+	BYTE_FLAGS_OFFSET = 1
+	BYTE_PY_OFFSET    = 2
+)
+
+// Byte share flags
+const (
+	BYTE_SHARE_F_LEN    = 0x01 // secret is length prefixed and padded
+	BYTE_SHARE_LEN_SIZE = 4
+	BYTE_SHARE_PAD      = 16
+)
+
+// Multi-element share layout
+//   Px        [8 Bytes]
+//   FF type   [1 Byte]
+//   Mode      [1 Byte]
+//   NSecrets  [2 Bytes]
+//   NElements [2 Bytes]
+//   Py        [NElements * ELEMENT_SIZE Bytes]
+const (
+	MULTI_PX_OFFSET        = 0
+	MULTI_FFTYPE_OFFSET    = 8
+	MULTI_MODE_OFFSET      = 9
+	MULTI_NSECRETS_OFFSET  = 10
+	MULTI_NELEMENTS_OFFSET = 12
+	MULTI_PY_OFFSET        = 14
+	MULTI_MAX_SECRETS      = 0xffff
+	ELEMENT_SIZE           = FFTYPE_OFFSET - PY_OFFSET
 )
 
 type Share struct {
-	Px int
-	Py ff.Element
+	Px        ff.Element       // x-coordinate. Sequential (1..MaxShares) or random nonzero element
+	Py        ff.Element
+	SetId     [SETID_SIZE]byte // Sharing set identifier. Zero if share does not belong to a known set
+	MinShares int              // Threshold of sharing set
+	MaxShares int              // Number of shares in sharing set
 }
 
+// Returns true if share carries sharing set information
+func (s Share) HasSet() bool {
+	return s.SetId != [SETID_SIZE]byte{}
+}
+
+// Encode share into a versioned envelope
 func (s Share) Marshal(p int) []byte {
 	b := make([]byte, SHARE_SIZE)
-	binary.LittleEndian.PutUint64(b[PX_OFFSET:PY_OFFSET], uint64(s.Px))
-	copy(b[PY_OFFSET:FFTYPE_OFFSET], s.Py.ToByte())
-	b[FFTYPE_OFFSET] = byte(p)
+	b[ENV_VERSION_OFFSET] = SHARE_ENV_VERSION_2
+	b[ENV_FFTYPE_OFFSET] = byte(p)
+	copy(b[ENV_SETID_OFFSET:ENV_MINSHARES_OFFSET], s.SetId[:])
+	binary.LittleEndian.PutUint16(b[ENV_MINSHARES_OFFSET:ENV_MAXSHARES_OFFSET], uint16(s.MinShares))
+	binary.LittleEndian.PutUint16(b[ENV_MAXSHARES_OFFSET:ENV_PX_OFFSET], uint16(s.MaxShares))
+	copy(b[ENV_PX_OFFSET:ENV_PY_OFFSET], s.Px.ToBytesLE())
+	copy(b[ENV_PY_OFFSET:ENV_CHECKSUM_OFFSET], s.Py.ToBytesLE())
+	copy(b[ENV_CHECKSUM_OFFSET:], envelopeChecksum(b[:ENV_CHECKSUM_OFFSET]))
 
 	return b
 }
 
+// Decode share. Versioned envelopes (1 and 2) and legacy (unversioned) shares are supported
 func (s *Share) Unmarshal(b []byte) (*Share, error) {
-	var err error
-	s.Px = int(binary.LittleEndian.Uint64(b[PX_OFFSET:PY_OFFSET]))
+	var pyOffset, checksumOffset int
+
+	switch {
+	case len(b) == LEGACY_SHARE_SIZE:
+		return s.unmarshalLegacy(b)
+
+	case len(b) == SHARE_SIZE && b[ENV_VERSION_OFFSET] == SHARE_ENV_VERSION_2:
+		pyOffset, checksumOffset = ENV_PY_OFFSET, ENV_CHECKSUM_OFFSET
+
+	case len(b) == SHARE_V1_SIZE && b[ENV_VERSION_OFFSET] == SHARE_ENV_VERSION_1:
+		pyOffset, checksumOffset = ENV_V1_PY_OFFSET, ENV_V1_CHECKSUM_OFFSET
+
+	case len(b) == SHARE_SIZE || len(b) == SHARE_V1_SIZE:
+		return nil, errors.New("Unknown share version")
+
+	default:
+		return nil, errors.New("Invalid share length")
+	}
+	if !bytes.Equal(b[checksumOffset:], envelopeChecksum(b[:checksumOffset])) {
+		return nil, errors.New("Invalid share checksum")
+	}
+
+	p := int(b[ENV_FFTYPE_OFFSET])
+	px, err := ff.NewElement(p)
+	if err != nil {
+		return nil, err
+	}
+
+	copy(s.SetId[:], b[ENV_SETID_OFFSET:ENV_MINSHARES_OFFSET])
+	s.MinShares = int(binary.LittleEndian.Uint16(b[ENV_MINSHARES_OFFSET:ENV_MAXSHARES_OFFSET]))
+	s.MaxShares = int(binary.LittleEndian.Uint16(b[ENV_MAXSHARES_OFFSET:ENV_PX_OFFSET]))
+	if s.HasSet() && (s.MinShares == 0 || s.MinShares > s.MaxShares) {
+		return nil, errors.New("Invalid share set parameters")
+	}
+
+	if b[ENV_VERSION_OFFSET] == SHARE_ENV_VERSION_1 {
+		// version 1 shares always have sequential x-coordinates
+		x := binary.LittleEndian.Uint64(b[ENV_PX_OFFSET:pyOffset])
+		if s.HasSet() && x > uint64(s.MaxShares) {
+			return nil, errors.New("Invalid share set parameters")
+		}
+		s.Px = px.SetUint64(x)
+	} else if s.Px, err = getElement(b[ENV_PX_OFFSET:pyOffset], p); err != nil {
+		return nil, err
+	}
+	if s.Px.IsZero() {
+		return nil, errors.New("Invalid share x-coordinate")
+	}
+	if s.Py, err = getElement(b[pyOffset:checksumOffset], p); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Decode legacy share (Px, Py and FF type only)
+func (s *Share) unmarshalLegacy(b []byte) (*Share, error) {
 	p := int(b[FFTYPE_OFFSET])
-	s.Py, err = ff.NewElement(p)
+	px, err := ff.NewElement(p)
 	if err != nil {
 		return nil, err
 	}
-	s.Py = s.Py.FromByte(b[PY_OFFSET:FFTYPE_OFFSET])
+	s.Px = px.SetUint64(binary.LittleEndian.Uint64(b[PX_OFFSET:PY_OFFSET]))
+	if s.Py, err = getElement(b[PY_OFFSET:FFTYPE_OFFSET], p); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
 
+// Decode element of type p stored in an ELEMENT_SIZE slot. Elements are stored in canonical little
+// endian form, zero padded to ELEMENT_SIZE bytes. Values out of range are rejected
+func getElement(b []byte, p int) (ff.Element, error) {
+	el, err := ff.NewElement(p)
+	if err != nil {
+		return nil, err
+	}
+	n := len(el.ToBytesLE())
+	for _, pad := range b[n:] {
+		if pad != 0 {
+			return nil, errors.New("Invalid share element")
+		}
+	}
+	if _, err = el.SetBytesLE(b[:n]); err != nil {
+		return nil, errors.New("Invalid share element")
+	}
+	return el, nil
+}
+
 func (s *Share) Hash(primeF int) []byte {
 	sharesByte := s.Marshal(primeF)
 	sharesHash := sha256.Sum256(sharesByte)
 	return sharesHash[:]
 }
+
+func envelopeChecksum(b []byte) []byte {
+	h := sha256.Sum256(b)
+	return h[:ENV_CHECKSUM_SIZE]
+}
+
+// Check that shares belong to the same sharing set, match threshold and are not duplicated.
+// Shares without set information are only checked for duplicates, and can't be mixed with shares
+// of a sharing set
+func checkShares(shares []Share, minShares, maxShares int) error {
+	var setId [SETID_SIZE]byte
+	for idx, share := range shares {
+		if share.Px == nil || share.Px.IsZero() {
+			return errors.New("Shamir's Secret : Invalid share x-coordinate")
+		}
+		for _, prev := range shares[:idx] {
+			if prev.Px.Equal(share.Px) {
+				return errors.New("Shamir's Secret : Duplicated share")
+			}
+		}
+		if share.HasSet() != shares[0].HasSet() {
+			return errors.New("Shamir's Secret : Shares with and without sharing set mixed")
+		}
+		if !share.HasSet() {
+			continue
+		}
+		if setId == [SETID_SIZE]byte{} {
+			setId = share.SetId
+		}
+		if share.SetId != setId {
+			return errors.New("Shamir's Secret : Shares belong to different sharing sets")
+		}
+		if share.MinShares != minShares || share.MaxShares != maxShares {
+			return errors.New("Shamir's Secret : Share does not match sharing configuration")
+		}
+	}
+	return nil
+}
+
+// Share of a secret split byte-wise over GF(2^8)
+type ByteShare struct {
+	Px    byte
+	Py    []byte
+	Flags byte
+}
+
+func (s ByteShare) Marshal() []byte {
+	b := make([]byte, BYTE_PY_OFFSET+len(s.Py))
+	b[BYTE_PX_OFFSET] = s.Px
+	b[BYTE_FLAGS_OFFSET] = s.Flags
+	copy(b[BYTE_PY_OFFSET:], s.Py)
+
+	return b
+}
+
+func (s *ByteShare) Unmarshal(b []byte) (*ByteShare, error) {
+	if len(b) < BYTE_PY_OFFSET {
+		return nil, errors.New("Invalid byte share length")
+	}
+	s.Px = b[BYTE_PX_OFFSET]
+	s.Flags = b[BYTE_FLAGS_OFFSET]
+	s.Py = make([]byte, len(b)-BYTE_PY_OFFSET)
+	copy(s.Py, b[BYTE_PY_OFFSET:])
+
+	return s, nil
+}
+
+func (s *ByteShare) Hash() []byte {
+	sharesHash := sha256.Sum256(s.Marshal())
+	return sharesHash[:]
+}
+
+// Share of a multi-element secret. Py holds one element per secret element (MULTI_INDEPENDENT)
+// or a single element (MULTI_PACKED)
+// Share of a multi-element secret. Multi-element sharings use sequential x-coordinates
+// (1..MaxShares), stored as an 8 Byte integer
+type MultiShare struct {
+	Px       ff.Element
+	Py       []ff.Element
+	Mode     int
+	NSecrets int
+}
+
+func (s MultiShare) Marshal(p int) []byte {
+	b := make([]byte, MULTI_PY_OFFSET+len(s.Py)*ELEMENT_SIZE)
+	binary.LittleEndian.PutUint64(b[MULTI_PX_OFFSET:MULTI_FFTYPE_OFFSET], s.Px.ToBigIntRegular(new(big.Int)).Uint64())
+	b[MULTI_FFTYPE_OFFSET] = byte(p)
+	b[MULTI_MODE_OFFSET] = byte(s.Mode)
+	binary.LittleEndian.PutUint16(b[MULTI_NSECRETS_OFFSET:MULTI_NELEMENTS_OFFSET], uint16(s.NSecrets))
+	binary.LittleEndian.PutUint16(b[MULTI_NELEMENTS_OFFSET:MULTI_PY_OFFSET], uint16(len(s.Py)))
+	for idx, py := range s.Py {
+		offset := MULTI_PY_OFFSET + idx*ELEMENT_SIZE
+		copy(b[offset:offset+ELEMENT_SIZE], py.ToBytesLE())
+	}
+
+	return b
+}
+
+func (s *MultiShare) Unmarshal(b []byte) (*MultiShare, error) {
+	if len(b) < MULTI_PY_OFFSET {
+		return nil, errors.New("Invalid multi-element share length")
+	}
+	nElements := int(binary.LittleEndian.Uint16(b[MULTI_NELEMENTS_OFFSET:MULTI_PY_OFFSET]))
+	if len(b) != MULTI_PY_OFFSET+nElements*ELEMENT_SIZE {
+		return nil, errors.New("Invalid multi-element share length")
+	}
+	p := int(b[MULTI_FFTYPE_OFFSET])
+	px, err := ff.NewElement(p)
+	if err != nil {
+		return nil, err
+	}
+	s.Px = px.SetUint64(binary.LittleEndian.Uint64(b[MULTI_PX_OFFSET:MULTI_FFTYPE_OFFSET]))
+	if s.Px.IsZero() {
+		return nil, errors.New("Invalid share x-coordinate")
+	}
+	s.Mode = int(b[MULTI_MODE_OFFSET])
+	s.NSecrets = int(binary.LittleEndian.Uint16(b[MULTI_NSECRETS_OFFSET:MULTI_NELEMENTS_OFFSET]))
+	s.Py = make([]ff.Element, nElements)
+	for idx := range s.Py {
+		offset := MULTI_PY_OFFSET + idx*ELEMENT_SIZE
+		el, err := getElement(b[offset:offset+ELEMENT_SIZE], p)
+		if err != nil {
+			return nil, err
+		}
+		s.Py[idx] = el
+	}
+
+	return s, nil
+}
@@ -0,0 +1,519 @@
+/*
+  SLIP-0039 mnemonic share encoding (https://github.com/satoshilabs/slips/blob/master/slip-0039.md)
+
+  Master secret is encrypted with a 4 round Feistel network keyed with a passphrase, and the encrypted
+  secret is split in two levels of Shamir's secret sharing over GF(2^8) : first among groups and then
+  among members of every group. Every member share is encoded as a list of words :
+
+   identifier          [15 bits]
+   extendable flag     [1 bit]
+   iteration exponent  [4 bits]
+   group index         [4 bits]
+   group threshold - 1 [4 bits]
+   group count - 1     [4 bits]
+   member index        [4 bits]
+   member threshold -1 [4 bits]
+   padded share value  [10 bits per word]
+   RS1024 checksum     [30 bits]
+*/
+
+package shamir
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	SLIP39_RADIX_BITS             = 10
+	SLIP39_RADIX                  = 1 << SLIP39_RADIX_BITS
+	SLIP39_ID_BITS                = 15
+	SLIP39_EXP_BITS               = 4
+	SLIP39_ID_EXP_WORDS           = 2
+	SLIP39_CHECKSUM_WORDS         = 3
+	SLIP39_METADATA_WORDS         = SLIP39_ID_EXP_WORDS + 2 + SLIP39_CHECKSUM_WORDS
+	SLIP39_MIN_STRENGTH_BYTES     = 16
+	SLIP39_MIN_MNEMONIC_WORDS     = SLIP39_METADATA_WORDS + 13
+	SLIP39_MAX_SHARES             = 16
+	SLIP39_DIGEST_BYTES           = 4
+	SLIP39_DIGEST_INDEX           = 254
+	SLIP39_SECRET_INDEX           = 255
+	SLIP39_ROUND_COUNT            = 4
+	SLIP39_BASE_ITERATION_COUNT   = 10000
+	SLIP39_CUSTOMIZATION          = "shamir"
+	SLIP39_CUSTOMIZATION_EXTENDED = "shamir_extendable"
+)
+
+// Member share encoded as a SLIP-0039 mnemonic
+type Slip39Share struct {
+	Identifier        int
+	Extendable        bool
+	IterationExponent int
+	GroupIndex        int
+	GroupThreshold    int
+	GroupCount        int
+	MemberIndex       int
+	MemberThreshold   int
+	Value             []byte
+}
+
+// Group definition : MemberThreshold out of MemberCount shares
+type Slip39Group struct {
+	MemberThreshold int
+	MemberCount     int
+}
+
+// Generate SLIP-0039 mnemonics of secret using a single group with MinShares out of MaxShares
+// members. Configuration needs to be initialized with FF_GF256 element type
+func (s Shamir) GenerateMnemonicShares(secret, passphrase []byte, iterationExponent int) ([]string, error) {
+	if s.ElementType != FF_GF256 {
+		return nil, errors.New("Shamir's Secret : SLIP-0039 sharing requires FF_GF256 element type")
+	}
+	groups, err := GenerateSlip39Shares(s.randomSource(), secret, passphrase, 1,
+		[]Slip39Group{{MemberThreshold: s.MinShares, MemberCount: s.MaxShares}}, iterationExponent)
+	if err != nil {
+		return nil, err
+	}
+	return groups[0], nil
+}
+
+// Generate secret from SLIP-0039 mnemonics
+func (s Shamir) GenerateMnemonicSecret(mnemonics []string, passphrase []byte) ([]byte, error) {
+	return CombineSlip39Shares(mnemonics, passphrase)
+}
+
+// Split secret into SLIP-0039 mnemonics. Returns the mnemonics of every group. Identifier and share
+// values are read from random, or from crypto/rand if random is nil
+func GenerateSlip39Shares(random io.Reader, secret, passphrase []byte, groupThreshold int, groups []Slip39Group, iterationExponent int) ([][]string, error) {
+	if len(secret) < SLIP39_MIN_STRENGTH_BYTES || len(secret)%2 != 0 {
+		return nil, errors.New("SLIP-0039 : Secret needs to be at least 128 bits long and have an even number of bytes")
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if iterationExponent < 0 || iterationExponent >= 1<<SLIP39_EXP_BITS {
+		return nil, errors.New("SLIP-0039 : Invalid iteration exponent")
+	}
+	if groupThreshold <= 0 || groupThreshold > len(groups) || len(groups) > SLIP39_MAX_SHARES {
+		return nil, errors.New("SLIP-0039 : Invalid group threshold")
+	}
+	for _, group := range groups {
+		if group.MemberThreshold <= 0 || group.MemberThreshold > group.MemberCount || group.MemberCount > SLIP39_MAX_SHARES {
+			return nil, errors.New("SLIP-0039 : Invalid member threshold")
+		}
+		if group.MemberThreshold == 1 && group.MemberCount > 1 {
+			return nil, errors.New("SLIP-0039 : Multiple member shares with member threshold 1 not allowed")
+		}
+	}
+
+	if random == nil {
+		random = rand.Reader
+	}
+	idBytes := make([]byte, 2)
+	if _, err := io.ReadFull(random, idBytes); err != nil {
+		return nil, err
+	}
+	identifier := (int(idBytes[0])<<8 | int(idBytes[1])) & (1<<SLIP39_ID_BITS - 1)
+	extendable := true
+
+	encryptedSecret := slip39Encrypt(secret, passphrase, iterationExponent, identifier, extendable)
+
+	groupShares, err := slip39SplitSecret(random, groupThreshold, len(groups), encryptedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	mnemonics := make([][]string, len(groups))
+	for groupIdx, group := range groups {
+		memberShares, err := slip39SplitSecret(random, group.MemberThreshold, group.MemberCount, groupShares[groupIdx])
+		if err != nil {
+			return nil, err
+		}
+		mnemonics[groupIdx] = make([]string, len(memberShares))
+		for memberIdx, value := range memberShares {
+			share := Slip39Share{
+				Identifier:        identifier,
+				Extendable:        extendable,
+				IterationExponent: iterationExponent,
+				GroupIndex:        groupIdx,
+				GroupThreshold:    groupThreshold,
+				GroupCount:        len(groups),
+				MemberIndex:       memberIdx,
+				MemberThreshold:   group.MemberThreshold,
+				Value:             value,
+			}
+			mnemonics[groupIdx][memberIdx] = share.Mnemonic()
+		}
+	}
+
+	return mnemonics, nil
+}
+
+// Recover secret from SLIP-0039 mnemonics. Mnemonics need to satisfy group and member thresholds
+func CombineSlip39Shares(mnemonics []string, passphrase []byte) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, errors.New("SLIP-0039 : No mnemonics provided")
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	shares := make([]Slip39Share, len(mnemonics))
+	for idx, mnemonic := range mnemonics {
+		share := &Slip39Share{}
+		share, err := share.ParseMnemonic(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+		shares[idx] = *share
+	}
+
+	// group shares by group index
+	first := shares[0]
+	groups := make(map[int][]Slip39Share)
+	groupOrder := make([]int, 0)
+	for _, share := range shares {
+		if share.Identifier != first.Identifier || share.Extendable != first.Extendable ||
+			share.IterationExponent != first.IterationExponent {
+			return nil, errors.New("SLIP-0039 : Mnemonics belong to different secrets")
+		}
+		if share.GroupThreshold != first.GroupThreshold || share.GroupCount != first.GroupCount ||
+			len(share.Value) != len(first.Value) {
+			return nil, errors.New("SLIP-0039 : Inconsistent group parameters")
+		}
+		group, ok := groups[share.GroupIndex]
+		if !ok {
+			groupOrder = append(groupOrder, share.GroupIndex)
+		}
+		for _, prev := range group {
+			if prev.MemberThreshold != share.MemberThreshold {
+				return nil, errors.New("SLIP-0039 : Inconsistent member threshold")
+			}
+			if prev.MemberIndex == share.MemberIndex {
+				return nil, errors.New("SLIP-0039 : Duplicated member share")
+			}
+		}
+		groups[share.GroupIndex] = append(group, share)
+	}
+
+	// recover group shares from complete groups
+	px := make([]byte, 0)
+	py := make([][]byte, 0)
+	for _, groupIdx := range groupOrder {
+		group := groups[groupIdx]
+		if len(group) < group[0].MemberThreshold {
+			continue
+		}
+		memberX := make([]byte, 0)
+		memberY := make([][]byte, 0)
+		for _, share := range group[:group[0].MemberThreshold] {
+			memberX = append(memberX, byte(share.MemberIndex))
+			memberY = append(memberY, share.Value)
+		}
+		value, err := slip39RecoverSecret(group[0].MemberThreshold, memberX, memberY)
+		if err != nil {
+			return nil, err
+		}
+		px = append(px, byte(groupIdx))
+		py = append(py, value)
+		if len(px) == first.GroupThreshold {
+			break
+		}
+	}
+	if len(px) < first.GroupThreshold {
+		return nil, errors.New("SLIP-0039 : Insufficient number of complete groups")
+	}
+
+	encryptedSecret, err := slip39RecoverSecret(first.GroupThreshold, px, py)
+	if err != nil {
+		return nil, err
+	}
+	return slip39Decrypt(encryptedSecret, passphrase, first.IterationExponent, first.Identifier, first.Extendable), nil
+}
+
+// Encode share as a space separated list of words
+func (s Slip39Share) Mnemonic() string {
+	words := s.wordIndexes()
+	mnemonic := make([]string, len(words))
+	for idx, w := range words {
+		mnemonic[idx] = slip39Wordlist[w]
+	}
+	return strings.Join(mnemonic, " ")
+}
+
+func (s Slip39Share) wordIndexes() []int {
+	ext := 0
+	if s.Extendable {
+		ext = 1
+	}
+	idExp := s.Identifier<<(SLIP39_EXP_BITS+1) | ext<<SLIP39_EXP_BITS | s.IterationExponent
+	params := s.GroupIndex<<16 | (s.GroupThreshold-1)<<12 | (s.GroupCount-1)<<8 | s.MemberIndex<<4 | (s.MemberThreshold - 1)
+
+	valueWords := (8*len(s.Value) + SLIP39_RADIX_BITS - 1) / SLIP39_RADIX_BITS
+	words := make([]int, 0, SLIP39_METADATA_WORDS+valueWords)
+	words = append(words, idExp>>SLIP39_RADIX_BITS, idExp&(SLIP39_RADIX-1))
+	words = append(words, params>>SLIP39_RADIX_BITS, params&(SLIP39_RADIX-1))
+	words = append(words, bytesToWords(s.Value, valueWords)...)
+
+	checksum := rs1024CreateChecksum(customization(s.Extendable), words)
+	return append(words, checksum...)
+}
+
+// Decode share from a list of words. Words can be abbreviated to their first 4 letters
+func (s *Slip39Share) ParseMnemonic(mnemonic string) (*Slip39Share, error) {
+	fields := strings.Fields(strings.ToLower(mnemonic))
+	if len(fields) < SLIP39_MIN_MNEMONIC_WORDS {
+		return nil, errors.New("SLIP-0039 : Invalid mnemonic length")
+	}
+	words := make([]int, len(fields))
+	for idx, field := range fields {
+		w, err := wordIndex(field)
+		if err != nil {
+			return nil, err
+		}
+		words[idx] = w
+	}
+
+	paddingLen := (SLIP39_RADIX_BITS * (len(words) - SLIP39_METADATA_WORDS)) % 16
+	if paddingLen > 8 {
+		return nil, errors.New("SLIP-0039 : Invalid mnemonic length")
+	}
+
+	idExp := words[0]<<SLIP39_RADIX_BITS | words[1]
+	s.Identifier = idExp >> (SLIP39_EXP_BITS + 1)
+	s.Extendable = (idExp>>SLIP39_EXP_BITS)&1 == 1
+	s.IterationExponent = idExp & (1<<SLIP39_EXP_BITS - 1)
+
+	if !rs1024VerifyChecksum(customization(s.Extendable), words) {
+		return nil, errors.New("SLIP-0039 : Invalid mnemonic checksum")
+	}
+
+	params := words[2]<<SLIP39_RADIX_BITS | words[3]
+	s.GroupIndex = params >> 16
+	s.GroupThreshold = (params>>12)&0xf + 1
+	s.GroupCount = (params>>8)&0xf + 1
+	s.MemberIndex = (params >> 4) & 0xf
+	s.MemberThreshold = params&0xf + 1
+	if s.GroupThreshold > s.GroupCount {
+		return nil, errors.New("SLIP-0039 : Group threshold exceeds group count")
+	}
+
+	valueWords := words[SLIP39_ID_EXP_WORDS+2 : len(words)-SLIP39_CHECKSUM_WORDS]
+	valueLen := (SLIP39_RADIX_BITS*len(valueWords) - paddingLen) / 8
+	value, err := wordsToBytes(valueWords, valueLen)
+	if err != nil {
+		return nil, err
+	}
+	s.Value = value
+
+	return s, nil
+}
+
+func wordIndex(word string) (int, error) {
+	for idx, w := range slip39Wordlist {
+		if w == word || (len(word) >= 4 && strings.HasPrefix(w, word)) {
+			return idx, nil
+		}
+	}
+	return 0, errors.New("SLIP-0039 : Invalid mnemonic word " + word)
+}
+
+// Big endian bytes to nWords 10 bit words. Padding bits are added at the front
+func bytesToWords(b []byte, nWords int) []int {
+	n := new(big.Int).SetBytes(b)
+	words := make([]int, nWords)
+	mask := big.NewInt(SLIP39_RADIX - 1)
+	for idx := nWords - 1; idx >= 0; idx-- {
+		words[idx] = int(new(big.Int).And(n, mask).Int64())
+		n.Rsh(n, SLIP39_RADIX_BITS)
+	}
+	return words
+}
+
+// 10 bit words to big endian bytes. Padding bits need to be zero
+func wordsToBytes(words []int, nBytes int) ([]byte, error) {
+	n := new(big.Int)
+	for _, w := range words {
+		n.Lsh(n, SLIP39_RADIX_BITS)
+		n.Or(n, big.NewInt(int64(w)))
+	}
+	if n.BitLen() > 8*nBytes {
+		return nil, errors.New("SLIP-0039 : Invalid mnemonic padding")
+	}
+	b := make([]byte, nBytes)
+	nb := n.Bytes()
+	copy(b[nBytes-len(nb):], nb)
+	return b, nil
+}
+
+func customization(extendable bool) []byte {
+	if extendable {
+		return []byte(SLIP39_CUSTOMIZATION_EXTENDED)
+	}
+	return []byte(SLIP39_CUSTOMIZATION)
+}
+
+func rs1024Polymod(values []int) int {
+	gen := [10]int{
+		0xE0E040, 0x1C1C080, 0x3838100, 0x7070200, 0xE0E0009,
+		0x1C0C2412, 0x38086C24, 0x3090FC48, 0x21B1F890, 0x3F3F120,
+	}
+	chk := 1
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xFFFFF)<<10 ^ v
+		for idx := 0; idx < 10; idx++ {
+			if (b>>idx)&1 == 1 {
+				chk ^= gen[idx]
+			}
+		}
+	}
+	return chk
+}
+
+func rs1024CreateChecksum(cs []byte, words []int) []int {
+	values := make([]int, 0, len(cs)+len(words)+SLIP39_CHECKSUM_WORDS)
+	for _, c := range cs {
+		values = append(values, int(c))
+	}
+	values = append(values, words...)
+	values = append(values, 0, 0, 0)
+	polymod := rs1024Polymod(values) ^ 1
+	checksum := make([]int, SLIP39_CHECKSUM_WORDS)
+	for idx := range checksum {
+		checksum[idx] = (polymod >> (SLIP39_RADIX_BITS * (SLIP39_CHECKSUM_WORDS - 1 - idx))) & (SLIP39_RADIX - 1)
+	}
+	return checksum
+}
+
+func rs1024VerifyChecksum(cs []byte, words []int) bool {
+	values := make([]int, 0, len(cs)+len(words))
+	for _, c := range cs {
+		values = append(values, int(c))
+	}
+	values = append(values, words...)
+	return rs1024Polymod(values) == 1
+}
+
+// Split secret into count shares with the given threshold. Share indexes are 0..count-1.
+// A digest of the secret is embedded at index 254 and the secret at index 255
+func slip39SplitSecret(random io.Reader, threshold, count int, secret []byte) ([][]byte, error) {
+	shares := make([][]byte, count)
+	if threshold == 1 {
+		for idx := range shares {
+			shares[idx] = append([]byte{}, secret...)
+		}
+		return shares, nil
+	}
+
+	randomShares := threshold - 2
+	px := make([]byte, 0, threshold)
+	py := make([][]byte, 0, threshold)
+	for idx := 0; idx < randomShares; idx++ {
+		shares[idx] = make([]byte, len(secret))
+		if _, err := io.ReadFull(random, shares[idx]); err != nil {
+			return nil, err
+		}
+		px = append(px, byte(idx))
+		py = append(py, shares[idx])
+	}
+
+	randomPart := make([]byte, len(secret)-SLIP39_DIGEST_BYTES)
+	if _, err := io.ReadFull(random, randomPart); err != nil {
+		return nil, err
+	}
+	digest := slip39Digest(randomPart, secret)
+	px = append(px, SLIP39_DIGEST_INDEX, SLIP39_SECRET_INDEX)
+	py = append(py, append(digest, randomPart...), secret)
+
+	for idx := randomShares; idx < count; idx++ {
+		shares[idx] = gf256InterpolateBytes(px, py, byte(idx))
+	}
+	return shares, nil
+}
+
+// Recover secret from threshold shares and check its digest
+func slip39RecoverSecret(threshold int, px []byte, py [][]byte) ([]byte, error) {
+	if threshold == 1 {
+		return py[0], nil
+	}
+	secret := gf256InterpolateBytes(px, py, SLIP39_SECRET_INDEX)
+	digestShare := gf256InterpolateBytes(px, py, SLIP39_DIGEST_INDEX)
+	digest := digestShare[:SLIP39_DIGEST_BYTES]
+	if !bytes.Equal(digest, slip39Digest(digestShare[SLIP39_DIGEST_BYTES:], secret)) {
+		return nil, errors.New("SLIP-0039 : Invalid digest of the shared secret")
+	}
+	return secret, nil
+}
+
+func slip39Digest(randomPart, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomPart)
+	mac.Write(secret)
+	return mac.Sum(nil)[:SLIP39_DIGEST_BYTES]
+}
+
+// Byte-wise interpolation at x of shares (px[i], py[i])
+func gf256InterpolateBytes(px []byte, py [][]byte, x byte) []byte {
+	result := make([]byte, len(py[0]))
+	ys := make([]byte, len(py))
+	for bIdx := range result {
+		for idx := range py {
+			ys[idx] = py[idx][bIdx]
+		}
+		result[bIdx] = gf256Interpolate(px, ys, x)
+	}
+	return result
+}
+
+func checkPassphrase(passphrase []byte) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return errors.New("SLIP-0039 : Passphrase needs to contain only printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+func slip39Salt(identifier int, extendable bool) []byte {
+	if extendable {
+		return []byte{}
+	}
+	return append([]byte(SLIP39_CUSTOMIZATION), byte(identifier>>8), byte(identifier))
+}
+
+func slip39RoundFunction(round int, passphrase []byte, exponent int, salt, r []byte) []byte {
+	iterations := (SLIP39_BASE_ITERATION_COUNT << uint(exponent)) / SLIP39_ROUND_COUNT
+	password := append([]byte{byte(round)}, passphrase...)
+	return pbkdf2.Key(password, append(append([]byte{}, salt...), r...), iterations, len(r), sha256.New)
+}
+
+func slip39Feistel(in, passphrase []byte, exponent, identifier int, extendable bool, rounds []int) []byte {
+	half := len(in) / 2
+	l := append([]byte{}, in[:half]...)
+	r := append([]byte{}, in[half:]...)
+	salt := slip39Salt(identifier, extendable)
+	for _, round := range rounds {
+		f := slip39RoundFunction(round, passphrase, exponent, salt, r)
+		for idx := range f {
+			f[idx] ^= l[idx]
+		}
+		l, r = r, f
+	}
+	return append(r, l...)
+}
+
+func slip39Encrypt(secret, passphrase []byte, exponent, identifier int, extendable bool) []byte {
+	return slip39Feistel(secret, passphrase, exponent, identifier, extendable, []int{0, 1, 2, 3})
+}
+
+func slip39Decrypt(encrypted, passphrase []byte, exponent, identifier int, extendable bool) []byte {
+	return slip39Feistel(encrypted, passphrase, exponent, identifier, extendable, []int{3, 2, 1, 0})
+}
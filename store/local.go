@@ -0,0 +1,134 @@
+/*
+  Local directory store
+*/
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prefix of temporary files written by Put
+const LOCAL_TMP_PREFIX = ".tmp-"
+
+type LocalStore struct {
+	dir string
+}
+
+// Create store in directory dir. Directory is created if it doesn't exist
+func NewLocalStore(dir string) (*LocalStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (st *LocalStore) path(name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(st.dir, filepath.FromSlash(name)), nil
+}
+
+// Store data with name. File is written to a temporary file and renamed, so that readers never
+// see partial objects
+func (st *LocalStore) Put(name string, data []byte) error {
+	fname, err := st.path(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(fname)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(dir, LOCAL_TMP_PREFIX)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), fname)
+}
+
+func (st *LocalStore) Get(name string) ([]byte, error) {
+	fname, err := st.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (st *LocalStore) List(prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	err := filepath.Walk(st.dir, func(fname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), LOCAL_TMP_PREFIX) {
+			return nil
+		}
+		rel, err := filepath.Rel(st.dir, fname)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, ObjectInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+func (st *LocalStore) Delete(name string) error {
+	fname, err := st.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(fname)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (st *LocalStore) Stat(name string) (*ObjectInfo, error) {
+	fname, err := st.path(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fname)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
@@ -0,0 +1,298 @@
+/*
+  S3-compatible object store (AWS S3, MinIO, ...)
+
+  Objects are accessed with path-style URLs (<endpoint>/<bucket>/<prefix><name>), and requests
+  are authenticated with AWS Signature Version 4.
+*/
+
+package store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	S3_SERVICE        = "s3"
+	S3_ALGORITHM      = "AWS4-HMAC-SHA256"
+	S3_TIME_FORMAT    = "20060102T150405Z"
+	S3_DATE_FORMAT    = "20060102"
+	S3_DEFAULT_REGION = "us-east-1"
+)
+
+type S3Config struct {
+	Endpoint  string // Service URL, for example https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Region    string // Defaults to us-east-1
+	Bucket    string
+	Prefix    string // Prefix added to object names. Optional
+	AccessKey string
+	SecretKey string
+}
+
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// Create store for bucket. Bucket needs to exist
+func NewS3Store(cfg S3Config, client *http.Client) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.New("Backup store : invalid S3 endpoint")
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("Backup store : missing S3 bucket")
+	}
+	if cfg.Region == "" {
+		cfg.Region = S3_DEFAULT_REGION
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Store{cfg: cfg, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+func (st *S3Store) Put(name string, data []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	resp, err := st.do(http.MethodPut, st.cfg.Prefix+name, nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+func (st *S3Store) Get(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	resp, err := st.do(http.MethodGet, st.cfg.Prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = s3Error(resp); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (st *S3Store) Delete(name string) error {
+	// S3 deletes are idempotent. Check object exists
+	if _, err := st.Stat(name); err != nil {
+		return err
+	}
+	resp, err := st.do(http.MethodDelete, st.cfg.Prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+func (st *S3Store) Stat(name string) (*ObjectInfo, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	resp, err := st.do(http.MethodHead, st.cfg.Prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = s3Error(resp); err != nil {
+		return nil, err
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &ObjectInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// ListObjectsV2 response
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+func (st *S3Store) List(prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", st.cfg.Prefix+prefix)
+	for {
+		resp, err := st.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = s3Error(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, el := range result.Contents {
+			objects = append(objects, ObjectInfo{
+				Name:    strings.TrimPrefix(el.Key, st.cfg.Prefix),
+				Size:    el.Size,
+				ModTime: el.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// Send signed request for key (bucket if key is empty)
+func (st *S3Store) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *st.endpoint
+	path := strings.TrimSuffix(u.Path, "/") + "/" + st.cfg.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	signV4(req, payloadHash[:], st.cfg.AccessKey, st.cfg.SecretKey, st.cfg.Region, S3_SERVICE, st.now())
+
+	return st.client.Do(req)
+}
+
+// S3 error response
+type s3ErrorResponse struct {
+	Code    string
+	Message string
+}
+
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	var errResp s3ErrorResponse
+	body, _ := ioutil.ReadAll(resp.Body)
+	xml.Unmarshal(body, &errResp)
+	return fmt.Errorf("Backup store : S3 error %d %s %s", resp.StatusCode, errResp.Code, errResp.Message)
+}
+
+// Sign request with AWS Signature Version 4. Signed headers are host and x-amz-* headers
+func signV4(req *http.Request, payloadHash []byte, accessKey, secretKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(S3_TIME_FORMAT)
+	date := now.Format(S3_DATE_FORMAT)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	// canonical headers
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := S3_ALGORITHM + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", S3_ALGORITHM+" Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Query string sorted by key, with keys and values URI encoded
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// URI encode every byte except unreserved characters (RFC 3986). Slash is encoded if encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	TEST_S3_BUCKET     = "backups"
+	TEST_S3_REGION     = "eu-west-1"
+	TEST_S3_ACCESS_KEY = "AKIDEXAMPLE"
+	TEST_S3_SECRET_KEY = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// In memory S3 server. Only path-style requests to a single bucket are supported
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+type fakeS3Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type fakeS3List struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []fakeS3Object
+}
+
+// Page size of list responses, to test continuation tokens
+const TEST_S3_MAX_KEYS = 2
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.checkSignature(r) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>SignatureDoesNotMatch</Code><Message>Invalid signature</Message></Error>"))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path != TEST_S3_BUCKET && !strings.HasPrefix(path, TEST_S3_BUCKET+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(path, TEST_S3_BUCKET), "/")
+
+	switch {
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	start := r.URL.Query().Get("continuation-token")
+	keys := make([]string, 0)
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > start {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var result fakeS3List
+	if len(keys) > TEST_S3_MAX_KEYS {
+		keys = keys[:TEST_S3_MAX_KEYS]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		result.Contents = append(result.Contents, fakeS3Object{Key: k, Size: int64(len(f.objects[k])), LastModified: time.Now().UTC()})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// Recompute signature from received request
+func (f *fakeS3) checkSignature(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	amzDate, err := time.Parse(S3_TIME_FORMAT, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	payloadHash := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return false
+	}
+
+	req := r.Clone(r.Context())
+	req.URL.Host = r.Host
+	req.Header.Del("Authorization")
+	signV4(req, payloadHash[:], TEST_S3_ACCESS_KEY, TEST_S3_SECRET_KEY, TEST_S3_REGION, S3_SERVICE, amzDate)
+	return auth != "" && req.Header.Get("Authorization") == auth
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer server.Close()
+
+	cfg := S3Config{
+		Endpoint:  server.URL,
+		Region:    TEST_S3_REGION,
+		Bucket:    TEST_S3_BUCKET,
+		Prefix:    "device1/",
+		AccessKey: TEST_S3_ACCESS_KEY,
+		SecretKey: TEST_S3_SECRET_KEY,
+	}
+	st, err := NewS3Store(cfg, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, st)
+
+	// wrong credentials
+	cfg.SecretKey = "wrong"
+	st, _ = NewS3Store(cfg, server.Client())
+	if _, err = st.Get("backup.bk"); err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Error("Invalid signature accepted", err)
+	}
+
+	// invalid configurations
+	if _, err = NewS3Store(S3Config{Endpoint: "localhost", Bucket: TEST_S3_BUCKET}, nil); err == nil {
+		t.Error("Invalid endpoint accepted")
+	}
+	if _, err = NewS3Store(S3Config{Endpoint: server.URL}, nil); err == nil {
+		t.Error("Missing bucket accepted")
+	}
+}
+
+// get-vanilla case of the AWS Signature Version 4 test suite
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	payloadHash := sha256.Sum256(nil)
+	now, _ := time.Parse(S3_TIME_FORMAT, "20150830T123600Z")
+	signV4(req, payloadHash[:], TEST_S3_ACCESS_KEY, TEST_S3_SECRET_KEY, "us-east-1", "service", now)
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if req.Header.Get("Authorization") != expected {
+		t.Error("Unexpected signature", req.Header.Get("Authorization"))
+	}
+	if uriEncode("/a b/c~d", false) != "/a%20b/c~d" || uriEncode("a/b+c", true) != "a%2Fb%2Bc" {
+		t.Error("Unexpected URI encoding")
+	}
+}
@@ -0,0 +1,53 @@
+/*
+  Package store implements backends to keep backup files out of the device.
+
+  A BackupStore keeps named objects (backup files). Names are slash separated relative paths
+  (for example "wallet/backup.bk"). Implementations are provided for a local directory, an
+  S3-compatible object store (AWS S3, MinIO...) and a WebDAV server.
+*/
+
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Object description
+type ObjectInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+type BackupStore interface {
+	// Store data with name. Existing objects are replaced
+	Put(name string, data []byte) error
+	// Retrieve object name. Returns ErrNotFound if object doesn't exist
+	Get(name string) ([]byte, error)
+	// List objects whose name starts with prefix, sorted by name
+	List(prefix string) ([]ObjectInfo, error)
+	// Delete object name. Returns ErrNotFound if object doesn't exist
+	Delete(name string) error
+	// Describe object name. Returns ErrNotFound if object doesn't exist
+	Stat(name string) (*ObjectInfo, error)
+}
+
+var (
+	ErrNotFound    = errors.New("Backup store : object not found")
+	ErrInvalidName = errors.New("Backup store : invalid object name")
+)
+
+// Names are non empty relative paths without empty, "." or ".." elements
+func checkName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return ErrInvalidName
+	}
+	for _, el := range strings.Split(name, "/") {
+		if el == "" || el == "." || el == ".." {
+			return ErrInvalidName
+		}
+	}
+	return nil
+}
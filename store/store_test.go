@@ -0,0 +1,114 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Conformance test run against every store implementation
+func testStore(t *testing.T, st BackupStore) {
+	t.Helper()
+	objects := map[string][]byte{
+		"backup.bk":         []byte("backup"),
+		"wallet/0001.bk":    []byte("wallet backup 1"),
+		"wallet/0002.bk":    []byte("wallet backup 2"),
+		"wallet/old/a.bk":   []byte("old wallet backup"),
+		"other/wallet.bk":   []byte("other"),
+		"space name/a b.bk": []byte("name with spaces"),
+	}
+	for name, data := range objects {
+		if err := st.Put(name, data); err != nil {
+			t.Fatal("Put", name, err)
+		}
+	}
+	for name, data := range objects {
+		obtained, err := st.Get(name)
+		if err != nil || !bytes.Equal(obtained, data) {
+			t.Error("Get", name, err)
+		}
+		info, err := st.Stat(name)
+		if err != nil || info.Name != name || info.Size != int64(len(data)) {
+			t.Error("Stat", name, info, err)
+		}
+	}
+
+	// overwrite
+	if err := st.Put("backup.bk", []byte("new backup")); err != nil {
+		t.Error("Put", err)
+	}
+	if data, _ := st.Get("backup.bk"); string(data) != "new backup" {
+		t.Error("Object not replaced")
+	}
+
+	// list
+	list, err := st.List("wallet/")
+	if err != nil || len(list) != 3 ||
+		list[0].Name != "wallet/0001.bk" || list[1].Name != "wallet/0002.bk" || list[2].Name != "wallet/old/a.bk" ||
+		list[0].Size != int64(len(objects["wallet/0001.bk"])) {
+		t.Error("List", list, err)
+	}
+	list, err = st.List("")
+	if err != nil || len(list) != len(objects) {
+		t.Error("List all", list, err)
+	}
+	list, err = st.List("missing")
+	if err != nil || len(list) != 0 {
+		t.Error("List missing", list, err)
+	}
+
+	// delete
+	if err = st.Delete("wallet/0001.bk"); err != nil {
+		t.Error("Delete", err)
+	}
+	if _, err = st.Get("wallet/0001.bk"); !errors.Is(err, ErrNotFound) {
+		t.Error("Deleted object retrieved", err)
+	}
+	if _, err = st.Stat("wallet/0001.bk"); !errors.Is(err, ErrNotFound) {
+		t.Error("Deleted object described", err)
+	}
+	if err = st.Delete("wallet/0001.bk"); !errors.Is(err, ErrNotFound) {
+		t.Error("Deleted object twice", err)
+	}
+	if _, err = st.Stat("wallet"); !errors.Is(err, ErrNotFound) {
+		t.Error("Collection described as object", err)
+	}
+	if list, _ = st.List("wallet/"); len(list) != 2 {
+		t.Error("List after delete", list)
+	}
+
+	// invalid names
+	for _, name := range []string{"", "/backup.bk", "../backup.bk", "wallet//a.bk", "wallet/./a.bk", "a\\b"} {
+		if err = st.Put(name, []byte("x")); !errors.Is(err, ErrInvalidName) {
+			t.Error("Invalid name accepted", name, err)
+		}
+		if _, err = st.Get(name); !errors.Is(err, ErrInvalidName) {
+			t.Error("Invalid name accepted", name, err)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := NewLocalStore(dir + "/backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, st)
+
+	// temporary files are not listed
+	ioutil.WriteFile(dir+"/backups/"+LOCAL_TMP_PREFIX+"123", []byte("partial"), 0600)
+	list, _ := st.List("")
+	for _, el := range list {
+		if el.Name == LOCAL_TMP_PREFIX+"123" {
+			t.Error("Temporary file listed")
+		}
+	}
+}
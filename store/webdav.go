@@ -0,0 +1,243 @@
+/*
+  WebDAV store (Nextcloud, ownCloud, Apache mod_dav, ...)
+
+  Objects are stored as resources below a base collection URL. Collections needed by object
+  names with slashes are created with MKCOL. Requests use HTTP basic authentication if a user
+  is configured.
+*/
+
+package store
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Properties requested with PROPFIND
+const WEBDAV_PROPFIND = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+type WebDAVStore struct {
+	base     *url.URL
+	user     string
+	password string
+	client   *http.Client
+}
+
+// Create store in collection baseURL. Collection needs to exist
+func NewWebDAVStore(baseURL, user, password string, client *http.Client) (*WebDAVStore, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("Backup store : invalid WebDAV URL")
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebDAVStore{base: base, user: user, password: password, client: client}, nil
+}
+
+func (st *WebDAVStore) Put(name string, data []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	// create parent collections
+	elements := strings.Split(name, "/")
+	for idx := 1; idx < len(elements); idx++ {
+		err := st.mkcol(strings.Join(elements[:idx], "/") + "/")
+		if err != nil {
+			return err
+		}
+	}
+	resp, err := st.do(http.MethodPut, name, nil, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return webdavError(resp)
+}
+
+func (st *WebDAVStore) Get(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	resp, err := st.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = webdavError(resp); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (st *WebDAVStore) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	resp, err := st.do(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return webdavError(resp)
+}
+
+func (st *WebDAVStore) Stat(name string) (*ObjectInfo, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	objects, err := st.propfind(name, "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 || strings.HasSuffix(objects[0].Name, "/") {
+		return nil, ErrNotFound
+	}
+	return &objects[0], nil
+}
+
+// List walks collections below the base collection with PROPFIND (Depth 1)
+func (st *WebDAVStore) List(prefix string) ([]ObjectInfo, error) {
+	objects := make([]ObjectInfo, 0)
+	pending := []string{""}
+	for len(pending) > 0 {
+		collection := pending[0]
+		pending = pending[1:]
+		entries, err := st.propfind(collection, "1")
+		if err != nil {
+			return nil, err
+		}
+		for _, el := range entries {
+			if strings.HasSuffix(el.Name, "/") {
+				// only walk collections that may contain objects with prefix
+				if el.Name != collection && (strings.HasPrefix(el.Name, prefix) || strings.HasPrefix(prefix, el.Name)) {
+					pending = append(pending, el.Name)
+				}
+				continue
+			}
+			if strings.HasPrefix(el.Name, prefix) {
+				objects = append(objects, el)
+			}
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// Multistatus response
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// Returns resources described by PROPFIND. Names of collections end with slash
+func (st *WebDAVStore) propfind(name, depth string) ([]ObjectInfo, error) {
+	header := http.Header{}
+	header.Set("Depth", depth)
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := st.do("PROPFIND", name, header, strings.NewReader(WEBDAV_PROPFIND))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = webdavError(resp); err != nil {
+		return nil, err
+	}
+	var ms webdavMultistatus
+	err = xml.NewDecoder(resp.Body).Decode(&ms)
+	if err != nil {
+		return nil, fmt.Errorf("Backup store : invalid WebDAV response : %w", err)
+	}
+
+	objects := make([]ObjectInfo, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil || !strings.HasPrefix(href.Path, st.base.Path) {
+			continue
+		}
+		info := ObjectInfo{Name: strings.TrimPrefix(href.Path, st.base.Path)}
+		if info.Name == "" {
+			// base collection
+			continue
+		}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil && !strings.HasSuffix(info.Name, "/") {
+				info.Name += "/"
+			}
+			if ps.Prop.ContentLength != "" {
+				info.Size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			}
+			if ps.Prop.LastModified != "" {
+				info.ModTime, _ = http.ParseTime(ps.Prop.LastModified)
+			}
+		}
+		objects = append(objects, info)
+	}
+	return objects, nil
+}
+
+// Create collection. Existing collections are accepted
+func (st *WebDAVStore) mkcol(name string) error {
+	resp, err := st.do("MKCOL", name, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil
+	}
+	return webdavError(resp)
+}
+
+func (st *WebDAVStore) do(method, name string, header http.Header, body io.Reader) (*http.Response, error) {
+	u := *st.base
+	u.Path = st.base.Path + name
+	u.RawPath = ""
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if st.user != "" {
+		req.SetBasicAuth(st.user, st.password)
+	}
+	return st.client.Do(req)
+}
+
+func webdavError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("Backup store : WebDAV error %d", resp.StatusCode)
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+const (
+	TEST_WEBDAV_USER     = "user"
+	TEST_WEBDAV_PASSWORD = "password"
+)
+
+func newWebDAVServer() *httptest.Server {
+	dav := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != TEST_WEBDAV_USER || password != TEST_WEBDAV_PASSWORD {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+}
+
+func TestWebDAVStore(t *testing.T) {
+	server := newWebDAVServer()
+	defer server.Close()
+
+	st, err := NewWebDAVStore(server.URL+"/dav", TEST_WEBDAV_USER, TEST_WEBDAV_PASSWORD, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, st)
+
+	// wrong credentials
+	st, _ = NewWebDAVStore(server.URL+"/dav/", TEST_WEBDAV_USER, "wrong", server.Client())
+	if _, err = st.Get("backup.bk"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Error("Invalid credentials accepted", err)
+	}
+
+	if _, err = NewWebDAVStore("dav", "", "", nil); err == nil {
+		t.Error("Invalid URL accepted")
+	}
+}